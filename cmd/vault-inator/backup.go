@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/nonaxanon/vault-inator/internal/age"
+	"github.com/nonaxanon/vault-inator/internal/exchange"
+	"github.com/nonaxanon/vault-inator/internal/services"
+	"golang.org/x/term"
+)
+
+const (
+	// backupPassphraseEnv names the environment variable holding the backup passphrase
+	backupPassphraseEnv = "VAULTINATOR_BACKUP_PASSPHRASE"
+	// backupRecipientsEnv names the environment variable holding comma-separated age
+	// recipients; when set, export encrypts to them instead of asking for a passphrase
+	backupRecipientsEnv = "VAULTINATOR_BACKUP_RECIPIENTS"
+)
+
+// runCommand runs a command line subcommand against the vault instead of the server:
+//
+//	vault-inator export [-o file] [-passphrase-file file] [-recipient age1... | -recipients-file file]
+//	vault-inator restore [-mode merge|replace] [-dry-run] [-passphrase-file file | -identity file] <backup>
+func runCommand(passwordService *services.PasswordService, args []string) error {
+	switch args[0] {
+	case "export":
+		return runExport(passwordService, args[1:])
+	case "restore":
+		return runRestore(passwordService, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q, expected export or restore", args[0])
+	}
+}
+
+// runExport writes an encrypted backup of the whole vault, to age recipients when any are
+// given and with a passphrase otherwise
+func runExport(passwordService *services.PasswordService, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := flags.String("o", "", "file to write the backup to")
+	passphraseFile := flags.String("passphrase-file", "", "file holding the backup passphrase")
+	var recipients []string
+	flags.Func("recipient", "age public key to encrypt the backup to; may be repeated", func(value string) error {
+		recipients = append(recipients, value)
+		return nil
+	})
+	recipientsFile := flags.String("recipients-file", "", "file of age public keys, one per line")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *recipientsFile != "" {
+		listed, err := readRecipients(*recipientsFile)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, listed...)
+	}
+	if len(recipients) == 0 {
+		for _, recipient := range strings.Split(os.Getenv(backupRecipientsEnv), ",") {
+			if recipient = strings.TrimSpace(recipient); recipient != "" {
+				recipients = append(recipients, recipient)
+			}
+		}
+	}
+
+	format := exchange.FormatArchive
+	var opts exchange.Options
+	if len(recipients) > 0 {
+		format = exchange.FormatAgeArchive
+		opts.Recipients = recipients
+	} else {
+		passphrase, err := readPassphrase(*passphraseFile)
+		if err != nil {
+			return err
+		}
+		opts.Passphrase = passphrase
+	}
+	exporter, err := exchange.LookupExporter(format)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		*output = exporter.FileName
+	}
+
+	vault, err := passwordService.ExportVault(services.ExportOptions{Attachments: true})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := exporter.Write(file, vault, opts); err != nil {
+		file.Close()
+		os.Remove(*output)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	log.Printf("Exported %d entries and %d folders to %s", len(vault.Passwords), len(vault.Folders), *output)
+	return nil
+}
+
+// runRestore restores an encrypted backup and prints the report as JSON
+func runRestore(passwordService *services.PasswordService, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	mode := flags.String("mode", services.RestoreMerge, "merge keeps the newer version of entries in both; replace deletes every folder and entry first")
+	dryRun := flags.Bool("dry-run", false, "report what would change without saving")
+	passphraseFile := flags.String("passphrase-file", "", "file holding the backup passphrase")
+	identityFile := flags.String("identity", "", "age identity file for backups encrypted to recipients")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: vault-inator restore [flags] <backup file>")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Only passphrase backups prompt for a passphrase
+	backup := bufio.NewReader(file)
+	var vault services.VaultExport
+	if start, _ := backup.Peek(64); age.IsAge(start) {
+		if *identityFile == "" {
+			return exchange.ErrIdentityRequired
+		}
+		identities, err := readIdentities(*identityFile)
+		if err != nil {
+			return err
+		}
+		vault, err = exchange.ReadAgeArchive(backup, identities)
+		if err != nil {
+			return err
+		}
+	} else {
+		passphrase, err := readPassphrase(*passphraseFile)
+		if err != nil {
+			return err
+		}
+		vault, err = exchange.ReadArchive(backup, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+
+	report, err := passwordService.RestoreVault(vault, services.RestoreOptions{Mode: *mode, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// readPassphrase reads the backup passphrase from file when given, then from the
+// VAULTINATOR_BACKUP_PASSPHRASE environment variable, and otherwise from the terminal
+// without echoing it, or from the first line of standard input when it is not a terminal
+func readPassphrase(file string) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if passphrase := os.Getenv(backupPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Backup passphrase: ")
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		return string(passphrase), nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readRecipients reads age public keys from a file, one per line, skipping blank lines and
+// lines starting with #
+func readRecipients(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var recipients []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			recipients = append(recipients, line)
+		}
+	}
+	return recipients, nil
+}
+
+// readIdentities reads an age identity file such as one written by age-keygen
+func readIdentities(file string) ([]*age.Identity, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return age.ParseIdentities(f)
+}
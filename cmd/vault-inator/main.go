@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/nonaxanon/vault-inator/internal/api"
+	"github.com/nonaxanon/vault-inator/internal/attachments"
+	"github.com/nonaxanon/vault-inator/internal/breach"
 	"github.com/nonaxanon/vault-inator/internal/config"
 	"github.com/nonaxanon/vault-inator/internal/services"
 	"github.com/nonaxanon/vault-inator/internal/storage"
@@ -43,8 +47,53 @@ func main() {
 		if err := passwordService.SetEncryptionKey(key[:]); err != nil {
 			log.Fatalf("Failed to set encryption key: %v", err)
 		}
+		// The configured master password unlocks the vault and builds the search index
+		if err := passwordService.Unlock(); err != nil {
+			log.Fatalf("Failed to unlock vault: %v", err)
+		}
+	}
+
+	// Configure attachment storage
+	var attachmentStore attachments.Store
+	switch cfg.AttachmentStore {
+	case "file":
+		fileStore, err := attachments.NewFileStore(cfg.AttachmentDir)
+		if err != nil {
+			log.Fatalf("Failed to create attachment store: %v", err)
+		}
+		attachmentStore = fileStore
+	default:
+		attachmentStore = attachments.NewDBStore(db.DB)
+	}
+	passwordService.SetAttachmentStore(attachmentStore, cfg.MaxAttachmentSize)
+	passwordService.SetMinStrength(cfg.MinPasswordStrength)
+	passwordService.SetRotationReminder(cfg.RotationReminder)
+
+	// Open the local breach dataset
+	if cfg.BreachDataset != "" {
+		dataset, err := breach.Open(cfg.BreachDataset)
+		if err != nil {
+			log.Fatalf("Failed to open breach dataset: %v", err)
+		}
+		defer dataset.Close()
+		passwordService.SetBreachChecker(dataset)
+		log.Printf("Checking passwords against the %s breach dataset %s", dataset.HashType(), cfg.BreachDataset)
+	}
+
+	// A command such as export or restore runs instead of the server
+	if len(os.Args) > 1 {
+		if err := runCommand(passwordService, os.Args[1:]); err != nil {
+			log.Fatalf("Failed to run %s: %v", os.Args[1], err)
+		}
+		return
 	}
 
+	// Purge expired trash in the background
+	go passwordService.RunTrashPurger(context.Background(), cfg.TrashRetention, time.Hour)
+
+	// Log reminders for passwords due or overdue for rotation once a day
+	go passwordService.RunRotationReminders(context.Background(), 24*time.Hour)
+
 	// Create and start API server
 	server := api.NewServer(db, authService, passwordService)
 
@@ -0,0 +1,138 @@
+// Package age encrypts backups to X25519 recipients in the age v1 format
+// (https://age-encryption.org/v1), so they can be opened with the age command line tool or
+// an identity file from age-keygen. The format is implemented by filippo.io/age; this
+// package limits it to X25519 keys, as passphrase (scrypt) and SSH recipients are not
+// supported.
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// intro is the first line of every age v1 file
+const intro = "age-encryption.org/v1"
+
+var (
+	ErrInvalidRecipient = errors.New("invalid age recipient")
+	ErrInvalidIdentity  = errors.New("invalid age identity")
+	ErrNoRecipients     = errors.New("at least one age recipient is required")
+	ErrNoIdentityMatch  = errors.New("no identity matches any of the file's recipients")
+)
+
+// Recipient is an X25519 public key that a file can be encrypted to
+type Recipient struct {
+	recipient *age.X25519Recipient
+}
+
+// Identity is an X25519 private key that decrypts files encrypted to its Recipient
+type Identity struct {
+	identity *age.X25519Identity
+}
+
+// IsAge reports whether data, the first bytes of a file, starts with the age v1 intro line
+func IsAge(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(intro+"\n"))
+}
+
+// Encrypt returns a writer that encrypts to dst for every recipient. The caller must close
+// it to write the final chunk.
+func Encrypt(dst io.Writer, recipients ...*Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+	wrapped := make([]age.Recipient, len(recipients))
+	for i, recipient := range recipients {
+		wrapped[i] = recipient.recipient
+	}
+	return age.Encrypt(dst, wrapped...)
+}
+
+// Decrypt returns a reader of the plaintext of src, whose file key is unwrapped with the
+// first identity that matches one of its recipients. Damage to the payload is reported by
+// the reader.
+func Decrypt(src io.Reader, identities ...*Identity) (io.Reader, error) {
+	unwrapping := make([]age.Identity, len(identities))
+	for i, identity := range identities {
+		unwrapping[i] = identity.identity
+	}
+	r, err := age.Decrypt(src, unwrapping...)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return nil, ErrNoIdentityMatch
+	}
+	return r, err
+}
+
+// ParseRecipient parses an age1... public key
+func ParseRecipient(s string) (*Recipient, error) {
+	recipient, err := age.ParseX25519Recipient(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrInvalidRecipient, s, err)
+	}
+	return &Recipient{recipient: recipient}, nil
+}
+
+// String returns the age1... encoding of the recipient
+func (r *Recipient) String() string {
+	return r.recipient.String()
+}
+
+// GenerateIdentity creates a new random X25519 identity
+func GenerateIdentity() (*Identity, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{identity: identity}, nil
+}
+
+// ParseIdentity parses an AGE-SECRET-KEY-1... private key
+func ParseIdentity(s string) (*Identity, error) {
+	identity, err := age.ParseX25519Identity(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIdentity, err)
+	}
+	return &Identity{identity: identity}, nil
+}
+
+// ParseIdentities reads an identity file: one private key per line, with blank lines and
+// lines starting with # ignored, as written by age-keygen
+func ParseIdentities(r io.Reader) ([]*Identity, error) {
+	var identities []*Identity
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		identity, err := ParseIdentity(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		identities = append(identities, identity)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIdentity, err)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("%w: no identities found", ErrInvalidIdentity)
+	}
+	return identities, nil
+}
+
+// String returns the AGE-SECRET-KEY-1... encoding of the identity
+func (i *Identity) String() string {
+	return i.identity.String()
+}
+
+// Recipient returns the public key matching the identity
+func (i *Identity) Recipient() *Recipient {
+	return &Recipient{recipient: i.identity.Recipient()}
+}
@@ -0,0 +1,186 @@
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	agetest "c2sp.org/CCTV/age"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	alice, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// More than one chunk of 64 KiB
+	plaintext := bytes.Repeat([]byte("vault-inator backup\n"), 10000)
+
+	var buf bytes.Buffer
+	w, err := Encrypt(&buf, alice.Recipient(), bob.Recipient())
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !IsAge(buf.Bytes()) {
+		t.Fatalf("IsAge = false for %q", buf.Bytes()[:32])
+	}
+
+	for _, identity := range []*Identity{alice, bob} {
+		r, err := Decrypt(bytes.NewReader(buf.Bytes()), identity)
+		if err != nil {
+			t.Fatalf("Decrypt: %v", err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("decrypted %d bytes, want %d", len(got), len(plaintext))
+		}
+	}
+
+	eve, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(bytes.NewReader(buf.Bytes()), eve); !errors.Is(err, ErrNoIdentityMatch) {
+		t.Errorf("Decrypt with another identity = %v, want ErrNoIdentityMatch", err)
+	}
+	if _, err := Encrypt(&buf); !errors.Is(err, ErrNoRecipients) {
+		t.Errorf("Encrypt without recipients = %v, want ErrNoRecipients", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	// The identity and recipient from the age specification's test vectors
+	const (
+		identity  = "AGE-SECRET-KEY-1XMWWC06LY3EE5RYTXM9MFLAZ2U56JJJ36S0MYPDRWSVLUL66MV4QX3S7F6"
+		recipient = "age1xmwwc06ly3ee5rytxm9mflaz2u56jjj36s0mypdrwsvlul66mv4q47ryef"
+	)
+	i, err := ParseIdentity(identity)
+	if err != nil {
+		t.Fatalf("ParseIdentity: %v", err)
+	}
+	if i.String() != identity {
+		t.Errorf("Identity.String() = %s, want %s", i, identity)
+	}
+	r, err := ParseRecipient(recipient)
+	if err != nil {
+		t.Fatalf("ParseRecipient: %v", err)
+	}
+	if r.String() != recipient {
+		t.Errorf("Recipient.String() = %s, want %s", r, recipient)
+	}
+
+	if _, err := ParseRecipient("age1notakey"); !errors.Is(err, ErrInvalidRecipient) {
+		t.Errorf("ParseRecipient of a bad key = %v, want ErrInvalidRecipient", err)
+	}
+	if _, err := ParseIdentity(recipient); !errors.Is(err, ErrInvalidIdentity) {
+		t.Errorf("ParseIdentity of a recipient = %v, want ErrInvalidIdentity", err)
+	}
+
+	file := "# created: 2024-01-01\n\n" + identity + "\n"
+	identities, err := ParseIdentities(strings.NewReader(file))
+	if err != nil || len(identities) != 1 {
+		t.Errorf("ParseIdentities = %d identities, %v", len(identities), err)
+	}
+	if _, err := ParseIdentities(strings.NewReader("# no keys\n")); !errors.Is(err, ErrInvalidIdentity) {
+		t.Errorf("ParseIdentities without keys = %v, want ErrInvalidIdentity", err)
+	}
+}
+
+// TestVectors decrypts the files of the age test kit (c2sp.org/CCTV/age) that use X25519
+// identities. Armored and passphrase vectors are skipped, as backups are neither.
+func TestVectors(t *testing.T) {
+	names, err := fs.Glob(agetest.Vectors, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		data, err := fs.ReadFile(agetest.Vectors, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(name, func(t *testing.T) {
+			testVector(t, data)
+		})
+	}
+}
+
+func testVector(t *testing.T, data []byte) {
+	var (
+		expect, payload string
+		identities      []*Identity
+	)
+	r := bufio.NewReader(bytes.NewReader(data))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("truncated vector: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+		key, value, _ := strings.Cut(line, ": ")
+		switch key {
+		case "expect":
+			expect = value
+		case "payload":
+			payload = value
+		case "identity":
+			identity, err := ParseIdentity(value)
+			if err != nil {
+				t.Fatalf("identity %s: %v", value, err)
+			}
+			identities = append(identities, identity)
+		case "armored", "passphrase":
+			t.Skip(key, "vectors are not supported")
+		}
+	}
+	if len(identities) == 0 {
+		t.Skip("no X25519 identity")
+	}
+
+	plaintext, err := Decrypt(r, identities...)
+	if err == nil {
+		var got []byte
+		got, err = io.ReadAll(plaintext)
+		if err == nil && expect == "success" {
+			sum := sha256.Sum256(got)
+			if hex.EncodeToString(sum[:]) != payload {
+				t.Errorf("payload hash = %x, want %s", sum, payload)
+			}
+		}
+	}
+	switch expect {
+	case "success":
+		if err != nil {
+			t.Errorf("Decrypt: %v", err)
+		}
+	case "no match":
+		if !errors.Is(err, ErrNoIdentityMatch) {
+			t.Errorf("Decrypt = %v, want ErrNoIdentityMatch", err)
+		}
+	default:
+		if err == nil {
+			t.Errorf("Decrypt succeeded, want %s", expect)
+		}
+	}
+}
@@ -2,13 +2,19 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/nonaxanon/vault-inator/internal/age"
+	"github.com/nonaxanon/vault-inator/internal/exchange"
 	"github.com/nonaxanon/vault-inator/internal/services"
 	"github.com/nonaxanon/vault-inator/internal/storage"
+	"github.com/nonaxanon/vault-inator/internal/urlmatch"
 	"github.com/rs/cors"
 	"github.com/sirupsen/logrus"
 )
@@ -38,19 +44,112 @@ func NewServer(db *storage.DB, authService *services.AuthService, passwordServic
 	return s
 }
 
+// statusForError maps service and storage errors to an HTTP status code.
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, services.ErrNoTOTP):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrFolderCycle), errors.Is(err, services.ErrInvalidFolderName),
+		errors.Is(err, services.ErrInvalidCustomField), errors.Is(err, services.ErrInvalidEntry),
+		errors.Is(err, services.ErrInvalidRotation):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrInvalidCursor), errors.Is(err, services.ErrInvalidSortKey),
+		errors.Is(err, services.ErrInvalidBulkRequest), errors.Is(err, services.ErrInvalidRestoreMode),
+		errors.Is(err, services.ErrInvalidGeneratorOptions), errors.Is(err, services.ErrInvalidGeneratorProfile):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrInvalidEquivalentDomains), errors.Is(err, urlmatch.ErrInvalidURL),
+		errors.Is(err, urlmatch.ErrInvalidMode):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrHOTPNotIdempotent):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, services.ErrPasswordTooWeak):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrAttachmentTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrAttachmentsDisabled):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, exchange.ErrUnknownFormat), errors.Is(err, exchange.ErrInvalidImport),
+		errors.Is(err, exchange.ErrPassphraseRequired), errors.Is(err, exchange.ErrIdentityRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, age.ErrInvalidRecipient), errors.Is(err, age.ErrNoRecipients), errors.Is(err, age.ErrInvalidIdentity):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrVaultLocked):
+		return http.StatusLocked
+	}
+	return http.StatusInternalServerError
+}
+
 // routes sets up the API routes.
 func (s *Server) routes() {
 	// Auth endpoints
 	s.router.HandleFunc("/api/auth/initialize", s.handleInitializeMasterPassword).Methods("POST")
 	s.router.HandleFunc("/api/auth/verify", s.handleVerifyMasterPassword).Methods("POST")
 	s.router.HandleFunc("/api/auth/change", s.handleChangeMasterPassword).Methods("POST")
+	s.router.HandleFunc("/api/auth/lock", s.handleLock).Methods("POST")
 	s.router.HandleFunc("/api/auth/status", s.handleAuthStatus).Methods("GET")
 
 	// Password endpoints
 	s.router.HandleFunc("/api/passwords", s.handleAddPassword).Methods("POST")
 	s.router.HandleFunc("/api/passwords", s.handleGetAllPasswords).Methods("GET")
+	s.router.HandleFunc("/api/passwords/bulk", s.handleBulk).Methods("POST")
 	s.router.HandleFunc("/api/passwords/{id}", s.handleGetPassword).Methods("GET")
+	s.router.HandleFunc("/api/passwords/{id}", s.handleUpdatePassword).Methods("PUT")
 	s.router.HandleFunc("/api/passwords/{id}", s.handleDeletePassword).Methods("DELETE")
+	s.router.HandleFunc("/api/passwords/{id}/move", s.handleMovePassword).Methods("POST")
+	s.router.HandleFunc("/api/passwords/{id}/tags", s.handleSetPasswordTags).Methods("PUT")
+	s.router.HandleFunc("/api/passwords/{id}/flags", s.handleSetPasswordFlags).Methods("PUT")
+	s.router.HandleFunc("/api/passwords/{id}/used", s.handleRecordUse).Methods("POST")
+	s.router.HandleFunc("/api/passwords/{id}/history", s.handleGetPasswordHistory).Methods("GET")
+	s.router.HandleFunc("/api/passwords/{id}/totp", s.handleGetTOTP).Methods("GET")
+	s.router.HandleFunc("/api/passwords/{id}/totp", s.handleGenerateTOTP).Methods("POST")
+	s.router.HandleFunc("/api/passwords/{id}/attachments", s.handleGetAttachments).Methods("GET")
+	s.router.HandleFunc("/api/passwords/{id}/attachments", s.handleUploadAttachment).Methods("POST")
+
+	// Attachment endpoints
+	s.router.HandleFunc("/api/attachments/{id}", s.handleDownloadAttachment).Methods("GET")
+	s.router.HandleFunc("/api/attachments/{id}", s.handleDeleteAttachment).Methods("DELETE")
+
+	// Import, export and restore endpoints
+	s.router.HandleFunc("/api/import", s.handleImport).Methods("POST")
+	s.router.HandleFunc("/api/export", s.handleExport).Methods("GET")
+	s.router.HandleFunc("/api/restore", s.handleRestore).Methods("POST")
+
+	// Generator and strength endpoints
+	s.router.HandleFunc("/api/generate", s.handleGenerate).Methods("POST")
+	s.router.HandleFunc("/api/strength", s.handleEstimateStrength).Methods("POST")
+	s.router.HandleFunc("/api/generator/profiles", s.handleGetGeneratorProfiles).Methods("GET")
+	s.router.HandleFunc("/api/generator/profiles", s.handleCreateGeneratorProfile).Methods("POST")
+	s.router.HandleFunc("/api/generator/profiles/{id}", s.handleGetGeneratorProfile).Methods("GET")
+	s.router.HandleFunc("/api/generator/profiles/{id}", s.handleUpdateGeneratorProfile).Methods("PUT")
+	s.router.HandleFunc("/api/generator/profiles/{id}", s.handleDeleteGeneratorProfile).Methods("DELETE")
+
+	// URL matching endpoints
+	s.router.HandleFunc("/api/match", s.handleMatchURL).Methods("GET")
+	s.router.HandleFunc("/api/match/equivalent-domains", s.handleGetEquivalentDomains).Methods("GET")
+	s.router.HandleFunc("/api/match/equivalent-domains", s.handleCreateEquivalentDomains).Methods("POST")
+	s.router.HandleFunc("/api/match/equivalent-domains/{id}", s.handleUpdateEquivalentDomains).Methods("PUT")
+	s.router.HandleFunc("/api/match/equivalent-domains/{id}", s.handleDeleteEquivalentDomains).Methods("DELETE")
+
+	// Report endpoints
+	s.router.HandleFunc("/api/reports/health", s.handleHealthReport).Methods("GET")
+
+	// Tag endpoints
+	s.router.HandleFunc("/api/tags", s.handleGetTags).Methods("GET")
+
+	// Folder endpoints
+	s.router.HandleFunc("/api/folders", s.handleGetFolders).Methods("GET")
+	s.router.HandleFunc("/api/folders", s.handleCreateFolder).Methods("POST")
+	s.router.HandleFunc("/api/folders/{id}", s.handleGetFolder).Methods("GET")
+	s.router.HandleFunc("/api/folders/{id}", s.handleRenameFolder).Methods("PUT")
+	s.router.HandleFunc("/api/folders/{id}", s.handleDeleteFolder).Methods("DELETE")
+	s.router.HandleFunc("/api/folders/{id}/move", s.handleMoveFolder).Methods("POST")
+	s.router.HandleFunc("/api/folders/{id}/rotation", s.handleSetFolderRotation).Methods("PUT")
+
+	// Trash endpoints
+	s.router.HandleFunc("/api/trash", s.handleGetTrash).Methods("GET")
+	s.router.HandleFunc("/api/trash", s.handleEmptyTrash).Methods("DELETE")
+	s.router.HandleFunc("/api/trash/{id}/restore", s.handleRestorePassword).Methods("POST")
+	s.router.HandleFunc("/api/trash/{id}", s.handlePurgePassword).Methods("DELETE")
 
 	// Serve static files (React frontend)
 	s.router.PathPrefix("/").Handler(http.FileServer(http.Dir("./web/build")))
@@ -61,8 +160,8 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Add CORS middleware
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:5432", "http://localhost:3000"},
-		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "X-Import-Passphrase", "X-Export-Passphrase", "X-Restore-Passphrase", "X-Restore-Identity"},
 		AllowCredentials: true,
 	})
 	c.Handler(s.router).ServeHTTP(w, r)
@@ -109,11 +208,28 @@ func (s *Server) handleVerifyMasterPassword(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// A verified master password unlocks the vault and builds the search index
+	if err := s.passwordService.Unlock(); err != nil {
+		s.logger.WithError(err).Error("Error unlocking vault")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	s.logger.Info("Successfully verified master password")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Password verified"})
 }
 
+// handleLock handles the POST request to lock the vault, discarding the search index.
+func (s *Server) handleLock(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received POST request to /api/auth/lock")
+	s.passwordService.Lock()
+
+	s.logger.Info("Successfully locked vault")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Vault locked"})
+}
+
 // handleChangeMasterPassword handles the POST request to change the master password.
 func (s *Server) handleChangeMasterPassword(w http.ResponseWriter, r *http.Request) {
 	s.logger.Info("Received POST request to /api/auth/change")
@@ -144,6 +260,7 @@ func (s *Server) handleAuthStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]bool{
 		"initialized": s.authService.IsInitialized(),
+		"unlocked":    s.passwordService.IsUnlocked(),
 	})
 }
 
@@ -157,55 +274,148 @@ func (s *Server) handleAddPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Convert to storage.PasswordEntry
-	entry := storage.PasswordEntry{
-		Title:    password.Title,
-		Username: password.Username,
-		Password: password.Password,
-		URL:      password.URL,
-		Notes:    password.Notes,
-	}
-
-	if err := s.db.AddPassword(entry); err != nil {
+	if err := s.passwordService.CreatePassword(&password); err != nil {
 		s.logger.WithError(err).Error("Error adding password")
-		http.Error(w, fmt.Sprintf("Failed to add password: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to add password: %v", err), statusForError(err))
 		return
 	}
 
 	s.logger.WithField("title", password.Title).Info("Successfully added password entry")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(password)
+	json.NewEncoder(w).Encode(s.newPasswordResponse(password))
 }
 
-// handleGetAllPasswords handles the GET request to retrieve all password entries.
+// handleGetAllPasswords handles the GET request to search and list password entries.
+// It returns a page of summaries without secrets; see parseSearchOptions for the
+// supported query parameters. Secrets are revealed with GET /api/passwords/{id}.
 func (s *Server) handleGetAllPasswords(w http.ResponseWriter, r *http.Request) {
 	s.logger.Info("Received GET request to /api/passwords")
-	entries, err := s.db.GetAllPasswords()
+
+	opts, err := parseSearchOptions(r)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid password search")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.passwordService.SearchPasswords(opts)
 	if err != nil {
 		s.logger.WithError(err).Error("Error fetching passwords")
-		http.Error(w, fmt.Sprintf("Failed to get passwords: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to get passwords: %v", err), statusForError(err))
 		return
 	}
 
-	// Convert to services.Password
-	passwords := make([]services.Password, len(entries))
-	for i, entry := range entries {
-		passwords[i] = services.Password{
-			ID:       entry.ID,
-			Title:    entry.Title,
-			Username: entry.Username,
-			Password: entry.Password,
-			URL:      entry.URL,
-			Notes:    entry.Notes,
+	s.logger.WithField("count", len(page.Items)).Info("Successfully fetched password entries")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// parseSearchOptions reads the search, sort and pagination parameters from the query string
+// in addition to the filter read by parsePasswordFilter:
+//
+//	q=<terms>          search terms; prefix with title:, username:, url:, tag: or field: to scope
+//	sort=<key>         title, username, url, type, created, updated, relevance,
+//	                   favorites or most_used; relevance when q is set, else title
+//	order=asc|desc     sort direction
+//	cursor=<cursor>    next_cursor of the previous page
+//	limit=<n>          page size
+func parseSearchOptions(r *http.Request) (services.SearchOptions, error) {
+	query := r.URL.Query()
+	var opts services.SearchOptions
+
+	filter, err := parsePasswordFilter(r)
+	if err != nil {
+		return opts, err
+	}
+	opts.Filter = filter
+	opts.Query = query.Get("q")
+	opts.Sort = query.Get("sort")
+	opts.Cursor = query.Get("cursor")
+
+	switch order := query.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		opts.Descending = true
+	default:
+		return opts, fmt.Errorf("invalid order %q, expected asc or desc", order)
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return opts, fmt.Errorf("invalid limit %q", limit)
 		}
+		opts.Limit = n
 	}
 
-	s.logger.WithField("count", len(passwords)).Info("Successfully fetched password entries")
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(passwords)
+	return opts, nil
+}
+
+// parsePasswordFilter reads the listing filter from the query string:
+//
+//	folder=<id>|none   entries in a folder, or entries without a folder
+//	recursive=true     include entries in subfolders of folder
+//	tag=<name>         repeatable or comma separated tag names
+//	tag_mode=and|or    require all tags (default) or any of them
+//	type=<type>        repeatable or comma separated entry types
+//	due=true           entries whose password rotation is due or overdue
+func parsePasswordFilter(r *http.Request) (services.PasswordFilter, error) {
+	query := r.URL.Query()
+	var filter services.PasswordFilter
+
+	switch folder := query.Get("folder"); folder {
+	case "":
+	case "none":
+		filter.Unfiled = true
+	default:
+		id, err := uuid.Parse(folder)
+		if err != nil {
+			return filter, fmt.Errorf("invalid folder ID format")
+		}
+		filter.FolderID = &id
+		filter.IncludeSubfolders = query.Get("recursive") == "true"
+	}
+
+	for _, value := range query["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				filter.Tags = append(filter.Tags, tag)
+			}
+		}
+	}
+
+	for _, value := range query["type"] {
+		for _, entryType := range strings.Split(value, ",") {
+			entryType = strings.TrimSpace(entryType)
+			if !services.IsEntryType(entryType) {
+				return filter, fmt.Errorf("invalid entry type %q", entryType)
+			}
+			filter.Types = append(filter.Types, entryType)
+		}
+	}
+
+	switch due := query.Get("due"); due {
+	case "", "false":
+	case "true":
+		filter.Due = true
+	default:
+		return filter, fmt.Errorf("invalid due %q, expected true or false", due)
+	}
+
+	switch mode := query.Get("tag_mode"); mode {
+	case "", "and":
+		filter.MatchAllTags = true
+	case "or":
+		filter.MatchAllTags = false
+	default:
+		return filter, fmt.Errorf("invalid tag_mode %q, expected and or or", mode)
+	}
+
+	return filter, nil
 }
 
-// handleGetPassword handles the GET request to retrieve a specific password entry by ID.
+// handleGetPassword handles the GET request to retrieve a specific password entry by ID,
+// including its password and other secrets.
 func (s *Server) handleGetPassword(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -220,29 +430,61 @@ func (s *Server) handleGetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entry, err := s.db.GetPassword(uuid)
+	password, err := s.passwordService.GetPassword(uuid)
 	if err != nil {
 		s.logger.WithError(err).Error("Error fetching password")
-		http.Error(w, fmt.Sprintf("Failed to get password: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to get password: %v", err), statusForError(err))
 		return
 	}
 
-	// Convert to services.Password
-	password := services.Password{
-		ID:       entry.ID,
-		Title:    entry.Title,
-		Username: entry.Username,
-		Password: entry.Password,
-		URL:      entry.URL,
-		Notes:    entry.Notes,
-	}
-
 	s.logger.WithField("id", id).Info("Successfully fetched password entry")
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(password)
 }
 
-// handleDeletePassword handles the DELETE request to remove a password entry by ID.
+// handleUpdatePassword handles the PUT request to replace a password entry by ID. Like the
+// POST request, the response includes the estimated strength of the password.
+func (s *Server) handleUpdatePassword(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	s.logger.WithField("id", id).Info("Received PUT request to /api/passwords/{id}")
+
+	// Parse UUID
+	uuid, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	var password services.Password
+	if err := json.NewDecoder(r.Body).Decode(&password); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	password.ID = uuid
+
+	if err := s.passwordService.UpdatePassword(&password); err != nil {
+		s.logger.WithError(err).Error("Error updating password")
+		http.Error(w, fmt.Sprintf("Failed to update password: %v", err), statusForError(err))
+		return
+	}
+
+	updated, err := s.passwordService.GetPassword(uuid)
+	if err != nil {
+		s.logger.WithError(err).Error("Error fetching password")
+		http.Error(w, fmt.Sprintf("Failed to get password: %v", err), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully updated password entry")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.newPasswordResponse(updated))
+}
+
+// handleDeletePassword handles the DELETE request to move a password entry to the trash by ID.
 func (s *Server) handleDeletePassword(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -257,12 +499,12 @@ func (s *Server) handleDeletePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.db.DeletePassword(uuid); err != nil {
+	if err := s.passwordService.DeletePassword(uuid); err != nil {
 		s.logger.WithError(err).WithField("id", id).Error("Error deleting password")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), statusForError(err))
 		return
 	}
 
-	s.logger.WithField("id", id).Info("Successfully deleted password entry")
+	s.logger.WithField("id", id).Info("Successfully moved password entry to trash")
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// multipartOverhead is the allowance for multipart headers and boundaries on top of the attachment size limit.
+const multipartOverhead = 1 << 20
+
+// handleGetAttachments handles the GET request to list the attachments of a password entry.
+func (s *Server) handleGetAttachments(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received GET request to /api/passwords/{id}/attachments")
+
+	// Parse UUID
+	passwordID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	attachments, err := s.passwordService.GetAttachments(passwordID)
+	if err != nil {
+		s.logger.WithError(err).Error("Error fetching attachments")
+		http.Error(w, fmt.Sprintf("Failed to get attachments: %v", err), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("count", len(attachments)).Info("Successfully fetched attachments")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// handleUploadAttachment handles the multipart POST request to attach a file to a password entry.
+// The file is read from the "file" part and encrypted while it streams in.
+func (s *Server) handleUploadAttachment(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received POST request to /api/passwords/{id}/attachments")
+
+	// Parse UUID
+	passwordID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.passwordService.MaxAttachmentSize()+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		s.logger.WithError(err).Error("Error reading multipart body")
+		http.Error(w, "Expected a multipart/form-data body", http.StatusBadRequest)
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.logger.WithError(err).Error("Error reading multipart body")
+			http.Error(w, "Invalid multipart body", http.StatusBadRequest)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		attachment, err := s.passwordService.UploadAttachment(passwordID, part.FileName(), part.Header.Get("Content-Type"), part)
+		part.Close()
+		if err != nil {
+			s.logger.WithError(err).WithField("id", id).Error("Error uploading attachment")
+			status := statusForError(err)
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, fmt.Sprintf("Failed to upload attachment: %v", err), status)
+			return
+		}
+
+		s.logger.WithField("id", attachment.ID).Info("Successfully uploaded attachment")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(attachment)
+		return
+	}
+
+	s.logger.Error("Multipart body has no file part")
+	http.Error(w, "Missing file part", http.StatusBadRequest)
+}
+
+// handleDownloadAttachment handles the GET request to stream the decrypted content of an attachment.
+func (s *Server) handleDownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received GET request to /api/attachments/{id}")
+
+	// Parse UUID
+	attachmentID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := s.passwordService.GetAttachment(attachmentID)
+	if err != nil {
+		s.logger.WithError(err).Error("Error fetching attachment")
+		http.Error(w, fmt.Sprintf("Failed to get attachment: %v", err), statusForError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := s.passwordService.WriteAttachment(attachmentID, w); err != nil {
+		// Headers are already sent, so the client sees a truncated body
+		s.logger.WithError(err).WithField("id", id).Error("Error streaming attachment")
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully streamed attachment")
+}
+
+// handleDeleteAttachment handles the DELETE request to remove an attachment.
+func (s *Server) handleDeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received DELETE request to /api/attachments/{id}")
+
+	// Parse UUID
+	attachmentID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.DeleteAttachment(attachmentID); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error deleting attachment")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully deleted attachment")
+	w.WriteHeader(http.StatusNoContent)
+}
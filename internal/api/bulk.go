@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/nonaxanon/vault-inator/internal/services"
+	"github.com/nonaxanon/vault-inator/internal/storage"
+)
+
+// handleBulk handles the POST request to apply one operation to many password entries.
+// The batch is all-or-nothing: if any item fails nothing changes and the response is
+// 409 Conflict with the per-item results.
+func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received POST request to /api/passwords/bulk")
+	var req services.BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.passwordService.ApplyBulk(req)
+	if errors.Is(err, storage.ErrBatchFailed) {
+		s.logger.WithError(err).WithField("operation", req.Operation).Error("Bulk operation rolled back")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("operation", req.Operation).Error("Error applying bulk operation")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("operation", req.Operation).WithField("count", len(result.Results)).Info("Successfully applied bulk operation")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
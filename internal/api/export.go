@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/nonaxanon/vault-inator/internal/exchange"
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+// handleExport handles the GET request to export the vault for another password manager,
+// or as a backup with format=vault or format=age. The format query parameter selects the
+// format, bitwarden by default. The kdbx and vault formats are protected with the
+// passphrase in the X-Export-Passphrase header and age backups are encrypted to the public
+// keys given as repeated recipient query parameters; other formats hold every secret in
+// the clear.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exchange.FormatBitwarden
+	}
+
+	s.logger.WithField("format", format).Info("Received GET request to /api/export")
+
+	exporter, err := exchange.LookupExporter(format)
+	if err != nil {
+		s.logger.WithError(err).Error("Error exporting vault")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	vault, err := s.passwordService.ExportVault(services.ExportOptions{Attachments: exporter.Attachments})
+	if err != nil {
+		s.logger.WithError(err).Error("Error exporting vault")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	// The export is written to memory first so that a failure can still be reported
+	var buf bytes.Buffer
+	if err := exporter.Write(&buf, vault, exchange.Options{
+		Passphrase: r.Header.Get("X-Export-Passphrase"),
+		Recipients: r.URL.Query()["recipient"],
+	}); err != nil {
+		s.logger.WithError(err).WithField("format", format).Error("Error writing export")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"format":    format,
+		"folders":   len(vault.Folders),
+		"passwords": len(vault.Passwords),
+	}).Info("Successfully exported vault")
+	w.Header().Set("Content-Type", exporter.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": exporter.FileName}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// handleGetFolders handles the GET request to list all folders.
+func (s *Server) handleGetFolders(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received GET request to /api/folders")
+	folders, err := s.passwordService.GetAllFolders()
+	if err != nil {
+		s.logger.WithError(err).Error("Error fetching folders")
+		http.Error(w, fmt.Sprintf("Failed to get folders: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.WithField("count", len(folders)).Info("Successfully fetched folders")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(folders)
+}
+
+// handleCreateFolder handles the POST request to create a folder.
+func (s *Server) handleCreateFolder(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received POST request to /api/folders")
+	var req struct {
+		Name     string     `json:"name"`
+		ParentID *uuid.UUID `json:"parent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	folder, err := s.passwordService.CreateFolder(req.Name, req.ParentID)
+	if err != nil {
+		s.logger.WithError(err).Error("Error creating folder")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", folder.ID).Info("Successfully created folder")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(folder)
+}
+
+// handleGetFolder handles the GET request to retrieve a folder by ID.
+func (s *Server) handleGetFolder(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received GET request to /api/folders/{id}")
+
+	// Parse UUID
+	uuid, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	folder, err := s.passwordService.GetFolder(uuid)
+	if err != nil {
+		s.logger.WithError(err).Error("Error fetching folder")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully fetched folder")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(folder)
+}
+
+// handleRenameFolder handles the PUT request to rename a folder.
+func (s *Server) handleRenameFolder(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received PUT request to /api/folders/{id}")
+
+	// Parse UUID
+	folderID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.RenameFolder(folderID, req.Name); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error renaming folder")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully renamed folder")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Folder renamed"})
+}
+
+// handleMoveFolder handles the POST request to move a folder under a new parent.
+// A null parent_id moves the folder to the root.
+func (s *Server) handleMoveFolder(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received POST request to /api/folders/{id}/move")
+
+	// Parse UUID
+	folderID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ParentID *uuid.UUID `json:"parent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.MoveFolder(folderID, req.ParentID); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error moving folder")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully moved folder")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Folder moved"})
+}
+
+// handleDeleteFolder handles the DELETE request to remove a folder by ID.
+// Its subfolders and entries are moved up to the folder's parent.
+func (s *Server) handleDeleteFolder(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received DELETE request to /api/folders/{id}")
+
+	// Parse UUID
+	uuid, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.DeleteFolder(uuid); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error deleting folder")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully deleted folder")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMovePassword handles the POST request to file a password entry into a folder.
+// A null folder_id moves the entry to the root.
+func (s *Server) handleMovePassword(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received POST request to /api/passwords/{id}/move")
+
+	// Parse UUID
+	passwordID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		FolderID *uuid.UUID `json:"folder_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.MovePassword(passwordID, req.FolderID); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error moving password")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully moved password entry")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password moved"})
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+// handleGenerate handles the POST request to generate a password or passphrase. The body
+// holds GeneratorOptions; omitted settings keep their defaults and an empty body generates
+// 20 characters from every class. With profile_id, or with the url of a site matching a
+// generator profile, the profile's rules are used instead. The response holds the password
+// and its entropy in bits.
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received POST request to /api/generate")
+
+	req := services.GenerateRequest{GeneratorOptions: services.DefaultGeneratorOptions()}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	generated, err := s.passwordService.Generate(req)
+	if err != nil {
+		s.logger.WithError(err).Error("Error generating password")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"mode":    req.Mode,
+		"profile": generated.ProfileName,
+		"entropy": generated.Entropy,
+	}).Info("Successfully generated password")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(generated)
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// handleGetPasswordHistory handles the GET request to list the earlier passwords of an entry.
+func (s *Server) handleGetPasswordHistory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received GET request to /api/passwords/{id}/history")
+
+	// Parse UUID
+	passwordID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	history, err := s.passwordService.GetPasswordHistory(passwordID)
+	if err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error fetching password history")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).WithField("count", len(history)).Info("Successfully fetched password history")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/nonaxanon/vault-inator/internal/exchange"
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+// handleImport handles the POST request to import entries from another password manager.
+// The request body is the exported file. Query parameters:
+//
+//	format=<format>    csv (default) for browser exports, bitwarden for Bitwarden JSON,
+//	                   kdbx for KeePass databases, 1pux for 1Password archives,
+//	                   lastpass for LastPass CSV exports
+//	dry_run=true       report what would be created, merged or skipped without saving
+//
+// Encrypted files are opened with the passphrase in the X-Import-Passphrase header.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received POST request to /api/import")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exchange.FormatBrowserCSV
+	}
+	var opts services.ImportOptions
+	if dryRun := r.URL.Query().Get("dry_run"); dryRun != "" {
+		value, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			http.Error(w, "Invalid dry_run value", http.StatusBadRequest)
+			return
+		}
+		opts.DryRun = value
+	}
+
+	body := http.MaxBytesReader(w, r.Body, exchange.MaxImportSize)
+	records, err := exchange.Parse(format, body, exchange.Options{Passphrase: r.Header.Get("X-Import-Passphrase")})
+	if err != nil {
+		s.logger.WithError(err).WithField("format", format).Error("Error parsing import file")
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Import file too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	report, err := s.passwordService.ImportPasswords(records, opts)
+	if err != nil {
+		s.logger.WithError(err).Error("Error importing passwords")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"format":  format,
+		"dry_run": report.DryRun,
+		"created": report.Created,
+		"merged":  report.Merged,
+		"skipped": report.Skipped,
+	}).Info("Successfully imported passwords")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// equivalentDomainsRequest is the body of a request creating or replacing a group of
+// equivalent domains
+type equivalentDomainsRequest struct {
+	Domains []string `json:"domains"`
+}
+
+// handleMatchURL handles the GET request to list the login entries matching the page given
+// by the url query parameter, most specific matches first.
+func (s *Server) handleMatchURL(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received GET request to /api/match")
+	pageURL := r.URL.Query().Get("url")
+	if pageURL == "" {
+		http.Error(w, "url is required", http.StatusBadRequest)
+		return
+	}
+
+	matches, err := s.passwordService.MatchURL(pageURL)
+	if err != nil {
+		s.logger.WithError(err).Error("Error matching URL")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("count", len(matches)).Info("Successfully matched URL")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(matches)
+}
+
+// handleGetEquivalentDomains handles the GET request to list the groups of equivalent domains.
+func (s *Server) handleGetEquivalentDomains(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received GET request to /api/match/equivalent-domains")
+	groups, err := s.passwordService.GetEquivalentDomains()
+	if err != nil {
+		s.logger.WithError(err).Error("Error fetching equivalent domains")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("count", len(groups)).Info("Successfully fetched equivalent domains")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groups)
+}
+
+// handleCreateEquivalentDomains handles the POST request to create a group of equivalent domains.
+func (s *Server) handleCreateEquivalentDomains(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received POST request to /api/match/equivalent-domains")
+	var req equivalentDomainsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	group, err := s.passwordService.CreateEquivalentDomains(req.Domains)
+	if err != nil {
+		s.logger.WithError(err).Error("Error creating equivalent domains")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", group.ID).Info("Successfully created equivalent domains")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(group)
+}
+
+// handleUpdateEquivalentDomains handles the PUT request to replace the domains of a group.
+func (s *Server) handleUpdateEquivalentDomains(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received PUT request to /api/match/equivalent-domains/{id}")
+
+	// Parse UUID
+	groupID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req equivalentDomainsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.UpdateEquivalentDomains(groupID, req.Domains); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error updating equivalent domains")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully updated equivalent domains")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteEquivalentDomains handles the DELETE request to remove a group of equivalent domains.
+func (s *Server) handleDeleteEquivalentDomains(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received DELETE request to /api/match/equivalent-domains/{id}")
+
+	// Parse UUID
+	groupID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.DeleteEquivalentDomains(groupID); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error deleting equivalent domains")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully deleted equivalent domains")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+// handleGetGeneratorProfiles handles the GET request to list all generator profiles.
+func (s *Server) handleGetGeneratorProfiles(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received GET request to /api/generator/profiles")
+	profiles, err := s.passwordService.GetGeneratorProfiles()
+	if err != nil {
+		s.logger.WithError(err).Error("Error fetching generator profiles")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("count", len(profiles)).Info("Successfully fetched generator profiles")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profiles)
+}
+
+// handleCreateGeneratorProfile handles the POST request to create a generator profile.
+// Omitted options keep the generator defaults.
+func (s *Server) handleCreateGeneratorProfile(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received POST request to /api/generator/profiles")
+	profile := services.GeneratorProfile{Options: services.DefaultGeneratorOptions()}
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := s.passwordService.CreateGeneratorProfile(profile)
+	if err != nil {
+		s.logger.WithError(err).Error("Error creating generator profile")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", created.ID).Info("Successfully created generator profile")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// handleGetGeneratorProfile handles the GET request to retrieve a generator profile by ID.
+func (s *Server) handleGetGeneratorProfile(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received GET request to /api/generator/profiles/{id}")
+
+	// Parse UUID
+	profileID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := s.passwordService.GetGeneratorProfile(profileID)
+	if err != nil {
+		s.logger.WithError(err).Error("Error fetching generator profile")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully fetched generator profile")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// handleUpdateGeneratorProfile handles the PUT request to replace a generator profile.
+func (s *Server) handleUpdateGeneratorProfile(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received PUT request to /api/generator/profiles/{id}")
+
+	// Parse UUID
+	profileID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	profile := services.GeneratorProfile{Options: services.DefaultGeneratorOptions()}
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := s.passwordService.UpdateGeneratorProfile(profileID, profile)
+	if err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error updating generator profile")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully updated generator profile")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// handleDeleteGeneratorProfile handles the DELETE request to remove a generator profile.
+func (s *Server) handleDeleteGeneratorProfile(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received DELETE request to /api/generator/profiles/{id}")
+
+	// Parse UUID
+	profileID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.DeleteGeneratorProfile(profileID); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error deleting generator profile")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully deleted generator profile")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+// handleHealthReport handles the GET request for the vault health report: reused, weak,
+// breached and old passwords, duplicate logins, http:// URLs and entries missing a URL or username.
+// Reused passwords are grouped by a keyed hash and no password is ever returned. The
+// max_age_days query parameter sets when a password counts as old, 365 days by default.
+func (s *Server) handleHealthReport(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received GET request to /api/reports/health")
+
+	var opts services.HealthOptions
+	if v := r.URL.Query().Get("max_age_days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			http.Error(w, "Invalid max_age_days", http.StatusBadRequest)
+			return
+		}
+		opts.MaxPasswordAge = time.Duration(days) * 24 * time.Hour
+	}
+
+	report, err := s.passwordService.HealthReport(opts)
+	if err != nil {
+		s.logger.WithError(err).Error("Error building health report")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"score":    report.Score,
+		"checked":  report.CheckedEntries,
+		"reused":   len(report.Reused),
+		"weak":     len(report.Weak),
+		"breached": len(report.Breached),
+		"old":      len(report.Old),
+	}).Info("Successfully built health report")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nonaxanon/vault-inator/internal/age"
+	"github.com/nonaxanon/vault-inator/internal/exchange"
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+// handleRestore handles the POST request to restore a backup written by the vault or age
+// export format. The request body is the backup, opened with the passphrase in the
+// X-Restore-Passphrase header or, for age backups, the comma-separated identities
+// (AGE-SECRET-KEY-1...) in the X-Restore-Identity header. Query parameters:
+//
+//	mode=merge|replace  merge (default) keeps the newer version of entries in both; replace
+//	                    deletes every folder and entry first
+//	dry_run=true        report what would change without saving
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received POST request to /api/restore")
+
+	opts := services.RestoreOptions{Mode: r.URL.Query().Get("mode")}
+	if dryRun := r.URL.Query().Get("dry_run"); dryRun != "" {
+		value, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			http.Error(w, "Invalid dry_run value", http.StatusBadRequest)
+			return
+		}
+		opts.DryRun = value
+	}
+
+	var identities []*age.Identity
+	if header := r.Header.Get("X-Restore-Identity"); header != "" {
+		parsed, err := age.ParseIdentities(strings.NewReader(strings.ReplaceAll(header, ",", "\n")))
+		if err != nil {
+			s.logger.WithError(err).Error("Error reading backup")
+			http.Error(w, err.Error(), statusForError(err))
+			return
+		}
+		identities = parsed
+	}
+
+	body := http.MaxBytesReader(w, r.Body, exchange.MaxArchiveSize)
+	vault, err := exchange.OpenArchive(body, r.Header.Get("X-Restore-Passphrase"), identities)
+	if err != nil {
+		s.logger.WithError(err).Error("Error reading backup")
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Backup too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	report, err := s.passwordService.RestoreVault(vault, opts)
+	if err != nil {
+		s.logger.WithError(err).Error("Error restoring vault")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"mode":      report.Mode,
+		"dry_run":   report.DryRun,
+		"created":   report.Created,
+		"updated":   report.Updated,
+		"unchanged": report.Unchanged,
+		"removed":   report.Removed,
+	}).Info("Successfully restored vault")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// handleSetFolderRotation handles the PUT request to set the rotation interval, in days,
+// inherited by entries in a folder and its subfolders. 0 removes the interval.
+func (s *Server) handleSetFolderRotation(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received PUT request to /api/folders/{id}/rotation")
+
+	// Parse UUID
+	folderID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		RotationDays int `json:"rotation_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.SetFolderRotation(folderID, req.RotationDays); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error setting folder rotation")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"id":            id,
+		"rotation_days": req.RotationDays,
+	}).Info("Successfully set folder rotation")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Folder rotation set"})
+}
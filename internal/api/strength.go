@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/nonaxanon/vault-inator/internal/services"
+	"github.com/nonaxanon/vault-inator/internal/strength"
+)
+
+// passwordResponse is a created or updated entry with the estimated strength of its
+// password and how often it appears in known breaches. Both are omitted for entries
+// without a password, and the breach count when no breach dataset is configured.
+type passwordResponse struct {
+	services.Password
+	Strength    *strength.Result `json:"strength,omitempty"`
+	BreachCount *int             `json:"breach_count,omitempty"`
+}
+
+func (s *Server) newPasswordResponse(password services.Password) passwordResponse {
+	resp := passwordResponse{Password: password, Strength: services.EstimateStrength(&password)}
+	// The entry is saved, so a failed breach check only leaves the count out
+	count, err := s.passwordService.BreachCount(password.Password)
+	if err != nil {
+		s.logger.WithError(err).Error("Error checking breaches")
+	}
+	resp.BreachCount = count
+	return resp
+}
+
+// handleEstimateStrength handles the POST request to estimate the strength of a password
+// before it is saved. The body holds the password and optionally the entry's title and
+// username; the response holds the score, from 0 to 4, the estimated guesses, feedback,
+// the minimum score required by the server, 0 when there is no policy, and the breach
+// count when a breach dataset is configured.
+func (s *Server) handleEstimateStrength(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received POST request to /api/strength")
+
+	var req struct {
+		Password string `json:"password"`
+		Title    string `json:"title"`
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result := strength.Estimate(req.Password, strength.SplitUserInputs(req.Title, req.Username)...)
+	count, err := s.passwordService.BreachCount(req.Password)
+	if err != nil {
+		s.logger.WithError(err).Error("Error checking breaches")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	resp := struct {
+		strength.Result
+		MinScore    int  `json:"min_score"`
+		BreachCount *int `json:"breach_count,omitempty"`
+	}{result, s.passwordService.MinStrength(), count}
+
+	s.logger.WithField("score", result.Score).Info("Successfully estimated password strength")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// handleGetTags handles the GET request to list all tags with their entry counts.
+func (s *Server) handleGetTags(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received GET request to /api/tags")
+	tags, err := s.passwordService.GetTagCounts()
+	if err != nil {
+		s.logger.WithError(err).Error("Error fetching tags")
+		http.Error(w, fmt.Sprintf("Failed to get tags: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.WithField("count", len(tags)).Info("Successfully fetched tags")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// handleSetPasswordTags handles the PUT request to replace the tags of a password entry.
+func (s *Server) handleSetPasswordTags(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received PUT request to /api/passwords/{id}/tags")
+
+	// Parse UUID
+	passwordID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.SetPasswordTags(passwordID, req.Tags); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error setting tags")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully updated password tags")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Tags updated"})
+}
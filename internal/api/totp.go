@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// handleGetTOTP handles the GET request for the current TOTP code of an entry, which comes
+// with the seconds it remains valid. HOTP codes are refused, as generating one advances the
+// entry's counter; they are generated by handleGenerateTOTP.
+func (s *Server) handleGetTOTP(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received GET request to /api/passwords/{id}/totp")
+
+	// Parse UUID
+	passwordID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	code, err := s.passwordService.CurrentOTP(passwordID)
+	if err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error generating one-time password")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"id":   id,
+		"type": code.Type,
+	}).Info("Successfully generated one-time password")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(code)
+}
+
+// handleGenerateTOTP handles the POST request for the next one-time password of an entry.
+// Each HOTP request advances the entry's counter; TOTP entries get their current code.
+func (s *Server) handleGenerateTOTP(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received POST request to /api/passwords/{id}/totp")
+
+	// Parse UUID
+	passwordID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	code, err := s.passwordService.GenerateOTP(passwordID)
+	if err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error generating one-time password")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"id":   id,
+		"type": code.Type,
+	}).Info("Successfully generated one-time password")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(code)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// handleGetTrash handles the GET request to list all trashed password entries.
+func (s *Server) handleGetTrash(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received GET request to /api/trash")
+	passwords, err := s.passwordService.GetTrashedPasswords()
+	if err != nil {
+		s.logger.WithError(err).Error("Error fetching trash")
+		http.Error(w, fmt.Sprintf("Failed to get trash: %v", err), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("count", len(passwords)).Info("Successfully fetched trashed entries")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(passwords)
+}
+
+// handleRestorePassword handles the POST request to restore a trashed password entry by ID.
+func (s *Server) handleRestorePassword(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received POST request to /api/trash/{id}/restore")
+
+	// Parse UUID
+	uuid, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.RestorePassword(uuid); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error restoring password")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully restored password entry")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password restored"})
+}
+
+// handlePurgePassword handles the DELETE request to permanently remove a trashed password entry by ID.
+func (s *Server) handlePurgePassword(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received DELETE request to /api/trash/{id}")
+
+	// Parse UUID
+	uuid, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.PurgePassword(uuid); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error purging password")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully purged password entry")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleEmptyTrash handles the DELETE request to permanently remove every trashed password entry.
+func (s *Server) handleEmptyTrash(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Received DELETE request to /api/trash")
+	purged, err := s.passwordService.EmptyTrash()
+	if err != nil {
+		s.logger.WithError(err).Error("Error emptying trash")
+		http.Error(w, fmt.Sprintf("Failed to empty trash: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.WithField("count", purged).Info("Successfully emptied trash")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"purged": purged})
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+// handleSetPasswordFlags handles the PUT request to mark a password entry as a favorite or pinned.
+func (s *Server) handleSetPasswordFlags(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received PUT request to /api/passwords/{id}/flags")
+
+	// Parse UUID
+	passwordID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	var flags services.PasswordFlags
+	if err := json.NewDecoder(r.Body).Decode(&flags); err != nil {
+		s.logger.WithError(err).Error("Error decoding request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.SetPasswordFlags(passwordID, flags); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error setting flags")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully updated password flags")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Flags updated"})
+}
+
+// handleRecordUse handles the POST request the UI sends when an entry's secrets are revealed or copied.
+func (s *Server) handleRecordUse(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	s.logger.WithField("id", id).Info("Received POST request to /api/passwords/{id}/used")
+
+	// Parse UUID
+	passwordID, err := uuid.Parse(id)
+	if err != nil {
+		s.logger.WithError(err).Error("Invalid UUID format")
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.passwordService.RecordUse(passwordID); err != nil {
+		s.logger.WithError(err).WithField("id", id).Error("Error recording use")
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	s.logger.WithField("id", id).Info("Successfully recorded password use")
+	w.WriteHeader(http.StatusNoContent)
+}
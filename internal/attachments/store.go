@@ -0,0 +1,129 @@
+package attachments
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// ErrBlobNotFound is returned when a store has no blob for an attachment ID.
+var ErrBlobNotFound = errors.New("attachment blob not found")
+
+// Store persists encrypted attachment blobs. Implementations never see plaintext.
+type Store interface {
+	// Put stores the blob read from r under id, replacing any existing blob.
+	Put(id uuid.UUID, r io.Reader) error
+	// Get opens the blob stored under id.
+	Get(id uuid.UUID) (io.ReadCloser, error)
+	// Delete removes the blob stored under id. Deleting a missing blob is not an error.
+	Delete(id uuid.UUID) error
+}
+
+// DBStore keeps blobs in the vaultinator.attachment_blobs table.
+type DBStore struct {
+	db *sql.DB
+}
+
+// NewDBStore creates a store backed by the given database connection.
+func NewDBStore(db *sql.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// Put stores the blob in the database. The whole blob is buffered in memory.
+func (s *DBStore) Put(id uuid.UUID, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO vaultinator.attachment_blobs (attachment_id, data)
+	VALUES ($1, $2)
+	ON CONFLICT (attachment_id) DO UPDATE SET data = EXCLUDED.data;`
+	if _, err := s.db.Exec(query, id, data); err != nil {
+		return fmt.Errorf("failed to store attachment blob: %v", err)
+	}
+	return nil
+}
+
+// Get reads the blob from the database.
+func (s *DBStore) Get(id uuid.UUID) (io.ReadCloser, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM vaultinator.attachment_blobs WHERE attachment_id = $1;`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Delete removes the blob from the database.
+func (s *DBStore) Delete(id uuid.UUID) error {
+	_, err := s.db.Exec(`DELETE FROM vaultinator.attachment_blobs WHERE attachment_id = $1;`, id)
+	return err
+}
+
+// FileStore keeps blobs as files in a directory, one file per attachment.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store that writes blobs under dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create attachment directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns the file path of a blob.
+func (s *FileStore) path(id uuid.UUID) string {
+	return filepath.Join(s.dir, id.String()+".bin")
+}
+
+// Put streams the blob to a temporary file and renames it into place.
+func (s *FileStore) Put(id uuid.UUID, r io.Reader) error {
+	tmp, err := os.CreateTemp(s.dir, id.String()+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create attachment file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write attachment file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync attachment file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close attachment file: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path(id))
+}
+
+// Get opens the blob file.
+func (s *FileStore) Get(id uuid.UUID) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return f, err
+}
+
+// Delete removes the blob file.
+func (s *FileStore) Delete(id uuid.UUID) error {
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,278 @@
+// Package breach checks passwords against a local copy of the Have I Been Pwned Pwned
+// Passwords dataset, so no password or hash ever leaves the machine.
+//
+// Two layouts produced by the PwnedPasswordsDownloader are supported: a single file of
+// "HASH:COUNT" lines sorted by hash, searched with a binary search over the memory-mapped
+// file, and a directory of range files named after the first five hex digits of the hash,
+// each holding sorted "SUFFIX:COUNT" lines. Both SHA-1 and NTLM datasets are recognised.
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// ErrInvalidDataset is returned when a file or directory is not a Pwned Passwords dataset
+var ErrInvalidDataset = errors.New("invalid breach dataset")
+
+// Hash types
+const (
+	HashSHA1 = "sha1"
+	HashNTLM = "ntlm"
+)
+
+const (
+	// prefixLength is the number of hex digits naming a range file
+	prefixLength = 5
+	// scanWindow is the span below which the binary search switches to a linear scan
+	scanWindow = 4096
+	// maxLineLength bounds a line, a hash plus a count
+	maxLineLength = 128
+)
+
+// Dataset is an open Pwned Passwords dataset
+type Dataset struct {
+	hashType string
+	// dir is set for a directory of range files
+	dir string
+	// data and size are the sorted single file
+	data   io.ReaderAt
+	size   int64
+	closer func() error
+}
+
+// Open opens the dataset at path, a sorted hash file or a directory of range files
+func Open(path string) (*Dataset, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breach dataset: %w", err)
+	}
+	if info.IsDir() {
+		return openDir(path)
+	}
+	return openFile(path, info.Size())
+}
+
+func openFile(path string, size int64) (*Dataset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breach dataset: %w", err)
+	}
+	data, closer, err := mapFile(f, size)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	first, err := readLine(data, 0, size)
+	if err != nil {
+		closer()
+		return nil, err
+	}
+	hash, _, ok := parseLine(first)
+	if !ok {
+		closer()
+		return nil, fmt.Errorf("%w: %s does not start with a HASH:COUNT line", ErrInvalidDataset, path)
+	}
+	hashType, ok := hashTypeForLength(len(hash))
+	if !ok {
+		closer()
+		return nil, fmt.Errorf("%w: %s holds %d digit hashes", ErrInvalidDataset, path, len(hash))
+	}
+	return &Dataset{hashType: hashType, data: data, size: size, closer: closer}, nil
+}
+
+func openDir(dir string) (*Dataset, error) {
+	f, err := os.Open(filepath.Join(dir, strings.Repeat("0", prefixLength)+".txt"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s has no range file 00000.txt: %v", ErrInvalidDataset, dir, err)
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read breach dataset: %w", err)
+	}
+	suffix, _, ok := parseLine([]byte(line))
+	if !ok {
+		return nil, fmt.Errorf("%w: %s does not hold SUFFIX:COUNT lines", ErrInvalidDataset, dir)
+	}
+	hashType, ok := hashTypeForLength(prefixLength + len(suffix))
+	if !ok {
+		return nil, fmt.Errorf("%w: %s holds %d digit hashes", ErrInvalidDataset, dir, prefixLength+len(suffix))
+	}
+	return &Dataset{hashType: hashType, dir: dir}, nil
+}
+
+func hashTypeForLength(length int) (string, bool) {
+	switch length {
+	case 2 * sha1.Size:
+		return HashSHA1, true
+	case 2 * md4.Size:
+		return HashNTLM, true
+	}
+	return "", false
+}
+
+// HashType returns the hash the dataset is keyed by, HashSHA1 or HashNTLM
+func (d *Dataset) HashType() string {
+	return d.hashType
+}
+
+// Close releases the dataset
+func (d *Dataset) Close() error {
+	if d.closer != nil {
+		return d.closer()
+	}
+	return nil
+}
+
+// Count returns how many times password appears in the breaches of the dataset, 0 when
+// it has not been seen
+func (d *Dataset) Count(password string) (int, error) {
+	hash := Hash(d.hashType, password)
+	if d.dir != "" {
+		return d.countInRange(hash)
+	}
+	return d.search(hash)
+}
+
+// Hash returns the uppercase hex hash of password used by datasets of hashType
+func Hash(hashType, password string) string {
+	if hashType == HashNTLM {
+		h := md4.New()
+		for _, unit := range utf16.Encode([]rune(password)) {
+			h.Write([]byte{byte(unit), byte(unit >> 8)})
+		}
+		return strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+	}
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// countInRange looks up a hash in its range file
+func (d *Dataset) countInRange(hash string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(d.dir, hash[:prefixLength]+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read breach dataset: %w", err)
+	}
+	return scanLines(data, hash[prefixLength:]), nil
+}
+
+// search finds a hash in the sorted file. The binary search keeps lo at the start of a
+// line below the hash and hi at the start of a line at or above it, then scans what is
+// left.
+func (d *Dataset) search(hash string) (int, error) {
+	lo, hi := int64(0), d.size
+	for hi-lo > scanWindow {
+		mid := lo + (hi-lo)/2
+		start, err := d.nextLineStart(mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi {
+			break
+		}
+		line, err := readLine(d.data, start, d.size)
+		if err != nil {
+			return 0, err
+		}
+		lineHash, _, ok := parseLine(line)
+		if !ok {
+			return 0, fmt.Errorf("%w: malformed line at offset %d", ErrInvalidDataset, start)
+		}
+		if strings.ToUpper(string(lineHash)) < hash {
+			lo = start
+		} else {
+			hi = start
+		}
+	}
+
+	end := min(hi+maxLineLength, d.size)
+	buf := make([]byte, end-lo)
+	if _, err := d.data.ReadAt(buf, lo); err != nil && !errors.Is(err, io.EOF) {
+		return 0, fmt.Errorf("failed to read breach dataset: %w", err)
+	}
+	return scanLines(buf, hash), nil
+}
+
+// nextLineStart returns the offset of the first line starting after offset
+func (d *Dataset) nextLineStart(offset int64) (int64, error) {
+	buf := make([]byte, maxLineLength)
+	for {
+		n, err := d.data.ReadAt(buf, offset)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return offset + int64(i) + 1, nil
+		}
+		if errors.Is(err, io.EOF) || n == 0 {
+			return d.size, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read breach dataset: %w", err)
+		}
+		offset += int64(n)
+	}
+}
+
+// readLine reads the line starting at offset
+func readLine(data io.ReaderAt, offset, size int64) ([]byte, error) {
+	buf := make([]byte, min(maxLineLength, size-offset))
+	n, err := data.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read breach dataset: %w", err)
+	}
+	line, _, _ := bytes.Cut(buf[:n], []byte("\n"))
+	return line, nil
+}
+
+// scanLines returns the count on the line for hash among sorted lines, 0 when it is absent
+func scanLines(data []byte, hash string) int {
+	for len(data) > 0 {
+		var line []byte
+		line, data, _ = bytes.Cut(data, []byte("\n"))
+		lineHash, count, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		switch h := strings.ToUpper(string(lineHash)); {
+		case h == hash:
+			return count
+		case h > hash:
+			return 0
+		}
+	}
+	return 0
+}
+
+// parseLine splits a "HASH:COUNT" line
+func parseLine(line []byte) ([]byte, int, bool) {
+	hash, rawCount, ok := bytes.Cut(bytes.TrimSpace(line), []byte(":"))
+	if !ok || len(hash) == 0 {
+		return nil, 0, false
+	}
+	for _, c := range hash {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", rune(c)) {
+			return nil, 0, false
+		}
+	}
+	count, err := strconv.Atoi(string(rawCount))
+	if err != nil {
+		return nil, 0, false
+	}
+	return hash, count, true
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package breach
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// mapFile reads a dataset file in place where memory-mapping is unavailable
+func mapFile(f *os.File, size int64) (io.ReaderAt, func() error, error) {
+	if size == 0 {
+		return nil, nil, fmt.Errorf("%w: %s is empty", ErrInvalidDataset, f.Name())
+	}
+	return f, f.Close, nil
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package breach
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// mapFile memory-maps a dataset file read-only, so lookups only page in the parts of
+// the file the binary search touches
+func mapFile(f *os.File, size int64) (io.ReaderAt, func() error, error) {
+	if size == 0 {
+		return nil, nil, fmt.Errorf("%w: %s is empty", ErrInvalidDataset, f.Name())
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to map breach dataset: %w", err)
+	}
+	closer := func() error {
+		defer f.Close()
+		return syscall.Munmap(data)
+	}
+	return bytes.NewReader(data), closer, nil
+}
@@ -5,11 +5,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+const (
+	// DefaultTrashRetentionDays is how long trashed entries are kept when TRASH_RETENTION_DAYS is not set
+	DefaultTrashRetentionDays = 30
+	// DefaultMaxAttachmentSize is the upload limit used when ATTACHMENT_MAX_BYTES is not set
+	DefaultMaxAttachmentSize = 25 << 20
+	// DefaultRotationReminderDays is how early a rotation is reported as due when ROTATION_REMINDER_DAYS is not set
+	DefaultRotationReminderDays = 7
+)
+
 var (
 	config     *Config
 	configOnce sync.Once
@@ -21,6 +32,27 @@ type Config struct {
 	MasterPasswordHash string `json:"master_password_hash"`
 	Salt               string `json:"salt"`
 	MasterPassword     string `json:"-"` // Not stored in config file
+
+	// TrashRetention is how long trashed entries are kept before being purged
+	TrashRetention time.Duration `json:"-"`
+
+	// AttachmentStore selects where attachment blobs are kept: "db" or "file"
+	AttachmentStore string `json:"-"`
+	// AttachmentDir is the directory used by the file attachment store
+	AttachmentDir string `json:"-"`
+	// MaxAttachmentSize is the largest accepted attachment upload in bytes
+	MaxAttachmentSize int64 `json:"-"`
+
+	// MinPasswordStrength is the strength score, 1 to 4, that new and changed passwords
+	// must reach; 0 turns the policy off
+	MinPasswordStrength int `json:"-"`
+
+	// BreachDataset is the path of a local Pwned Passwords dataset, a sorted hash file or
+	// a directory of range files; empty turns breach checks off
+	BreachDataset string `json:"-"`
+
+	// RotationReminder is how long before its due date a password rotation is reported as due
+	RotationReminder time.Duration `json:"-"`
 }
 
 // GetConfig returns the singleton config instance
@@ -77,6 +109,62 @@ func LoadConfig() (*Config, error) {
 		fmt.Println("Info: MASTER_PASSWORD not set, will be initialized through UI")
 	}
 
+	// Trash retention in days
+	retentionDays := DefaultTrashRetentionDays
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("invalid TRASH_RETENTION_DAYS %q", v)
+		}
+		retentionDays = days
+	}
+	config.TrashRetention = time.Duration(retentionDays) * 24 * time.Hour
+
+	// Attachment storage
+	config.AttachmentStore = os.Getenv("ATTACHMENT_STORE")
+	switch config.AttachmentStore {
+	case "":
+		config.AttachmentStore = "db"
+	case "db", "file":
+	default:
+		return nil, fmt.Errorf("invalid ATTACHMENT_STORE %q, expected db or file", config.AttachmentStore)
+	}
+	config.AttachmentDir = os.Getenv("ATTACHMENT_DIR")
+	if config.AttachmentDir == "" {
+		config.AttachmentDir = filepath.Join(filepath.Dir(configPath), "attachments")
+	}
+	config.MaxAttachmentSize = DefaultMaxAttachmentSize
+	if v := os.Getenv("ATTACHMENT_MAX_BYTES"); v != "" {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid ATTACHMENT_MAX_BYTES %q", v)
+		}
+		config.MaxAttachmentSize = size
+	}
+
+	// Minimum password strength policy
+	if v := os.Getenv("MIN_PASSWORD_STRENGTH"); v != "" {
+		score, err := strconv.Atoi(v)
+		if err != nil || score < 0 || score > 4 {
+			return nil, fmt.Errorf("invalid MIN_PASSWORD_STRENGTH %q, expected 0 to 4", v)
+		}
+		config.MinPasswordStrength = score
+	}
+
+	// Local breach dataset
+	config.BreachDataset = os.Getenv("BREACH_DATASET")
+
+	// Rotation reminder window in days
+	reminderDays := DefaultRotationReminderDays
+	if v := os.Getenv("ROTATION_REMINDER_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("invalid ROTATION_REMINDER_DAYS %q", v)
+		}
+		reminderDays = days
+	}
+	config.RotationReminder = time.Duration(reminderDays) * 24 * time.Hour
+
 	return config, nil
 }
 
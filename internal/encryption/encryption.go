@@ -3,10 +3,15 @@ package encryption
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"io"
+
+	"golang.org/x/crypto/hkdf"
 )
 
 // Encryptor handles encryption and decryption of sensitive data
@@ -82,3 +87,18 @@ func (e *Encryptor) Decrypt(ciphertext string) (string, error) {
 
 	return string(plaintext), nil
 }
+
+// BlindIndex returns a deterministic keyed hash of value that can be stored next to its
+// ciphertext and compared for equality without revealing the plaintext.
+// The HMAC key is derived from the encryption key so the two are never used interchangeably.
+func (e *Encryptor) BlindIndex(value string) string {
+	indexKey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, e.key, nil, []byte("vaultinator blind index")), indexKey); err != nil {
+		// hkdf only fails when more than 255 blocks are requested
+		panic(err)
+	}
+
+	mac := hmac.New(sha256.New, indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
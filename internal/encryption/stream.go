@@ -0,0 +1,160 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// StreamChunkSize is the amount of plaintext sealed in each chunk of an encrypted stream.
+const StreamChunkSize = 64 * 1024
+
+const (
+	streamVersion     = 1
+	streamNoncePrefix = 7
+	streamHeaderSize  = 1 + streamNoncePrefix
+)
+
+var (
+	// ErrStreamTruncated is returned when an encrypted stream ends before its final chunk.
+	ErrStreamTruncated = errors.New("encrypted stream is truncated")
+	// ErrStreamVersion is returned when an encrypted stream has an unknown header.
+	ErrStreamVersion = errors.New("unsupported encrypted stream version")
+)
+
+// NewStreamKey returns a random 32-byte key for EncryptStream.
+func NewStreamKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// streamNonce builds the nonce of a chunk: the random prefix, a big-endian chunk counter
+// and a flag marking the final chunk, so chunks cannot be reordered, dropped or truncated.
+func streamNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[streamNoncePrefix:], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// EncryptStream reads plaintext from src and writes it to dst as a sequence of
+// AES-256-GCM sealed chunks. It returns the number of plaintext bytes read.
+func EncryptStream(dst io.Writer, src io.Reader, key []byte) (int64, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return 0, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, streamHeaderSize)
+	header[0] = streamVersion
+	if _, err := io.ReadFull(rand.Reader, header[1:]); err != nil {
+		return 0, err
+	}
+	if _, err := dst.Write(header); err != nil {
+		return 0, err
+	}
+	prefix := header[1:]
+
+	reader := bufio.NewReaderSize(src, StreamChunkSize+1)
+	buf := make([]byte, StreamChunkSize)
+	var total int64
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(reader, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return total, err
+		}
+		total += int64(n)
+
+		// The chunk is final when nothing follows it
+		_, peekErr := reader.Peek(1)
+		last := peekErr == io.EOF
+		if peekErr != nil && peekErr != io.EOF {
+			return total, peekErr
+		}
+
+		if counter == ^uint32(0) && !last {
+			return total, errors.New("encrypted stream is too long")
+		}
+
+		sealed := gcm.Seal(nil, streamNonce(prefix, counter, last), buf[:n], nil)
+		if _, err := dst.Write(sealed); err != nil {
+			return total, err
+		}
+		if last {
+			return total, nil
+		}
+	}
+}
+
+// DecryptStream reads a stream written by EncryptStream from src and writes the plaintext
+// to dst. Chunks are authenticated before they are written, but a failure part way through
+// leaves the preceding plaintext in dst.
+func DecryptStream(dst io.Writer, src io.Reader, key []byte) (int64, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return 0, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return 0, ErrStreamTruncated
+	}
+	if header[0] != streamVersion {
+		return 0, ErrStreamVersion
+	}
+	prefix := header[1:]
+
+	chunkSize := StreamChunkSize + gcm.Overhead()
+	reader := bufio.NewReaderSize(src, chunkSize+1)
+	buf := make([]byte, chunkSize)
+	var total int64
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(reader, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return total, err
+		}
+		if n < gcm.Overhead() {
+			return total, ErrStreamTruncated
+		}
+
+		_, peekErr := reader.Peek(1)
+		last := peekErr == io.EOF
+		if peekErr != nil && peekErr != io.EOF {
+			return total, peekErr
+		}
+
+		plaintext, err := gcm.Open(nil, streamNonce(prefix, counter, last), buf[:n], nil)
+		if err != nil {
+			if last {
+				// A final chunk that only opens as a middle chunk means the stream was cut short
+				return total, ErrStreamTruncated
+			}
+			return total, err
+		}
+		if _, err := dst.Write(plaintext); err != nil {
+			return total, err
+		}
+		total += int64(len(plaintext))
+		if last {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package exchange
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/age"
+	"github.com/nonaxanon/vault-inator/internal/encryption"
+	"github.com/nonaxanon/vault-inator/internal/services"
+	"golang.org/x/crypto/argon2"
+)
+
+// MaxArchiveSize is the largest backup archive accepted for restore, in bytes
+const MaxArchiveSize = 1 << 30
+
+// archiveMagic starts every backup archive
+const archiveMagic = "VIBACKUP"
+
+const archiveVersion = 1
+
+// Argon2id settings used when writing, and the limits accepted when reading so that a
+// hostile archive cannot exhaust the server. The limits leave room for stronger settings
+// in later versions without letting a restore cost much more than a backup.
+const (
+	archiveArgon2Time    = 3
+	archiveArgon2Memory  = 64 << 10 // KiB
+	archiveArgon2Threads = 4
+
+	maxArchiveArgon2Time    = 2 * archiveArgon2Time
+	maxArchiveArgon2Memory  = 2 * archiveArgon2Memory
+	maxArchiveArgon2Threads = 4 * archiveArgon2Threads
+)
+
+// maxArchiveContent limits the size of the decompressed archive content
+const maxArchiveContent = 2 << 30
+
+// archiveHeader precedes the encrypted content. The key derivation settings and salt
+// determine the key, so tampering with them makes the content fail to decrypt.
+type archiveHeader struct {
+	Magic   [8]byte
+	Version uint8
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	Salt    [16]byte
+}
+
+// archiveDocument is the content of a backup archive
+type archiveDocument struct {
+	Version     int                                         `json:"version"`
+	CreatedAt   time.Time                                   `json:"created_at"`
+	Folders     []services.Folder                           `json:"folders"`
+	Passwords   []services.Password                         `json:"passwords"`
+	History     map[uuid.UUID][]services.PasswordHistory    `json:"history"`
+	Attachments map[uuid.UUID][]services.ExportedAttachment `json:"attachments"`
+}
+
+// key derives the content key from the passphrase with the header's Argon2id settings
+func (h archiveHeader) key(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), h.Salt[:], h.Time, h.Memory, h.Threads, 32)
+}
+
+// WriteArchive writes the vault as an encrypted Vault-inator backup: every folder, entry,
+// attachment and earlier password, with their IDs and timestamps. The key is derived from
+// passphrase with Argon2id and the gzip-compressed JSON content is sealed with
+// AES-256-GCM in authenticated chunks.
+func WriteArchive(w io.Writer, vault services.VaultExport, passphrase string) error {
+	if passphrase == "" {
+		return ErrPassphraseRequired
+	}
+
+	header := archiveHeader{
+		Version: archiveVersion,
+		Time:    archiveArgon2Time,
+		Memory:  archiveArgon2Memory,
+		Threads: archiveArgon2Threads,
+	}
+	copy(header.Magic[:], archiveMagic)
+	if _, err := rand.Read(header.Salt[:]); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	content, err := encodeArchive(vault)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	_, err = encryption.EncryptStream(w, content, header.key(passphrase))
+	return err
+}
+
+// WriteAgeArchive writes the same content as WriteArchive encrypted to age X25519
+// recipients (age1... public keys) instead of a passphrase. Only the holders of the
+// matching identities can open it, here or with "age -d -i key.txt | gunzip".
+func WriteAgeArchive(w io.Writer, vault services.VaultExport, recipients []string) error {
+	if len(recipients) == 0 {
+		return age.ErrNoRecipients
+	}
+	parsed := make([]*age.Recipient, 0, len(recipients))
+	for _, recipient := range recipients {
+		r, err := age.ParseRecipient(recipient)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, r)
+	}
+
+	content, err := encodeArchive(vault)
+	if err != nil {
+		return err
+	}
+	encrypted, err := age.Encrypt(w, parsed...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(encrypted, content); err != nil {
+		return err
+	}
+	return encrypted.Close()
+}
+
+// OpenArchive reads a backup written by WriteArchive or WriteAgeArchive, telling them
+// apart by their header. Passphrase backups need passphrase and age backups identities.
+func OpenArchive(r io.Reader, passphrase string, identities []*age.Identity) (services.VaultExport, error) {
+	br := bufio.NewReader(r)
+	// Peek returns what it has when the input is shorter
+	if start, _ := br.Peek(64); !age.IsAge(start) {
+		return ReadArchive(br, passphrase)
+	}
+	return ReadAgeArchive(br, identities)
+}
+
+// ReadAgeArchive decrypts a backup written by WriteAgeArchive with the first identity
+// that matches one of its recipients.
+func ReadAgeArchive(r io.Reader, identities []*age.Identity) (services.VaultExport, error) {
+	if len(identities) == 0 {
+		return services.VaultExport{}, ErrIdentityRequired
+	}
+	content, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return services.VaultExport{}, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	// The payload is authenticated chunk by chunk while it is decompressed
+	vault, err := decodeArchive(content)
+	if err != nil {
+		return services.VaultExport{}, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	return vault, nil
+}
+
+// ReadArchive decrypts a backup written by WriteArchive. A wrong passphrase and a damaged
+// archive are reported alike, as ErrInvalidImport.
+func ReadArchive(r io.Reader, passphrase string) (services.VaultExport, error) {
+	if passphrase == "" {
+		return services.VaultExport{}, ErrPassphraseRequired
+	}
+
+	var header archiveHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil || string(header.Magic[:]) != archiveMagic {
+		return services.VaultExport{}, fmt.Errorf("%w: not a Vault-inator backup", ErrInvalidImport)
+	}
+	if header.Version != archiveVersion {
+		return services.VaultExport{}, fmt.Errorf("%w: unsupported backup version %d", ErrInvalidImport, header.Version)
+	}
+	if header.Time < 1 || header.Time > maxArchiveArgon2Time ||
+		header.Threads < 1 || header.Threads > maxArchiveArgon2Threads ||
+		header.Memory < 8*uint32(header.Threads) || header.Memory > maxArchiveArgon2Memory {
+		return services.VaultExport{}, fmt.Errorf("%w: unsupported key derivation settings", ErrInvalidImport)
+	}
+
+	// The content is decoded while it is decrypted rather than buffered first; every chunk
+	// is authenticated before it reaches the decoder
+	key := header.key(passphrase)
+	content, decrypted := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := encryption.DecryptStream(decrypted, r, key)
+		decrypted.CloseWithError(err)
+		done <- err
+	}()
+	vault, err := decodeArchive(content)
+	// Closing the pipe stops the decryption when decoding gave up early
+	content.Close()
+	if decryptErr := <-done; decryptErr != nil && !errors.Is(decryptErr, io.ErrClosedPipe) {
+		return services.VaultExport{}, fmt.Errorf("%w: wrong passphrase or damaged backup: %w", ErrInvalidImport, decryptErr)
+	}
+	if err != nil {
+		return services.VaultExport{}, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	return vault, nil
+}
+
+// encodeArchive returns the gzip-compressed JSON content of a backup
+func encodeArchive(vault services.VaultExport) (*bytes.Buffer, error) {
+	document := archiveDocument{
+		Version:     archiveVersion,
+		CreatedAt:   time.Now().UTC(),
+		Folders:     vault.Folders,
+		Passwords:   vault.Passwords,
+		History:     vault.History,
+		Attachments: vault.Attachments,
+	}
+	var content bytes.Buffer
+	gz := gzip.NewWriter(&content)
+	if err := json.NewEncoder(gz).Encode(document); err != nil {
+		return nil, fmt.Errorf("failed to encode backup: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// decodeArchive reads the decrypted content of a backup
+func decodeArchive(r io.Reader) (services.VaultExport, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return services.VaultExport{}, err
+	}
+	defer gz.Close()
+	var document archiveDocument
+	if err := json.NewDecoder(io.LimitReader(gz, maxArchiveContent)).Decode(&document); err != nil {
+		return services.VaultExport{}, err
+	}
+	// Reading to the end verifies the gzip checksum and authenticates the final chunk of
+	// the encrypted stream
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return services.VaultExport{}, err
+	}
+	if document.Version != archiveVersion {
+		return services.VaultExport{}, fmt.Errorf("unsupported backup version %d", document.Version)
+	}
+	return services.VaultExport{
+		Folders:     document.Folders,
+		Passwords:   document.Passwords,
+		History:     document.History,
+		Attachments: document.Attachments,
+	}, nil
+}
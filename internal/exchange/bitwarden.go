@@ -0,0 +1,422 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nonaxanon/vault-inator/internal/services"
+	"github.com/nonaxanon/vault-inator/internal/urlmatch"
+)
+
+// Bitwarden item types
+const (
+	bitwardenLogin      = 1
+	bitwardenSecureNote = 2
+	bitwardenCard       = 3
+	bitwardenIdentity   = 4
+	bitwardenSSHKey     = 5
+)
+
+// Bitwarden custom field types
+const (
+	bitwardenFieldText    = 0
+	bitwardenFieldHidden  = 1
+	bitwardenFieldBoolean = 2
+	bitwardenFieldLinked  = 3
+)
+
+// bitwardenExport is the unencrypted JSON export of a Bitwarden vault. Nested folders are
+// represented by names joined with "/".
+type bitwardenExport struct {
+	Encrypted bool              `json:"encrypted"`
+	Folders   []bitwardenFolder `json:"folders"`
+	Items     []bitwardenItem   `json:"items"`
+}
+
+type bitwardenFolder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type bitwardenItem struct {
+	ID           string                 `json:"id"`
+	FolderID     *string                `json:"folderId"`
+	Type         int                    `json:"type"`
+	Reprompt     int                    `json:"reprompt"`
+	Name         string                 `json:"name"`
+	Notes        *string                `json:"notes"`
+	Favorite     bool                   `json:"favorite"`
+	Fields       []bitwardenField       `json:"fields,omitempty"`
+	Login        *bitwardenLoginData    `json:"login,omitempty"`
+	SecureNote   *bitwardenNoteData     `json:"secureNote,omitempty"`
+	Card         *bitwardenCardData     `json:"card,omitempty"`
+	Identity     *bitwardenIdentityData `json:"identity,omitempty"`
+	SSHKey       *bitwardenSSHKeyData   `json:"sshKey,omitempty"`
+	CreationDate *time.Time             `json:"creationDate,omitempty"`
+	RevisionDate *time.Time             `json:"revisionDate,omitempty"`
+}
+
+type bitwardenField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  int    `json:"type"`
+}
+
+type bitwardenLoginData struct {
+	URIs     []bitwardenURI `json:"uris,omitempty"`
+	Username string         `json:"username"`
+	Password string         `json:"password"`
+	TOTP     *string        `json:"totp"`
+}
+
+type bitwardenURI struct {
+	Match *int   `json:"match"`
+	URI   string `json:"uri"`
+}
+
+// bitwardenMatchModes maps Bitwarden URI match detection values to URL match modes. Exact
+// (3) and never (5) have no equivalent and import with the default mode.
+var bitwardenMatchModes = map[int]string{
+	0: urlmatch.ModeBaseDomain,
+	1: urlmatch.ModeHostPort,
+	2: urlmatch.ModeStartsWith,
+	4: urlmatch.ModeRegex,
+}
+
+// bitwardenMatchDetections maps URL match modes back to Bitwarden match detection values.
+// Bitwarden's host detection also compares the port, the closest it has to ModeHost.
+var bitwardenMatchDetections = map[string]int{
+	urlmatch.ModeBaseDomain: 0,
+	urlmatch.ModeHost:       1,
+	urlmatch.ModeHostPort:   1,
+	urlmatch.ModeStartsWith: 2,
+	urlmatch.ModeRegex:      4,
+}
+
+type bitwardenNoteData struct {
+	Type int `json:"type"`
+}
+
+type bitwardenCardData struct {
+	CardholderName string `json:"cardholderName"`
+	Brand          string `json:"brand"`
+	Number         string `json:"number"`
+	ExpMonth       string `json:"expMonth"`
+	ExpYear        string `json:"expYear"`
+	Code           string `json:"code"`
+}
+
+type bitwardenIdentityData struct {
+	Title          string `json:"title"`
+	FirstName      string `json:"firstName"`
+	MiddleName     string `json:"middleName"`
+	LastName       string `json:"lastName"`
+	Address1       string `json:"address1"`
+	Address2       string `json:"address2"`
+	Address3       string `json:"address3"`
+	City           string `json:"city"`
+	State          string `json:"state"`
+	PostalCode     string `json:"postalCode"`
+	Country        string `json:"country"`
+	Company        string `json:"company"`
+	Email          string `json:"email"`
+	Phone          string `json:"phone"`
+	SSN            string `json:"ssn"`
+	Username       string `json:"username"`
+	PassportNumber string `json:"passportNumber"`
+	LicenseNumber  string `json:"licenseNumber"`
+}
+
+type bitwardenSSHKeyData struct {
+	PrivateKey     string `json:"privateKey"`
+	PublicKey      string `json:"publicKey"`
+	KeyFingerprint string `json:"keyFingerprint"`
+}
+
+// ParseBitwarden parses an unencrypted Bitwarden JSON export. Login URIs keep their order
+// and match detection, TOTP secrets become the entry's one-time password, and linked custom
+// fields are dropped because they only point at other login fields.
+func ParseBitwarden(r io.Reader) ([]services.ImportRecord, error) {
+	var export bitwardenExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	if export.Encrypted {
+		return nil, fmt.Errorf("%w: encrypted Bitwarden exports are not supported, export as unencrypted JSON", ErrInvalidImport)
+	}
+
+	folders := make(map[string][]string, len(export.Folders))
+	for _, folder := range export.Folders {
+		folders[folder.ID] = strings.Split(folder.Name, "/")
+	}
+
+	records := make([]services.ImportRecord, 0, len(export.Items))
+	for _, item := range export.Items {
+		password := services.Password{
+			Title:    item.Name,
+			Favorite: item.Favorite,
+		}
+		if item.Notes != nil {
+			password.Notes = *item.Notes
+		}
+
+		switch item.Type {
+		case bitwardenLogin:
+			password.Type = services.EntryTypeLogin
+			if login := item.Login; login != nil {
+				password.Username = login.Username
+				password.Password = login.Password
+				for _, uri := range login.URIs {
+					entryURI := services.URI{URL: uri.URI}
+					if uri.Match != nil {
+						entryURI.Match = bitwardenMatchModes[*uri.Match]
+					}
+					password.URIs = append(password.URIs, entryURI)
+				}
+				if login.TOTP != nil {
+					password.TOTP = *login.TOTP
+				}
+			}
+		case bitwardenSecureNote:
+			password.Type = services.EntryTypeSecureNote
+		case bitwardenCard:
+			password.Type = services.EntryTypeCard
+			password.Card = &services.Card{}
+			if card := item.Card; card != nil {
+				password.Card.Cardholder = card.CardholderName
+				password.Card.Brand = card.Brand
+				password.Card.Number = card.Number
+				password.Card.ExpiryMonth, _ = strconv.Atoi(card.ExpMonth)
+				password.Card.ExpiryYear, _ = strconv.Atoi(card.ExpYear)
+				password.Card.CVV = card.Code
+			}
+		case bitwardenIdentity:
+			password.Type = services.EntryTypeIdentity
+			password.Identity = &services.Identity{}
+			if identity := item.Identity; identity != nil {
+				address2 := strings.TrimSpace(strings.Join([]string{identity.Address2, identity.Address3}, " "))
+				*password.Identity = services.Identity{
+					Title:          identity.Title,
+					FirstName:      identity.FirstName,
+					MiddleName:     identity.MiddleName,
+					LastName:       identity.LastName,
+					Email:          identity.Email,
+					Phone:          identity.Phone,
+					Company:        identity.Company,
+					Address1:       identity.Address1,
+					Address2:       address2,
+					City:           identity.City,
+					State:          identity.State,
+					PostalCode:     identity.PostalCode,
+					Country:        identity.Country,
+					SSN:            identity.SSN,
+					PassportNumber: identity.PassportNumber,
+					LicenseNumber:  identity.LicenseNumber,
+				}
+				password.Username = identity.Username
+			}
+		case bitwardenSSHKey:
+			password.Type = services.EntryTypeSSHKey
+			password.SSHKey = &services.SSHKey{}
+			if key := item.SSHKey; key != nil {
+				password.SSHKey.PrivateKey = key.PrivateKey
+				password.SSHKey.PublicKey = key.PublicKey
+			}
+		default:
+			// Unknown item types are kept as secure notes so nothing is silently lost
+			password.Type = services.EntryTypeSecureNote
+		}
+
+		for _, field := range item.Fields {
+			fieldType := services.FieldTypeText
+			switch field.Type {
+			case bitwardenFieldHidden:
+				fieldType = services.FieldTypeHidden
+			case bitwardenFieldBoolean:
+				fieldType = services.FieldTypeBoolean
+			case bitwardenFieldLinked:
+				continue
+			}
+			password.Fields = append(password.Fields, services.CustomField{
+				Name:  field.Name,
+				Type:  fieldType,
+				Value: field.Value,
+			})
+		}
+
+		record := services.ImportRecord{Password: password}
+		if item.FolderID != nil {
+			record.Folder = folders[*item.FolderID]
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// WriteBitwarden writes the vault as an unencrypted Bitwarden JSON export. API credentials
+// and database connections have no Bitwarden equivalent and are written as secure notes
+// whose details are custom fields, with secrets hidden.
+func WriteBitwarden(w io.Writer, vault services.VaultExport) error {
+	paths := vault.FolderPaths()
+	export := bitwardenExport{
+		Folders: make([]bitwardenFolder, 0, len(vault.Folders)),
+		Items:   make([]bitwardenItem, 0, len(vault.Passwords)),
+	}
+	for _, folder := range vault.Folders {
+		export.Folders = append(export.Folders, bitwardenFolder{
+			ID:   folder.ID.String(),
+			Name: strings.Join(paths[folder.ID], "/"),
+		})
+	}
+
+	for _, password := range vault.Passwords {
+		createdAt, updatedAt := password.CreatedAt, password.UpdatedAt
+		item := bitwardenItem{
+			ID:           password.ID.String(),
+			Name:         password.Title,
+			Favorite:     password.Favorite,
+			CreationDate: &createdAt,
+			RevisionDate: &updatedAt,
+		}
+		if password.Notes != "" {
+			notes := password.Notes
+			item.Notes = &notes
+		}
+		if password.FolderID != nil {
+			folderID := password.FolderID.String()
+			item.FolderID = &folderID
+		}
+
+		switch password.Type {
+		case services.EntryTypeCard:
+			item.Type = bitwardenCard
+			if card := password.Card; card != nil {
+				item.Card = &bitwardenCardData{
+					CardholderName: card.Cardholder,
+					Brand:          card.Brand,
+					Number:         card.Number,
+					ExpMonth:       strconv.Itoa(card.ExpiryMonth),
+					ExpYear:        strconv.Itoa(card.ExpiryYear),
+					Code:           card.CVV,
+				}
+				if card.PIN != "" {
+					item.Fields = append(item.Fields, bitwardenField{Name: "PIN", Value: card.PIN, Type: bitwardenFieldHidden})
+				}
+			}
+		case services.EntryTypeIdentity:
+			item.Type = bitwardenIdentity
+			if identity := password.Identity; identity != nil {
+				item.Identity = &bitwardenIdentityData{
+					Title:          identity.Title,
+					FirstName:      identity.FirstName,
+					MiddleName:     identity.MiddleName,
+					LastName:       identity.LastName,
+					Address1:       identity.Address1,
+					Address2:       identity.Address2,
+					City:           identity.City,
+					State:          identity.State,
+					PostalCode:     identity.PostalCode,
+					Country:        identity.Country,
+					Company:        identity.Company,
+					Email:          identity.Email,
+					Phone:          identity.Phone,
+					SSN:            identity.SSN,
+					Username:       password.Username,
+					PassportNumber: identity.PassportNumber,
+					LicenseNumber:  identity.LicenseNumber,
+				}
+			}
+		case services.EntryTypeSSHKey:
+			item.Type = bitwardenSSHKey
+			if key := password.SSHKey; key != nil {
+				item.SSHKey = &bitwardenSSHKeyData{
+					PrivateKey:     key.PrivateKey,
+					PublicKey:      key.PublicKey,
+					KeyFingerprint: key.Fingerprint,
+				}
+				if key.Passphrase != "" {
+					item.Fields = append(item.Fields, bitwardenField{Name: "Passphrase", Value: key.Passphrase, Type: bitwardenFieldHidden})
+				}
+			}
+		case services.EntryTypeSecureNote:
+			item.Type = bitwardenSecureNote
+			item.SecureNote = &bitwardenNoteData{}
+		case services.EntryTypeAPICredential:
+			item.Type = bitwardenSecureNote
+			item.SecureNote = &bitwardenNoteData{}
+			if credential := password.APICredential; credential != nil {
+				item.Fields = appendFields(item.Fields,
+					bitwardenField{Name: "Key ID", Value: credential.KeyID},
+					bitwardenField{Name: "Secret", Value: credential.Secret, Type: bitwardenFieldHidden},
+					bitwardenField{Name: "Endpoint", Value: credential.Endpoint},
+					bitwardenField{Name: "Expires", Value: credential.Expires},
+				)
+			}
+		case services.EntryTypeDatabase:
+			item.Type = bitwardenSecureNote
+			item.SecureNote = &bitwardenNoteData{}
+			if database := password.Database; database != nil {
+				port := ""
+				if database.Port != 0 {
+					port = strconv.Itoa(database.Port)
+				}
+				item.Fields = appendFields(item.Fields,
+					bitwardenField{Name: "Engine", Value: database.Engine},
+					bitwardenField{Name: "Host", Value: database.Host},
+					bitwardenField{Name: "Port", Value: port},
+					bitwardenField{Name: "Database", Value: database.Database},
+					bitwardenField{Name: "Username", Value: database.Username},
+					bitwardenField{Name: "Password", Value: database.Password, Type: bitwardenFieldHidden},
+					bitwardenField{Name: "Options", Value: database.Options},
+				)
+			}
+		default:
+			item.Type = bitwardenLogin
+			item.Login = &bitwardenLoginData{
+				Username: password.Username,
+				Password: password.Password,
+			}
+			for _, entryURI := range password.URIs {
+				uri := bitwardenURI{URI: entryURI.URL}
+				if match, ok := bitwardenMatchDetections[entryURI.Match]; ok {
+					uri.Match = &match
+				}
+				item.Login.URIs = append(item.Login.URIs, uri)
+			}
+			if password.TOTP != "" {
+				totp := password.TOTP
+				item.Login.TOTP = &totp
+			}
+		}
+
+		for _, field := range password.Fields {
+			fieldType := bitwardenFieldText
+			switch field.Type {
+			case services.FieldTypeHidden:
+				fieldType = bitwardenFieldHidden
+			case services.FieldTypeBoolean:
+				fieldType = bitwardenFieldBoolean
+			}
+			item.Fields = append(item.Fields, bitwardenField{Name: field.Name, Value: field.Value, Type: fieldType})
+		}
+		export.Items = append(export.Items, item)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
+
+// appendFields appends the fields that have a value
+func appendFields(fields []bitwardenField, candidates ...bitwardenField) []bitwardenField {
+	for _, field := range candidates {
+		if field.Value != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,99 @@
+package exchange
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+// browserColumns maps the lower-cased CSV headers used by browsers to entry fields:
+//
+//	Chrome, Edge: name, url, username, password, note
+//	Firefox:      url, username, password, httpRealm, formActionOrigin, guid, time*
+//	Safari:       Title, URL, Username, Password, Notes, OTPAuth
+var browserColumns = map[string]string{
+	"name":     "title",
+	"title":    "title",
+	"url":      "url",
+	"username": "username",
+	"password": "password",
+	"note":     "notes",
+	"notes":    "notes",
+	"otpauth":  "otpauth",
+}
+
+// ParseBrowserCSV parses a password CSV exported by Chrome, Edge, Firefox or Safari. The
+// dialect is detected from the header row; unknown columns are ignored.
+func ParseBrowserCSV(r io.Reader) ([]services.ImportRecord, error) {
+	reader := csv.NewReader(skipBOM(r))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: empty file", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		if field, ok := browserColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["password"]; !ok {
+		return nil, fmt.Errorf("%w: no password column in header", ErrInvalidImport)
+	}
+	_, hasURL := columns["url"]
+	_, hasTitle := columns["title"]
+	if !hasURL && !hasTitle {
+		return nil, fmt.Errorf("%w: no url or title column in header", ErrInvalidImport)
+	}
+
+	var records []services.ImportRecord
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+		}
+		value := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+
+		password := services.Password{
+			Type:     services.EntryTypeLogin,
+			Title:    value("title"),
+			URL:      value("url"),
+			Username: value("username"),
+			Password: value("password"),
+			Notes:    value("notes"),
+			TOTP:     value("otpauth"),
+		}
+		records = append(records, services.ImportRecord{Password: password})
+	}
+	return records, nil
+}
+
+// skipBOM drops a leading UTF-8 byte order mark, which some exports include
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+		br.Discard(3)
+	}
+	return br
+}
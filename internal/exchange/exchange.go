@@ -0,0 +1,130 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+// MaxImportSize is the largest import file accepted, in bytes
+const MaxImportSize = 32 << 20
+
+// Supported formats
+const (
+	// FormatBrowserCSV covers the CSV exports of Chrome, Edge, Firefox and Safari
+	FormatBrowserCSV = "csv"
+	// FormatBitwarden is the unencrypted Bitwarden JSON export
+	FormatBitwarden = "bitwarden"
+	// FormatKDBX is a KeePass 2 database in the KDBX 4 format
+	FormatKDBX = "kdbx"
+	// FormatOnePUX is the 1Password 1PUX archive
+	FormatOnePUX = "1pux"
+	// FormatLastPass is the LastPass CSV export
+	FormatLastPass = "lastpass"
+	// FormatArchive is the encrypted Vault-inator backup, restored with RestoreVault
+	FormatArchive = "vault"
+	// FormatAgeArchive is the Vault-inator backup encrypted to age X25519 recipients
+	FormatAgeArchive = "age"
+)
+
+var (
+	ErrUnknownFormat      = errors.New("unknown format")
+	ErrInvalidImport      = errors.New("invalid import file")
+	ErrPassphraseRequired = errors.New("a passphrase is required for this format")
+	ErrIdentityRequired   = errors.New("an age identity is required for this backup")
+)
+
+// ImportFormats lists every format that can be imported
+var ImportFormats = []string{FormatBrowserCSV, FormatBitwarden, FormatKDBX, FormatOnePUX, FormatLastPass}
+
+// ExportFormats lists every format the vault can be exported to
+var ExportFormats = []string{FormatBitwarden, FormatKDBX, FormatArchive, FormatAgeArchive}
+
+// Options carries the settings only some formats use.
+type Options struct {
+	// Passphrase opens or protects encrypted formats such as KDBX
+	Passphrase string
+	// Recipients are the age public keys an age backup is encrypted to
+	Recipients []string
+}
+
+// Parse reads an export in the given format into import records. It only parses;
+// duplicate detection and storage happen in PasswordService.ImportPasswords.
+func Parse(format string, r io.Reader, opts Options) ([]services.ImportRecord, error) {
+	switch format {
+	case FormatBrowserCSV:
+		return ParseBrowserCSV(r)
+	case FormatBitwarden:
+		return ParseBitwarden(r)
+	case FormatKDBX:
+		return ParseKDBX(r, opts.Passphrase)
+	case FormatOnePUX:
+		return ParseOnePUX(r)
+	case FormatLastPass:
+		return ParseLastPassCSV(r)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// Exporter writes the vault in one format.
+type Exporter struct {
+	// FileName and ContentType describe the download
+	FileName    string
+	ContentType string
+	// Attachments is set when the format carries attachment content
+	Attachments bool
+	write       func(w io.Writer, vault services.VaultExport, opts Options) error
+}
+
+// Write writes an exported vault. Unless the format is encrypted, the output holds every
+// secret in the clear.
+func (e Exporter) Write(w io.Writer, vault services.VaultExport, opts Options) error {
+	return e.write(w, vault, opts)
+}
+
+// exporters maps export formats to their exporters
+var exporters = map[string]Exporter{
+	FormatBitwarden: {
+		FileName:    "vault-inator-bitwarden.json",
+		ContentType: "application/json",
+		write: func(w io.Writer, vault services.VaultExport, _ Options) error {
+			return WriteBitwarden(w, vault)
+		},
+	},
+	FormatKDBX: {
+		FileName:    "vault-inator.kdbx",
+		ContentType: "application/octet-stream",
+		Attachments: true,
+		write: func(w io.Writer, vault services.VaultExport, opts Options) error {
+			return WriteKDBX(w, vault, opts.Passphrase)
+		},
+	},
+	FormatArchive: {
+		FileName:    "vault-inator-backup.vault",
+		ContentType: "application/octet-stream",
+		Attachments: true,
+		write: func(w io.Writer, vault services.VaultExport, opts Options) error {
+			return WriteArchive(w, vault, opts.Passphrase)
+		},
+	},
+	FormatAgeArchive: {
+		FileName:    "vault-inator-backup.vault.age",
+		ContentType: "application/octet-stream",
+		Attachments: true,
+		write: func(w io.Writer, vault services.VaultExport, opts Options) error {
+			return WriteAgeArchive(w, vault, opts.Recipients)
+		},
+	},
+}
+
+// LookupExporter returns the exporter of a format
+func LookupExporter(format string) (Exporter, error) {
+	exporter, ok := exporters[format]
+	if !ok {
+		return Exporter{}, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	return exporter, nil
+}
@@ -0,0 +1,382 @@
+package exchange
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/kdbx"
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+// kdbxGenerator names Vault-inator as the writer of exported databases
+const kdbxGenerator = "Vault-inator"
+
+// kdbxKeyOTP is the string KeePassXC keeps an entry's otpauth:// URI in
+const kdbxKeyOTP = "otp"
+
+// kdbxKeyAdditionalURL prefixes the strings KeePassXC and KeePass2Android keep further
+// URLs in: KP2A_URL, KP2A_URL_1, KP2A_URL_2 and so on
+const kdbxKeyAdditionalURL = "KP2A_URL"
+
+// ParseKDBX decrypts a KeePass KDBX 4 database. Groups become folders, strings other than
+// the standard ones become custom fields (hidden when protected), earlier passwords in the
+// entry history become password history and binaries become attachments. Entries in the
+// recycle bin are skipped.
+func ParseKDBX(r io.Reader, passphrase string) ([]services.ImportRecord, error) {
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	db, err := kdbx.Read(r, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+
+	var recycleBin *kdbx.UUID
+	if db.Meta.RecycleBinEnabled && db.Meta.RecycleBinUUID != (kdbx.UUID{}) {
+		recycleBin = &db.Meta.RecycleBinUUID
+	}
+
+	var records []services.ImportRecord
+	var walk func(group *kdbx.Group, path []string)
+	walk = func(group *kdbx.Group, path []string) {
+		for i := range group.Entries {
+			records = append(records, kdbxRecord(db, &group.Entries[i], path))
+		}
+		for i := range group.Groups {
+			sub := &group.Groups[i]
+			if recycleBin != nil && sub.UUID == *recycleBin {
+				continue
+			}
+			walk(sub, append(path[:len(path):len(path)], sub.Name))
+		}
+	}
+	// The root group is the database itself, not a folder
+	walk(&db.Root, nil)
+	return records, nil
+}
+
+// kdbxRecord converts a KeePass entry filed under path
+func kdbxRecord(db *kdbx.Database, entry *kdbx.Entry, path []string) services.ImportRecord {
+	password := services.Password{
+		Type:     services.EntryTypeLogin,
+		Title:    entry.Get(kdbx.KeyTitle),
+		Username: entry.Get(kdbx.KeyUserName),
+		Password: entry.Get(kdbx.KeyPassword),
+		URL:      entry.Get(kdbx.KeyURL),
+		Notes:    entry.Get(kdbx.KeyNotes),
+		Tags:     entry.TagList(),
+	}
+	if password.Username == "" && password.Password == "" && password.URL == "" && password.Notes != "" {
+		password.Type = services.EntryTypeSecureNote
+	}
+	if password.URL != "" {
+		password.URIs = []services.URI{{URL: password.URL}}
+	}
+
+	for _, s := range entry.Strings {
+		switch s.Key {
+		case kdbx.KeyTitle, kdbx.KeyUserName, kdbx.KeyPassword, kdbx.KeyURL, kdbx.KeyNotes:
+			continue
+		case kdbxKeyOTP:
+			password.TOTP = s.Value.Content
+			continue
+		}
+		if strings.HasPrefix(s.Key, kdbxKeyAdditionalURL) && s.Value.Content != "" {
+			password.URIs = append(password.URIs, services.URI{URL: s.Value.Content})
+			continue
+		}
+		if strings.TrimSpace(s.Key) == "" {
+			continue
+		}
+		fieldType := services.FieldTypeText
+		if s.Value.Protected {
+			fieldType = services.FieldTypeHidden
+		}
+		password.Fields = append(password.Fields, services.CustomField{Name: s.Key, Type: fieldType, Value: s.Value.Content})
+	}
+
+	record := services.ImportRecord{Password: password, Folder: path}
+
+	// A password or hidden field was replaced when the next version changed or dropped it
+	versions := append(entry.History[:len(entry.History):len(entry.History)], *entry)
+	for i := 0; i < len(versions)-1; i++ {
+		item := services.PasswordHistory{ChangedAt: time.Time(versions[i+1].Times.LastModificationTime)}
+		if earlier := versions[i].Get(kdbx.KeyPassword); earlier != "" && earlier != versions[i+1].Get(kdbx.KeyPassword) {
+			item.Password = earlier
+		}
+		item.Fields = kdbxReplacedFields(versions[i], versions[i+1])
+		if item.Password != "" || len(item.Fields) > 0 {
+			record.History = append(record.History, item)
+		}
+	}
+
+	for _, ref := range entry.Binaries {
+		if ref.Value.Ref >= 0 && ref.Value.Ref < len(db.Binaries) {
+			record.Attachments = append(record.Attachments, services.ImportAttachment{Name: ref.Key, Data: db.Binaries[ref.Value.Ref]})
+		}
+	}
+	return record
+}
+
+// kdbxReplacedFields returns the protected custom strings of earlier that next changed
+// or dropped, as hidden fields
+func kdbxReplacedFields(earlier, next kdbx.Entry) []services.CustomField {
+	kept := make(map[string]string, len(next.Strings))
+	for _, s := range next.Strings {
+		kept[s.Key] = s.Value.Content
+	}
+	var fields []services.CustomField
+	for _, s := range earlier.Strings {
+		if !bool(s.Value.Protected) || s.Value.Content == "" || !kdbxCustomKey(s.Key) {
+			continue
+		}
+		if value, ok := kept[s.Key]; ok && value == s.Value.Content {
+			continue
+		}
+		fields = append(fields, services.CustomField{Name: s.Key, Type: services.FieldTypeHidden, Value: s.Value.Content})
+	}
+	return fields
+}
+
+// kdbxCustomKey reports whether key is a string that is imported as a custom field
+func kdbxCustomKey(key string) bool {
+	switch key {
+	case kdbx.KeyTitle, kdbx.KeyUserName, kdbx.KeyPassword, kdbx.KeyURL, kdbx.KeyNotes, kdbxKeyOTP:
+		return false
+	}
+	return !strings.HasPrefix(key, kdbxKeyAdditionalURL) && strings.TrimSpace(key) != ""
+}
+
+// WriteKDBX writes the vault as a KeePass KDBX 4 database protected by passphrase. Folders
+// become groups and attachments become binaries. Details of cards, identities, SSH keys,
+// API credentials and databases are written as extra strings, protected when secret.
+// The password history of each entry becomes its KeePass history.
+func WriteKDBX(w io.Writer, vault services.VaultExport, passphrase string) error {
+	if passphrase == "" {
+		return ErrPassphraseRequired
+	}
+
+	now := time.Now()
+	db := &kdbx.Database{
+		Meta: kdbx.Meta{Generator: kdbxGenerator, DatabaseName: kdbxGenerator},
+		Root: kdbx.Group{
+			UUID:       kdbx.UUID(uuid.New()),
+			Name:       kdbxGenerator,
+			IconID:     48,
+			Times:      kdbx.NewTimes(now, now),
+			IsExpanded: true,
+		},
+	}
+
+	children := make(map[uuid.UUID][]services.Folder)
+	known := make(map[uuid.UUID]bool, len(vault.Folders))
+	for _, folder := range vault.Folders {
+		known[folder.ID] = true
+	}
+	for _, folder := range vault.Folders {
+		var parent uuid.UUID
+		if folder.ParentID != nil && known[*folder.ParentID] {
+			parent = *folder.ParentID
+		}
+		children[parent] = append(children[parent], folder)
+	}
+	entries := make(map[uuid.UUID][]kdbx.Entry)
+	for _, password := range vault.Passwords {
+		var folder uuid.UUID
+		if password.FolderID != nil && known[*password.FolderID] {
+			folder = *password.FolderID
+		}
+		entries[folder] = append(entries[folder], kdbxEntry(db, vault, password))
+	}
+
+	var fill func(group *kdbx.Group, id uuid.UUID, depth int)
+	fill = func(group *kdbx.Group, id uuid.UUID, depth int) {
+		group.Entries = entries[id]
+		if depth > len(vault.Folders) {
+			return
+		}
+		for _, folder := range children[id] {
+			sub := kdbx.Group{
+				UUID:       kdbx.UUID(folder.ID),
+				Name:       folder.Name,
+				IconID:     48,
+				Times:      kdbx.NewTimes(folder.CreatedAt, folder.CreatedAt),
+				IsExpanded: true,
+			}
+			fill(&sub, folder.ID, depth+1)
+			group.Groups = append(group.Groups, sub)
+		}
+	}
+	fill(&db.Root, uuid.Nil, 0)
+
+	return kdbx.Write(w, db, passphrase)
+}
+
+// kdbxEntry converts an entry, adding its attachments to the database binaries
+func kdbxEntry(db *kdbx.Database, vault services.VaultExport, password services.Password) kdbx.Entry {
+	strs := kdbxStrings{}
+	strs.add(kdbx.KeyTitle, password.Title, false)
+	strs.add(kdbx.KeyUserName, password.Username, false)
+	strs.add(kdbx.KeyPassword, password.Password, true)
+	strs.add(kdbx.KeyURL, password.URL, false)
+	strs.add(kdbx.KeyNotes, password.Notes, false)
+	strs.addNonEmpty(kdbxKeyOTP, password.TOTP, true)
+	for i := 1; i < len(password.URIs); i++ {
+		key := kdbxKeyAdditionalURL
+		if i > 1 {
+			key = fmt.Sprintf("%s_%d", kdbxKeyAdditionalURL, i-1)
+		}
+		strs.add(key, password.URIs[i].URL, false)
+	}
+
+	switch {
+	case password.Card != nil:
+		card := password.Card
+		strs.addNonEmpty("Cardholder", card.Cardholder, false)
+		strs.addNonEmpty("Brand", card.Brand, false)
+		strs.addNonEmpty("Card Number", card.Number, true)
+		if card.ExpiryMonth != 0 || card.ExpiryYear != 0 {
+			strs.add("Expiry", fmt.Sprintf("%02d/%d", card.ExpiryMonth, card.ExpiryYear), false)
+		}
+		strs.addNonEmpty("CVV", card.CVV, true)
+		strs.addNonEmpty("PIN", card.PIN, true)
+	case password.Identity != nil:
+		identity := password.Identity
+		strs.addNonEmpty("Name Title", identity.Title, false)
+		strs.addNonEmpty("First Name", identity.FirstName, false)
+		strs.addNonEmpty("Middle Name", identity.MiddleName, false)
+		strs.addNonEmpty("Last Name", identity.LastName, false)
+		strs.addNonEmpty("Email", identity.Email, false)
+		strs.addNonEmpty("Phone", identity.Phone, false)
+		strs.addNonEmpty("Company", identity.Company, false)
+		strs.addNonEmpty("Address 1", identity.Address1, false)
+		strs.addNonEmpty("Address 2", identity.Address2, false)
+		strs.addNonEmpty("City", identity.City, false)
+		strs.addNonEmpty("State", identity.State, false)
+		strs.addNonEmpty("Postal Code", identity.PostalCode, false)
+		strs.addNonEmpty("Country", identity.Country, false)
+		strs.addNonEmpty("SSN", identity.SSN, true)
+		strs.addNonEmpty("Passport Number", identity.PassportNumber, true)
+		strs.addNonEmpty("License Number", identity.LicenseNumber, true)
+	case password.SSHKey != nil:
+		key := password.SSHKey
+		strs.addNonEmpty("Private Key", key.PrivateKey, true)
+		strs.addNonEmpty("Public Key", key.PublicKey, false)
+		strs.addNonEmpty("Fingerprint", key.Fingerprint, false)
+		strs.addNonEmpty("Passphrase", key.Passphrase, true)
+	case password.APICredential != nil:
+		credential := password.APICredential
+		strs.addNonEmpty("Key ID", credential.KeyID, false)
+		strs.addNonEmpty("Secret", credential.Secret, true)
+		strs.addNonEmpty("Endpoint", credential.Endpoint, false)
+		strs.addNonEmpty("Expires", credential.Expires, false)
+	case password.Database != nil:
+		database := password.Database
+		strs.addNonEmpty("Engine", database.Engine, false)
+		strs.addNonEmpty("Host", database.Host, false)
+		if database.Port != 0 {
+			strs.add("Port", strconv.Itoa(database.Port), false)
+		}
+		strs.addNonEmpty("Database", database.Database, false)
+		strs.addNonEmpty("Database Username", database.Username, false)
+		strs.addNonEmpty("Database Password", database.Password, true)
+		strs.addNonEmpty("Options", database.Options, false)
+	}
+
+	for _, field := range password.Fields {
+		strs.add(field.Name, field.Value, field.Type == services.FieldTypeHidden)
+	}
+
+	entry := kdbx.Entry{
+		UUID:    kdbx.UUID(password.ID),
+		Tags:    strings.Join(password.Tags, ";"),
+		Times:   kdbx.NewTimes(password.CreatedAt, password.UpdatedAt),
+		Strings: strs,
+	}
+
+	// KeePass keeps full earlier versions, stamped with when they were made. They are
+	// rebuilt from the newest back by undoing one change at a time: the replaced password
+	// and hidden fields go back in, everything else stays as it is now. Each version was
+	// made when the one before it was replaced, the oldest when the entry was created.
+	history := vault.History[password.ID]
+	versions := make([]kdbx.Entry, len(history))
+	current := strs
+	for i, item := range history {
+		setAt := password.CreatedAt
+		if i+1 < len(history) {
+			setAt = history[i+1].ChangedAt
+		}
+		earlier := make(kdbxStrings, len(current))
+		copy(earlier, current)
+		if item.Password != "" {
+			earlier.set(kdbx.KeyPassword, item.Password, false)
+		}
+		for _, field := range item.Fields {
+			earlier.set(field.Name, field.Value, true)
+		}
+		versions[len(history)-1-i] = kdbx.Entry{
+			UUID:    entry.UUID,
+			Tags:    entry.Tags,
+			Times:   kdbx.NewTimes(password.CreatedAt, setAt),
+			Strings: earlier,
+		}
+		current = earlier
+	}
+	entry.History = versions
+
+	names := make(map[string]bool)
+	for _, attachment := range vault.Attachments[password.ID] {
+		name := uniqueName(names, attachment.Name)
+		ref := kdbx.BinaryRef{Key: name}
+		ref.Value.Ref = len(db.Binaries)
+		db.Binaries = append(db.Binaries, attachment.Data)
+		entry.Binaries = append(entry.Binaries, ref)
+	}
+	return entry
+}
+
+// kdbxStrings builds the strings of an entry; KeePass requires unique keys
+type kdbxStrings []kdbx.String
+
+func (s *kdbxStrings) add(key, value string, protected bool) {
+	names := make(map[string]bool, len(*s))
+	for _, existing := range *s {
+		names[existing.Key] = true
+	}
+	*s = append(*s, kdbx.String{
+		Key:   uniqueName(names, key),
+		Value: kdbx.Value{Content: value, Protected: kdbx.Bool(protected)},
+	})
+}
+
+// set replaces the value of key, or adds it if there is none
+func (s *kdbxStrings) set(key, value string, protected bool) {
+	for i := range *s {
+		if (*s)[i].Key == key {
+			(*s)[i].Value = kdbx.Value{Content: value, Protected: (*s)[i].Value.Protected || kdbx.Bool(protected)}
+			return
+		}
+	}
+	s.add(key, value, protected)
+}
+
+func (s *kdbxStrings) addNonEmpty(key, value string, protected bool) {
+	if value != "" {
+		s.add(key, value, protected)
+	}
+}
+
+// uniqueName returns name, or name with a number appended if it is already taken, and
+// marks the result as taken
+func uniqueName(taken map[string]bool, name string) string {
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s (%d)", name, i)
+	}
+	taken[unique] = true
+	return unique
+}
@@ -0,0 +1,58 @@
+package exchange
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+func TestKDBXHistoryRoundTrip(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := created.Add(24 * time.Hour)
+	second := first.Add(24 * time.Hour)
+	password := services.Password{
+		ID:        uuid.New(),
+		Type:      services.EntryTypeLogin,
+		Title:     "Mail",
+		Password:  "third",
+		Fields:    []services.CustomField{{Name: "PIN", Type: services.FieldTypeHidden, Value: "3333"}},
+		CreatedAt: created,
+		UpdatedAt: second,
+	}
+	// Most recently replaced first: the PIN changed alone, before that only the password
+	history := []services.PasswordHistory{
+		{Fields: []services.CustomField{{Name: "PIN", Type: services.FieldTypeHidden, Value: "2222"}}, ChangedAt: second},
+		{Password: "first", ChangedAt: first},
+	}
+	vault := services.VaultExport{
+		Passwords: []services.Password{password},
+		History:   map[uuid.UUID][]services.PasswordHistory{password.ID: history},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteKDBX(&buf, vault, "passphrase"); err != nil {
+		t.Fatalf("WriteKDBX() error = %v", err)
+	}
+	records, err := ParseKDBX(&buf, "passphrase")
+	if err != nil {
+		t.Fatalf("ParseKDBX() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("ParseKDBX() returned %d records, want 1", len(records))
+	}
+	got := records[0].History
+	// Import lists history oldest first
+	want := []services.PasswordHistory{history[1], history[0]}
+	if len(got) != len(want) {
+		t.Fatalf("History = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Password != want[i].Password || !got[i].ChangedAt.Equal(want[i].ChangedAt) || !reflect.DeepEqual(got[i].Fields, want[i].Fields) {
+			t.Errorf("History[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package exchange
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+// lastPassNoteURL marks secure notes in a LastPass export
+const lastPassNoteURL = "http://sn"
+
+// lastPassNoFolder is the grouping of entries outside any folder
+const lastPassNoFolder = "(none)"
+
+// lastPassNotesKey starts the free text of a typed secure note; it runs to the end
+const lastPassNotesKey = "Notes"
+
+// lastPassHidden lists the secure note keys whose values are secret
+var lastPassHidden = map[string]bool{
+	"Password":       true,
+	"Passphrase":     true,
+	"Private Key":    true,
+	"PIN":            true,
+	"Security Code":  true,
+	"Number":         true,
+	"Account Number": true,
+	"Routing Number": true,
+	"Pin":            true,
+}
+
+// ParseLastPassCSV parses a LastPass CSV export with the columns url, username, password,
+// totp, extra, name, grouping and fav. Groupings become folders, split at backslashes.
+// Typed secure notes pack their fields into extra as "Key:Value" lines; credit cards,
+// addresses, databases, SSH keys, servers and identity documents become entries of the
+// matching type and other note types secure notes with custom fields.
+func ParseLastPassCSV(r io.Reader) ([]services.ImportRecord, error) {
+	reader := csv.NewReader(skipBOM(r))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: empty file", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, seen := columns[name]; !seen {
+			columns[name] = i
+		}
+	}
+	for _, required := range []string{"url", "password", "extra", "name", "grouping"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: no %s column in header, not a LastPass export", ErrInvalidImport, required)
+		}
+	}
+
+	var records []services.ImportRecord
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+		}
+		value := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+		// LastPass escapes HTML special characters in the name, grouping and extra columns
+		// only; credentials are exported as they are, so a password such as "&amp;" survives
+		text := func(column string) string {
+			return html.UnescapeString(value(column))
+		}
+
+		password := services.Password{
+			Title:    text("name"),
+			Favorite: value("fav") == "1",
+		}
+		if value("url") == lastPassNoteURL {
+			lastPassNote(&password, text("extra"))
+		} else {
+			password.Type = services.EntryTypeLogin
+			password.URL = value("url")
+			password.Username = value("username")
+			password.Password = value("password")
+			password.Notes = text("extra")
+		}
+		password.TOTP = value("totp")
+
+		record := services.ImportRecord{Password: password}
+		if grouping := text("grouping"); grouping != "" && grouping != lastPassNoFolder {
+			record.Folder = strings.Split(grouping, `\`)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// lastPassNote fills password from the extra column of a secure note
+func lastPassNote(password *services.Password, extra string) {
+	password.Type = services.EntryTypeSecureNote
+	noteType, fields, notes, ok := unpackLastPassNote(extra)
+	if !ok {
+		password.Notes = extra
+		return
+	}
+	password.Notes = notes
+
+	take := func(key string) string {
+		value := fields.get(key)
+		fields.remove(key)
+		return value
+	}
+	switch noteType {
+	case "Credit Card":
+		password.Type = services.EntryTypeCard
+		password.Card = &services.Card{
+			Cardholder: take("Name on Card"),
+			Brand:      take("Type"),
+			Number:     take("Number"),
+			CVV:        take("Security Code"),
+		}
+		password.Card.ExpiryMonth, password.Card.ExpiryYear = parseLastPassMonth(take("Expiration Date"))
+	case "Address":
+		password.Type = services.EntryTypeIdentity
+		password.Identity = &services.Identity{
+			Title:      take("Title"),
+			FirstName:  take("First Name"),
+			MiddleName: take("Middle Name"),
+			LastName:   take("Last Name"),
+			Company:    take("Company"),
+			Address1:   take("Address 1"),
+			Address2:   strings.TrimSpace(take("Address 2") + " " + take("Address 3")),
+			City:       take("City / Town"),
+			State:      take("State"),
+			PostalCode: take("Zip / Postal Code"),
+			Country:    take("Country"),
+			Email:      take("Email Address"),
+			Phone:      lastPassPhone(take("Phone")),
+		}
+		password.Username = take("Username")
+	case "Driver's License", "Passport", "Social Security":
+		first, last := splitFullName(fields.get("Name"))
+		if first == "" && last == "" {
+			break
+		}
+		fields.remove("Name")
+		password.Type = services.EntryTypeIdentity
+		password.Identity = &services.Identity{FirstName: first, LastName: last}
+		switch noteType {
+		case "Driver's License":
+			password.Identity.LicenseNumber = take("Number")
+		case "Passport":
+			password.Identity.PassportNumber = take("Number")
+		case "Social Security":
+			password.Identity.SSN = take("Number")
+		}
+	case "Database":
+		password.Type = services.EntryTypeDatabase
+		password.Database = &services.DatabaseConnection{
+			Engine:   take("Type"),
+			Host:     take("Hostname"),
+			Database: take("Database"),
+			Username: take("Username"),
+			Password: take("Password"),
+		}
+		if port, err := strconv.Atoi(fields.get("Port")); err == nil {
+			fields.remove("Port")
+			password.Database.Port = port
+		}
+	case "SSH Key":
+		password.Type = services.EntryTypeSSHKey
+		password.SSHKey = &services.SSHKey{
+			PrivateKey: take("Private Key"),
+			PublicKey:  take("Public Key"),
+			Passphrase: take("Passphrase"),
+		}
+	case "Server":
+		password.Type = services.EntryTypeLogin
+		password.URL = take("Hostname")
+		password.Username = take("Username")
+		password.Password = take("Password")
+	}
+
+	for _, field := range fields {
+		// Empty dates are written as ","
+		if strings.Trim(field.value, ", ") == "" {
+			continue
+		}
+		fieldType := services.FieldTypeText
+		if lastPassHidden[field.key] {
+			fieldType = services.FieldTypeHidden
+		}
+		if strings.Contains(field.key, "Phone") {
+			field.value = lastPassPhone(field.value)
+		}
+		password.Fields = append(password.Fields, services.CustomField{Name: field.key, Type: fieldType, Value: field.value})
+	}
+}
+
+// lastPassField is one "Key:Value" line of a typed secure note
+type lastPassField struct {
+	key   string
+	value string
+}
+
+type lastPassFields []lastPassField
+
+func (f lastPassFields) get(key string) string {
+	for _, field := range f {
+		if field.key == key {
+			return field.value
+		}
+	}
+	return ""
+}
+
+func (f *lastPassFields) remove(key string) {
+	for i, field := range *f {
+		if field.key == key {
+			*f = append((*f)[:i], (*f)[i+1:]...)
+			return
+		}
+	}
+}
+
+// unpackLastPassNote splits a typed secure note into its type, fields and notes. Notes
+// are not typed when the first line is not "NoteType:<type>". Lines without a colon
+// continue the value of the field before them, such as the lines of a private key.
+func unpackLastPassNote(extra string) (noteType string, fields lastPassFields, notes string, ok bool) {
+	lines := strings.Split(strings.ReplaceAll(extra, "\r\n", "\n"), "\n")
+	noteType, found := strings.CutPrefix(lines[0], "NoteType:")
+	if !found {
+		return "", nil, "", false
+	}
+	// last is the index of the field continuation lines belong to, -1 for none
+	last := -1
+	for i := 1; i < len(lines); i++ {
+		key, value, found := strings.Cut(lines[i], ":")
+		if !found {
+			if last >= 0 {
+				fields[last].value += "\n" + lines[i]
+			}
+			continue
+		}
+		if key == lastPassNotesKey {
+			notes = strings.Join(append([]string{value}, lines[i+1:]...), "\n")
+			break
+		}
+		if key == "Language" {
+			last = -1
+			continue
+		}
+		fields = append(fields, lastPassField{key: key, value: value})
+		last = len(fields) - 1
+	}
+	return noteType, fields, notes, true
+}
+
+// parseLastPassMonth parses an expiration date such as "January,2027"
+func parseLastPassMonth(value string) (month, year int) {
+	monthName, yearText, _ := strings.Cut(value, ",")
+	if t, err := time.Parse("January", strings.TrimSpace(monthName)); err == nil {
+		month = int(t.Month())
+	}
+	year, _ = strconv.Atoi(strings.TrimSpace(yearText))
+	return month, year
+}
+
+// lastPassPhone extracts the number from a phone value, which LastPass stores as JSON
+// such as {"num":"5551234","ext":"","cc3l":"USA"}
+func lastPassPhone(value string) string {
+	var phone struct {
+		Num string `json:"num"`
+		Ext string `json:"ext"`
+	}
+	if json.Unmarshal([]byte(value), &phone) != nil {
+		return value
+	}
+	if phone.Ext != "" {
+		return phone.Num + " ext. " + phone.Ext
+	}
+	return phone.Num
+}
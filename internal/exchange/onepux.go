@@ -0,0 +1,565 @@
+package exchange
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nonaxanon/vault-inator/internal/services"
+)
+
+// 1Password item categories
+const (
+	onePUXLogin          = "001"
+	onePUXCreditCard     = "002"
+	onePUXSecureNote     = "003"
+	onePUXIdentity       = "004"
+	onePUXPassword       = "005"
+	onePUXDocument       = "006"
+	onePUXDatabase       = "102"
+	onePUXDriverLicense  = "103"
+	onePUXPassport       = "106"
+	onePUXSocialSecurity = "108"
+	onePUXServer         = "110"
+	onePUXAPICredential  = "112"
+	onePUXSSHKey         = "114"
+)
+
+// onePUXDataFile is the archive member holding the vault data; attachments are stored
+// under onePUXFilesDir as <documentId>__<fileName>
+const (
+	onePUXDataFile = "export.data"
+	onePUXFilesDir = "files/"
+)
+
+// maxExtractedSize limits the total size of the files read from a 1PUX archive
+const maxExtractedSize = 256 << 20
+
+// onePUXExport is the export.data document of a 1PUX archive.
+type onePUXExport struct {
+	Accounts []onePUXAccount `json:"accounts"`
+}
+
+type onePUXAccount struct {
+	Vaults []onePUXVault `json:"vaults"`
+}
+
+type onePUXVault struct {
+	Attrs struct {
+		Name string `json:"name"`
+	} `json:"attrs"`
+	Items []onePUXItem `json:"items"`
+}
+
+type onePUXItem struct {
+	UUID         string         `json:"uuid"`
+	FavIndex     int            `json:"favIndex"`
+	State        string         `json:"state"`
+	CategoryUUID string         `json:"categoryUuid"`
+	Details      onePUXDetails  `json:"details"`
+	Overview     onePUXOverview `json:"overview"`
+}
+
+type onePUXDetails struct {
+	LoginFields        []onePUXLoginField      `json:"loginFields"`
+	NotesPlain         string                  `json:"notesPlain"`
+	Sections           []onePUXSection         `json:"sections"`
+	PasswordHistory    []onePUXPasswordHistory `json:"passwordHistory"`
+	DocumentAttributes *onePUXFile             `json:"documentAttributes"`
+	Password           string                  `json:"password"`
+}
+
+type onePUXLoginField struct {
+	Value       string `json:"value"`
+	Designation string `json:"designation"`
+}
+
+type onePUXSection struct {
+	Title  string        `json:"title"`
+	Fields []onePUXField `json:"fields"`
+}
+
+type onePUXField struct {
+	ID    string      `json:"id"`
+	Title string      `json:"title"`
+	Value onePUXValue `json:"value"`
+}
+
+type onePUXPasswordHistory struct {
+	Value string `json:"value"`
+	Time  int64  `json:"time"`
+}
+
+type onePUXOverview struct {
+	Title string      `json:"title"`
+	URL   string      `json:"url"`
+	URLs  []onePUXURL `json:"urls"`
+	Tags  []string    `json:"tags"`
+}
+
+type onePUXURL struct {
+	URL string `json:"url"`
+}
+
+type onePUXFile struct {
+	FileName   string `json:"fileName"`
+	DocumentID string `json:"documentId"`
+}
+
+type onePUXAddress struct {
+	Street  string `json:"street"`
+	City    string `json:"city"`
+	State   string `json:"state"`
+	Zip     string `json:"zip"`
+	Country string `json:"country"`
+}
+
+type onePUXSSHKeyValue struct {
+	PrivateKey string `json:"privateKey"`
+	Metadata   struct {
+		PublicKey   string `json:"publicKey"`
+		Fingerprint string `json:"fingerprint"`
+	} `json:"metadata"`
+}
+
+// onePUXValue is a typed field value, an object with a single key naming the type such
+// as {"concealed": "..."} or {"address": {...}}
+type onePUXValue map[string]json.RawMessage
+
+// kind returns the type of the value and its raw content
+func (v onePUXValue) kind() (string, json.RawMessage) {
+	for kind, raw := range v {
+		return kind, raw
+	}
+	return "", nil
+}
+
+// text renders the value as a single string
+func (v onePUXValue) text() string {
+	kind, raw := v.kind()
+	switch kind {
+	case "date":
+		var seconds int64
+		if json.Unmarshal(raw, &seconds) == nil && seconds != 0 {
+			return time.Unix(seconds, 0).UTC().Format(time.DateOnly)
+		}
+		return ""
+	case "monthYear":
+		var monthYear int
+		if json.Unmarshal(raw, &monthYear) == nil && monthYear != 0 {
+			return fmt.Sprintf("%02d/%d", monthYear%100, monthYear/100)
+		}
+		return ""
+	case "email":
+		var email struct {
+			Address string `json:"email_address"`
+		}
+		json.Unmarshal(raw, &email)
+		return email.Address
+	case "address":
+		var address onePUXAddress
+		json.Unmarshal(raw, &address)
+		var parts []string
+		for _, part := range []string{address.Street, address.City, address.State, address.Zip, address.Country} {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, ", ")
+	case "file":
+		var file onePUXFile
+		json.Unmarshal(raw, &file)
+		return file.FileName
+	case "sshKey":
+		var key onePUXSSHKeyValue
+		json.Unmarshal(raw, &key)
+		return key.PrivateKey
+	default:
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			return s
+		}
+		var n json.Number
+		if json.Unmarshal(raw, &n) == nil {
+			return n.String()
+		}
+		return ""
+	}
+}
+
+// ParseOnePUX parses a 1Password 1PUX archive. Vaults become folders, categories become
+// entry types and tags are kept; categories without an equivalent type are imported as
+// secure notes. Section fields that do not map to entry details become custom fields,
+// concealed ones hidden, and attached files and documents become attachments. Archived
+// items are tagged "archived".
+func ParseOnePUX(r io.Reader) ([]services.ImportRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a 1PUX archive: %w", ErrInvalidImport, err)
+	}
+
+	budget := int64(maxExtractedSize)
+	var export *onePUXExport
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		switch {
+		case file.Name == onePUXDataFile:
+			content, err := readZipFile(file, &budget)
+			if err != nil {
+				return nil, err
+			}
+			export = &onePUXExport{}
+			if err := json.Unmarshal(content, export); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+			}
+		case strings.HasPrefix(file.Name, onePUXFilesDir):
+			documentID, _, ok := strings.Cut(strings.TrimPrefix(file.Name, onePUXFilesDir), "__")
+			if ok && documentID != "" {
+				files[documentID] = file
+			}
+		}
+	}
+	if export == nil {
+		return nil, fmt.Errorf("%w: %s not found in archive", ErrInvalidImport, onePUXDataFile)
+	}
+
+	var records []services.ImportRecord
+	for _, account := range export.Accounts {
+		for _, vault := range account.Vaults {
+			var folder []string
+			if name := strings.TrimSpace(vault.Attrs.Name); name != "" {
+				folder = []string{name}
+			}
+			for _, item := range vault.Items {
+				if item.State == "deleted" {
+					continue
+				}
+				record, err := onePUXRecord(item, files, &budget)
+				if err != nil {
+					return nil, err
+				}
+				record.Folder = folder
+				records = append(records, record)
+			}
+		}
+	}
+	return records, nil
+}
+
+// onePUXRecord converts a 1Password item, reading its attachments from files
+func onePUXRecord(item onePUXItem, files map[string]*zip.File, budget *int64) (services.ImportRecord, error) {
+	details := item.Details
+	password := services.Password{
+		Type:     services.EntryTypeSecureNote,
+		Title:    item.Overview.Title,
+		URL:      item.Overview.URL,
+		Notes:    details.NotesPlain,
+		Tags:     item.Overview.Tags,
+		Favorite: item.FavIndex > 0,
+	}
+	if item.State == "archived" {
+		password.Tags = append(password.Tags[:len(password.Tags):len(password.Tags)], "archived")
+	}
+
+	fields := newOnePUXFields(details.Sections)
+	switch item.CategoryUUID {
+	case onePUXSecureNote, onePUXDocument:
+		// Documents are notes carrying their file as an attachment
+	case onePUXLogin, onePUXPassword:
+		password.Type = services.EntryTypeLogin
+		password.Password = details.Password
+		for _, field := range details.LoginFields {
+			switch field.Designation {
+			case "username":
+				password.Username = field.Value
+			case "password":
+				password.Password = field.Value
+			}
+		}
+	case onePUXServer:
+		password.Type = services.EntryTypeLogin
+		password.URL = fields.take("url")
+		password.Username = fields.take("username")
+		password.Password = fields.take("password")
+	case onePUXCreditCard:
+		password.Type = services.EntryTypeCard
+		password.Card = &services.Card{
+			Cardholder: fields.take("cardholder"),
+			Brand:      fields.take("type"),
+			Number:     fields.take("ccnum"),
+			CVV:        fields.take("cvv"),
+			PIN:        fields.take("pin"),
+		}
+		if expiry, ok := fields.takeField("expiry"); ok {
+			var monthYear int
+			_, raw := expiry.Value.kind()
+			if json.Unmarshal(raw, &monthYear) == nil {
+				password.Card.ExpiryMonth, password.Card.ExpiryYear = monthYear%100, monthYear/100
+			}
+		}
+	case onePUXIdentity:
+		password.Type = services.EntryTypeIdentity
+		password.Identity = &services.Identity{
+			FirstName:  fields.take("firstname"),
+			MiddleName: fields.take("initial"),
+			LastName:   fields.take("lastname"),
+			Company:    fields.take("company"),
+			Phone:      fields.take("defphone"),
+			Email:      fields.take("email"),
+		}
+		password.Username = fields.take("username")
+		if address, ok := fields.takeField("address"); ok {
+			var value onePUXAddress
+			if _, raw := address.Value.kind(); json.Unmarshal(raw, &value) == nil {
+				password.Identity.Address1 = value.Street
+				password.Identity.City = value.City
+				password.Identity.State = value.State
+				password.Identity.PostalCode = value.Zip
+				password.Identity.Country = value.Country
+			}
+		}
+	case onePUXDriverLicense, onePUXPassport, onePUXSocialSecurity:
+		name := fields.peek("fullname")
+		if name == "" {
+			name = fields.peek("name")
+		}
+		first, last := splitFullName(name)
+		if first == "" && last == "" {
+			// Without a name the details cannot form an identity; keep them as a note
+			break
+		}
+		fields.take("fullname")
+		fields.take("name")
+		password.Type = services.EntryTypeIdentity
+		password.Identity = &services.Identity{FirstName: first, LastName: last}
+		switch item.CategoryUUID {
+		case onePUXDriverLicense:
+			password.Identity.LicenseNumber = fields.take("number")
+		case onePUXPassport:
+			password.Identity.PassportNumber = fields.take("number")
+		case onePUXSocialSecurity:
+			password.Identity.SSN = fields.take("number")
+		}
+	case onePUXDatabase:
+		password.Type = services.EntryTypeDatabase
+		password.Database = &services.DatabaseConnection{
+			Engine:   fields.take("database_type"),
+			Host:     fields.take("hostname"),
+			Database: fields.take("database"),
+			Username: fields.take("username"),
+			Password: fields.take("password"),
+			Options:  fields.take("options"),
+		}
+		password.Database.Port, _ = strconv.Atoi(fields.peek("port"))
+		if password.Database.Port != 0 {
+			fields.take("port")
+		}
+	case onePUXAPICredential:
+		password.Type = services.EntryTypeAPICredential
+		password.APICredential = &services.APICredential{
+			KeyID:    fields.take("username"),
+			Secret:   fields.take("credential"),
+			Endpoint: fields.take("hostname"),
+			Expires:  fields.take("expires"),
+		}
+	case onePUXSSHKey:
+		password.Type = services.EntryTypeSSHKey
+		password.SSHKey = &services.SSHKey{}
+		if key, ok := fields.takeKind("sshKey"); ok {
+			var value onePUXSSHKeyValue
+			if _, raw := key.Value.kind(); json.Unmarshal(raw, &value) == nil {
+				password.SSHKey.PrivateKey = value.PrivateKey
+				password.SSHKey.PublicKey = value.Metadata.PublicKey
+			}
+		}
+	}
+
+	// Websites become the entry's URIs, the primary one first; notes keep them as URL fields
+	urls := []string{password.URL}
+	for _, u := range item.Overview.URLs {
+		if u.URL != "" && u.URL != password.URL {
+			urls = append(urls, u.URL)
+		}
+	}
+	if urls[0] == "" {
+		urls = urls[1:]
+	}
+	password.URL = ""
+	for i, u := range urls {
+		if password.Type != services.EntryTypeSecureNote {
+			password.URIs = append(password.URIs, services.URI{URL: u})
+			continue
+		}
+		password.Fields = append(password.Fields, services.CustomField{
+			Name:  fmt.Sprintf("URL %d", i+1),
+			Type:  services.FieldTypeURL,
+			Value: u,
+		})
+	}
+
+	record := services.ImportRecord{}
+	documents := fields.remaining(&password)
+	if details.DocumentAttributes != nil {
+		documents = append(documents, *details.DocumentAttributes)
+	}
+	for _, document := range documents {
+		file, ok := files[document.DocumentID]
+		if !ok {
+			continue
+		}
+		content, err := readZipFile(file, budget)
+		if err != nil {
+			return services.ImportRecord{}, err
+		}
+		name := document.FileName
+		if name == "" {
+			_, name, _ = strings.Cut(strings.TrimPrefix(file.Name, onePUXFilesDir), "__")
+		}
+		record.Attachments = append(record.Attachments, services.ImportAttachment{Name: name, Data: content})
+	}
+
+	for _, item := range details.PasswordHistory {
+		if item.Value != "" {
+			record.History = append(record.History, services.PasswordHistory{
+				Password:  item.Value,
+				ChangedAt: time.Unix(item.Time, 0),
+			})
+		}
+	}
+	record.Password = password
+	return record, nil
+}
+
+// onePUXFields tracks which section fields of an item have been mapped to entry details
+type onePUXFields struct {
+	fields []onePUXField
+	used   []bool
+}
+
+func newOnePUXFields(sections []onePUXSection) *onePUXFields {
+	f := &onePUXFields{}
+	for _, section := range sections {
+		f.fields = append(f.fields, section.Fields...)
+	}
+	f.used = make([]bool, len(f.fields))
+	return f
+}
+
+// takeField marks the first unused field with the given ID as used and returns it
+func (f *onePUXFields) takeField(id string) (onePUXField, bool) {
+	for i, field := range f.fields {
+		if !f.used[i] && field.ID == id {
+			f.used[i] = true
+			return field, true
+		}
+	}
+	return onePUXField{}, false
+}
+
+// takeKind marks the first unused field holding a value of the given type as used
+func (f *onePUXFields) takeKind(kind string) (onePUXField, bool) {
+	for i, field := range f.fields {
+		if k, _ := field.Value.kind(); !f.used[i] && k == kind {
+			f.used[i] = true
+			return field, true
+		}
+	}
+	return onePUXField{}, false
+}
+
+// take returns the text of the field with the given ID and marks it as used
+func (f *onePUXFields) take(id string) string {
+	field, _ := f.takeField(id)
+	return field.Value.text()
+}
+
+// peek returns the text of the field with the given ID without using it
+func (f *onePUXFields) peek(id string) string {
+	for i, field := range f.fields {
+		if !f.used[i] && field.ID == id {
+			return field.Value.text()
+		}
+	}
+	return ""
+}
+
+// remaining adds the unused fields with a value to password as custom fields and returns
+// the files they attach
+func (f *onePUXFields) remaining(password *services.Password) []onePUXFile {
+	var files []onePUXFile
+	for i, field := range f.fields {
+		if f.used[i] {
+			continue
+		}
+		kind, raw := field.Value.kind()
+		if kind == "file" {
+			var file onePUXFile
+			if json.Unmarshal(raw, &file) == nil {
+				files = append(files, file)
+			}
+			continue
+		}
+		value := field.Value.text()
+		if value == "" {
+			continue
+		}
+		name := field.Title
+		if name == "" {
+			name = field.ID
+		}
+		fieldType := services.FieldTypeText
+		switch kind {
+		case "concealed", "creditCardNumber", "sshKey":
+			fieldType = services.FieldTypeHidden
+		case "totp":
+			if password.TOTP == "" && password.Type == services.EntryTypeLogin {
+				password.TOTP = value
+				continue
+			}
+			name, fieldType = "totp", services.FieldTypeHidden
+		case "url":
+			fieldType = services.FieldTypeURL
+		}
+		if strings.TrimSpace(name) == "" {
+			name = kind
+		}
+		password.Fields = append(password.Fields, services.CustomField{Name: name, Type: fieldType, Value: value})
+	}
+	return files
+}
+
+// readZipFile reads an archive member, charging its size to the remaining budget
+func readZipFile(file *zip.File, budget *int64) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(io.LimitReader(rc, *budget+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	if int64(len(content)) > *budget {
+		return nil, fmt.Errorf("%w: archive content exceeds %d bytes", ErrInvalidImport, maxExtractedSize)
+	}
+	*budget -= int64(len(content))
+	return content, nil
+}
+
+// splitFullName splits a full name at its last space into first and last name
+func splitFullName(name string) (first, last string) {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndexByte(name, ' '); i >= 0 {
+		return strings.TrimSpace(name[:i]), name[i+1:]
+	}
+	return "", name
+}
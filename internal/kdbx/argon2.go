@@ -0,0 +1,295 @@
+package kdbx
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Argon2 variants used by KeePass. golang.org/x/crypto/argon2 has no Argon2d, which is the
+// KeePass default, so both variants KeePass supports are implemented here following RFC 9106.
+const (
+	argon2d  = 0
+	argon2id = 2
+)
+
+const (
+	argon2Version   = 0x13
+	argon2BlockSize = 128 // 64-bit words per 1 KiB block
+	argon2SyncSteps = 4   // slices per pass
+)
+
+type argon2Block [argon2BlockSize]uint64
+
+// argon2Params are the KDF parameters stored in a KDBX header
+type argon2Params struct {
+	variant     int
+	salt        []byte
+	secret      []byte
+	assocData   []byte
+	iterations  uint32
+	memory      uint32 // KiB
+	parallelism uint32
+}
+
+// argon2Key derives a keyLen-byte key from password
+func argon2Key(password []byte, p argon2Params, keyLen uint32) []byte {
+	h0 := argon2InitHash(password, p, keyLen)
+
+	// Memory is rounded down to a multiple of 4 blocks per lane
+	memory := p.memory / (argon2SyncSteps * p.parallelism) * (argon2SyncSteps * p.parallelism)
+	if memory < 2*argon2SyncSteps*p.parallelism {
+		memory = 2 * argon2SyncSteps * p.parallelism
+	}
+	laneLength := memory / p.parallelism
+	segmentLength := laneLength / argon2SyncSteps
+
+	blocks := make([]argon2Block, memory)
+	var seed [blake2b.Size + 8]byte
+	copy(seed[:], h0)
+	for lane := uint32(0); lane < p.parallelism; lane++ {
+		offset := lane * laneLength
+		binary.LittleEndian.PutUint32(seed[blake2b.Size+4:], lane)
+		for i := uint32(0); i < 2; i++ {
+			binary.LittleEndian.PutUint32(seed[blake2b.Size:], i)
+			var buf [1024]byte
+			argon2Hash(buf[:], seed[:])
+			for j := range blocks[offset+i] {
+				blocks[offset+i][j] = binary.LittleEndian.Uint64(buf[j*8:])
+			}
+		}
+	}
+
+	for pass := uint32(0); pass < p.iterations; pass++ {
+		for slice := uint32(0); slice < argon2SyncSteps; slice++ {
+			var wg sync.WaitGroup
+			for lane := uint32(0); lane < p.parallelism; lane++ {
+				wg.Add(1)
+				go func(lane uint32) {
+					defer wg.Done()
+					argon2FillSegment(blocks, p, memory, laneLength, segmentLength, pass, slice, lane)
+				}(lane)
+			}
+			wg.Wait()
+		}
+	}
+
+	final := blocks[laneLength-1]
+	for lane := uint32(1); lane < p.parallelism; lane++ {
+		last := &blocks[lane*laneLength+laneLength-1]
+		for i := range final {
+			final[i] ^= last[i]
+		}
+	}
+	var buf [1024]byte
+	for i, v := range final {
+		binary.LittleEndian.PutUint64(buf[i*8:], v)
+	}
+	key := make([]byte, keyLen)
+	argon2Hash(key, buf[:])
+	return key
+}
+
+// argon2InitHash computes H0 over the parameters and inputs
+func argon2InitHash(password []byte, p argon2Params, keyLen uint32) []byte {
+	h, _ := blake2b.New512(nil)
+	var word [4]byte
+	writeWord := func(v uint32) {
+		binary.LittleEndian.PutUint32(word[:], v)
+		h.Write(word[:])
+	}
+	writeBytes := func(b []byte) {
+		writeWord(uint32(len(b)))
+		h.Write(b)
+	}
+	writeWord(p.parallelism)
+	writeWord(keyLen)
+	writeWord(p.memory)
+	writeWord(p.iterations)
+	writeWord(argon2Version)
+	writeWord(uint32(p.variant))
+	writeBytes(password)
+	writeBytes(p.salt)
+	writeBytes(p.secret)
+	writeBytes(p.assocData)
+	return h.Sum(nil)
+}
+
+// argon2Hash is the variable-length hash H' used to expand and compress blocks
+func argon2Hash(out, in []byte) {
+	var prefix [4]byte
+	binary.LittleEndian.PutUint32(prefix[:], uint32(len(out)))
+
+	if len(out) <= blake2b.Size {
+		h, _ := blake2b.New(len(out), nil)
+		h.Write(prefix[:])
+		h.Write(in)
+		h.Sum(out[:0])
+		return
+	}
+
+	h, _ := blake2b.New512(nil)
+	h.Write(prefix[:])
+	h.Write(in)
+	v := h.Sum(nil)
+	copy(out, v[:32])
+	n := 32
+	for len(out)-n > blake2b.Size {
+		v = blake2bSum(h, v)
+		copy(out[n:], v[:32])
+		n += 32
+	}
+	last, _ := blake2b.New(len(out)-n, nil)
+	last.Write(v)
+	last.Sum(out[n:n])
+}
+
+func blake2bSum(h hash.Hash, in []byte) []byte {
+	h.Reset()
+	h.Write(in)
+	return h.Sum(nil)
+}
+
+// argon2FillSegment computes the blocks of one lane within one slice of a pass
+func argon2FillSegment(blocks []argon2Block, p argon2Params, memory, laneLength, segmentLength, pass, slice, lane uint32) {
+	dataIndependent := p.variant == argon2id && pass == 0 && slice < argon2SyncSteps/2
+
+	var address, input, zero argon2Block
+	if dataIndependent {
+		input[0] = uint64(pass)
+		input[1] = uint64(lane)
+		input[2] = uint64(slice)
+		input[3] = uint64(memory)
+		input[4] = uint64(p.iterations)
+		input[5] = uint64(p.variant)
+	}
+
+	start := uint32(0)
+	if pass == 0 && slice == 0 {
+		// The first two blocks of each lane come from H0
+		start = 2
+		if dataIndependent {
+			input[6]++
+			argon2NextAddresses(&address, &input, &zero)
+		}
+	}
+
+	offset := lane*laneLength + slice*segmentLength + start
+	for index := start; index < segmentLength; index, offset = index+1, offset+1 {
+		prev := offset - 1
+		if offset%laneLength == 0 {
+			prev = offset + laneLength - 1
+		}
+
+		var random uint64
+		if dataIndependent {
+			if index%argon2BlockSize == 0 {
+				input[6]++
+				argon2NextAddresses(&address, &input, &zero)
+			}
+			random = address[index%argon2BlockSize]
+		} else {
+			random = blocks[prev][0]
+		}
+
+		refLane := uint32(random>>32) % p.parallelism
+		if pass == 0 && slice == 0 {
+			refLane = lane
+		}
+		refIndex := argon2RefIndex(uint32(random), pass, slice, index, laneLength, segmentLength, refLane == lane)
+		argon2Compress(&blocks[offset], &blocks[prev], &blocks[refLane*laneLength+refIndex], pass > 0)
+	}
+}
+
+// argon2NextAddresses computes the next block of pseudo-random reference addresses
+func argon2NextAddresses(address, input, zero *argon2Block) {
+	var tmp argon2Block
+	argon2Compress(&tmp, zero, input, false)
+	argon2Compress(address, zero, &tmp, false)
+}
+
+// argon2RefIndex maps the pseudo-random value to a block index within the reference lane
+func argon2RefIndex(rand, pass, slice, index, laneLength, segmentLength uint32, sameLane bool) uint32 {
+	var area uint32
+	switch {
+	case pass == 0 && sameLane:
+		area = slice*segmentLength + index - 1
+	case pass == 0:
+		area = slice * segmentLength
+		if index == 0 {
+			area--
+		}
+	case sameLane:
+		area = laneLength - segmentLength + index - 1
+	default:
+		area = laneLength - segmentLength
+		if index == 0 {
+			area--
+		}
+	}
+
+	x := uint64(rand) * uint64(rand) >> 32
+	y := uint64(area) * x >> 32
+	relative := uint64(area) - 1 - y
+
+	var startPos uint64
+	if pass != 0 && slice != argon2SyncSteps-1 {
+		startPos = uint64((slice + 1) * segmentLength)
+	}
+	return uint32((startPos + relative) % uint64(laneLength))
+}
+
+// argon2Compress is the compression function G. From the second pass on the result is
+// XORed into the existing block instead of replacing it.
+func argon2Compress(out, x, y *argon2Block, xor bool) {
+	var r, z argon2Block
+	for i := range r {
+		r[i] = x[i] ^ y[i]
+	}
+	z = r
+	for i := 0; i < 8; i++ {
+		row := z[16*i : 16*i+16]
+		blamkaRound(&row[0], &row[1], &row[2], &row[3], &row[4], &row[5], &row[6], &row[7],
+			&row[8], &row[9], &row[10], &row[11], &row[12], &row[13], &row[14], &row[15])
+	}
+	for i := 0; i < 8; i++ {
+		c := 2 * i
+		blamkaRound(&z[c], &z[c+1], &z[c+16], &z[c+17], &z[c+32], &z[c+33], &z[c+48], &z[c+49],
+			&z[c+64], &z[c+65], &z[c+80], &z[c+81], &z[c+96], &z[c+97], &z[c+112], &z[c+113])
+	}
+	if xor {
+		for i := range out {
+			out[i] ^= z[i] ^ r[i]
+		}
+		return
+	}
+	for i := range out {
+		out[i] = z[i] ^ r[i]
+	}
+}
+
+// blamkaRound is the BLAKE2b round with multiplication-hardened additions
+func blamkaRound(v0, v1, v2, v3, v4, v5, v6, v7, v8, v9, v10, v11, v12, v13, v14, v15 *uint64) {
+	blamkaG(v0, v4, v8, v12)
+	blamkaG(v1, v5, v9, v13)
+	blamkaG(v2, v6, v10, v14)
+	blamkaG(v3, v7, v11, v15)
+	blamkaG(v0, v5, v10, v15)
+	blamkaG(v1, v6, v11, v12)
+	blamkaG(v2, v7, v8, v13)
+	blamkaG(v3, v4, v9, v14)
+}
+
+func blamkaG(a, b, c, d *uint64) {
+	*a += *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
+	*d = bits.RotateLeft64(*d^*a, -32)
+	*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+	*b = bits.RotateLeft64(*b^*c, -24)
+	*a += *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
+	*d = bits.RotateLeft64(*d^*a, -16)
+	*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+	*b = bits.RotateLeft64(*b^*c, -63)
+}
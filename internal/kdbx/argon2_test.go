@@ -0,0 +1,67 @@
+package kdbx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// rfc9106Params are the inputs shared by the test vectors in RFC 9106, section 5
+func rfc9106Params(variant int) argon2Params {
+	return argon2Params{
+		variant:     variant,
+		salt:        bytes.Repeat([]byte{0x02}, 16),
+		secret:      bytes.Repeat([]byte{0x03}, 8),
+		assocData:   bytes.Repeat([]byte{0x04}, 12),
+		iterations:  3,
+		memory:      32,
+		parallelism: 4,
+	}
+}
+
+func TestArgon2RFC9106(t *testing.T) {
+	tests := []struct {
+		name    string
+		variant int
+		tag     string
+	}{
+		{"Argon2d", argon2d, "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb"},
+		{"Argon2id", argon2id, "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659"},
+	}
+	password := bytes.Repeat([]byte{0x01}, 32)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hex.EncodeToString(argon2Key(password, rfc9106Params(tt.variant), 32))
+			if got != tt.tag {
+				t.Errorf("tag = %s, want %s", got, tt.tag)
+			}
+		})
+	}
+}
+
+// TestArgon2idMatchesXCrypto compares Argon2id against golang.org/x/crypto/argon2, which
+// supports neither a secret nor associated data
+func TestArgon2idMatchesXCrypto(t *testing.T) {
+	tests := []struct {
+		memory, iterations uint32
+		lanes              uint8
+	}{
+		{64, 1, 1},
+		{256, 2, 2},
+		{1024, 3, 4},
+		// Memory that is not a multiple of 4 blocks per lane is rounded down
+		{1000, 2, 3},
+	}
+	password := []byte("correct horse battery staple")
+	salt := []byte("0123456789abcdef")
+	for _, tt := range tests {
+		p := argon2Params{variant: argon2id, salt: salt, iterations: tt.iterations, memory: tt.memory, parallelism: uint32(tt.lanes)}
+		got := argon2Key(password, p, 32)
+		want := argon2.IDKey(password, salt, tt.iterations, tt.memory, tt.lanes, 32)
+		if !bytes.Equal(got, want) {
+			t.Errorf("m=%d t=%d p=%d: key = %x, want %x", tt.memory, tt.iterations, tt.lanes, got, want)
+		}
+	}
+}
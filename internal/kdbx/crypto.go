@@ -0,0 +1,287 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20/salsa"
+)
+
+// Outer ciphers
+var (
+	cipherAES256   = [16]byte{0x31, 0xc1, 0xf2, 0xe6, 0xbf, 0x71, 0x43, 0x50, 0xbe, 0x58, 0x05, 0x21, 0x6a, 0xfc, 0x5a, 0xff}
+	cipherChaCha20 = [16]byte{0xd6, 0x03, 0x8a, 0x2b, 0x8b, 0x6f, 0x4c, 0xb5, 0xa5, 0x24, 0x33, 0x9a, 0x31, 0xdb, 0xb5, 0x9a}
+)
+
+// Key derivation functions
+var (
+	kdfAESKDF   = [16]byte{0xc9, 0xd9, 0xf3, 0x9a, 0x62, 0x8a, 0x44, 0x60, 0xbf, 0x74, 0x0d, 0x08, 0xc1, 0x8a, 0x4f, 0xea}
+	kdfArgon2d  = [16]byte{0xef, 0x63, 0x6d, 0xdf, 0x8c, 0x29, 0x44, 0x4b, 0x91, 0xf7, 0xa9, 0xa4, 0x03, 0xe3, 0x0a, 0x0c}
+	kdfArgon2id = [16]byte{0x9e, 0x29, 0x8b, 0x19, 0x56, 0xdb, 0x47, 0x73, 0xb2, 0x3d, 0xfc, 0x3e, 0xc6, 0xf0, 0xa1, 0xe6}
+)
+
+// Inner random streams that protect values inside the XML
+const (
+	innerStreamSalsa20  = 2
+	innerStreamChaCha20 = 3
+)
+
+// salsa20Nonce is the fixed nonce of the Salsa20 inner stream
+var salsa20Nonce = [8]byte{0xe8, 0x30, 0x09, 0x4b, 0x97, 0x20, 0x5d, 0x2a}
+
+// Limits on KDF parameters so a hostile file cannot exhaust the server
+const (
+	maxArgon2Memory     = 1 << 30 // bytes
+	maxArgon2Iterations = 100
+	maxArgon2Lanes      = 16
+	maxAESKDFRounds     = 100_000_000
+)
+
+// compositeKey hashes the password the way KeePass combines key components
+func compositeKey(password string) []byte {
+	passwordHash := sha256.Sum256([]byte(password))
+	key := sha256.Sum256(passwordHash[:])
+	return key[:]
+}
+
+// transformKey runs the key derivation function described by params over the composite key
+func transformKey(composite []byte, params variantDictionary) ([]byte, error) {
+	id, ok := params.bytes("$UUID")
+	if !ok || len(id) != 16 {
+		return nil, fmt.Errorf("%w: missing KDF", ErrCorrupt)
+	}
+
+	switch [16]byte(id) {
+	case kdfAESKDF:
+		seed, ok := params.bytes("S")
+		rounds, ok2 := params.uint64("R")
+		if !ok || !ok2 || len(seed) != 32 {
+			return nil, fmt.Errorf("%w: invalid AES-KDF parameters", ErrCorrupt)
+		}
+		if rounds > maxAESKDFRounds {
+			return nil, fmt.Errorf("%w: AES-KDF rounds exceed %d", ErrUnsupported, maxAESKDFRounds)
+		}
+		return aesKDF(composite, seed, rounds)
+
+	case kdfArgon2d, kdfArgon2id:
+		p := argon2Params{variant: argon2d}
+		if [16]byte(id) == kdfArgon2id {
+			p.variant = argon2id
+		}
+		var ok [5]bool
+		var memory, iterations uint64
+		var version, lanes uint32
+		p.salt, ok[0] = params.bytes("S")
+		memory, ok[1] = params.uint64("M")
+		iterations, ok[2] = params.uint64("I")
+		lanes, ok[3] = params.uint32("P")
+		version, ok[4] = params.uint32("V")
+		for _, present := range ok {
+			if !present {
+				return nil, fmt.Errorf("%w: invalid Argon2 parameters", ErrCorrupt)
+			}
+		}
+		if version != argon2Version {
+			return nil, fmt.Errorf("%w: Argon2 version %#x", ErrUnsupported, version)
+		}
+		if memory > maxArgon2Memory || iterations > maxArgon2Iterations || lanes > maxArgon2Lanes {
+			return nil, fmt.Errorf("%w: Argon2 parameters exceed the supported limits", ErrUnsupported)
+		}
+		if memory < 8<<10*uint64(lanes) || iterations < 1 || lanes < 1 {
+			return nil, fmt.Errorf("%w: invalid Argon2 parameters", ErrCorrupt)
+		}
+		p.memory, p.iterations, p.parallelism = uint32(memory>>10), uint32(iterations), lanes
+		p.secret, _ = params.bytes("K")
+		p.assocData, _ = params.bytes("A")
+		return argon2Key(composite, p, 32), nil
+
+	default:
+		return nil, fmt.Errorf("%w: unknown KDF", ErrUnsupported)
+	}
+}
+
+// aesKDF encrypts both halves of the key rounds times with AES-256 keyed by seed
+func aesKDF(key, seed []byte, rounds uint64) ([]byte, error) {
+	block, err := aes.NewCipher(seed)
+	if err != nil {
+		return nil, err
+	}
+	out := bytes.Clone(key)
+	for i := uint64(0); i < rounds; i++ {
+		block.Encrypt(out[:16], out[:16])
+		block.Encrypt(out[16:], out[16:])
+	}
+	sum := sha256.Sum256(out)
+	return sum[:], nil
+}
+
+// fileKeys holds the keys derived from the master seed and the transformed key
+type fileKeys struct {
+	cipherKey []byte
+	hmacKey   []byte
+}
+
+func deriveKeys(masterSeed, transformed []byte) fileKeys {
+	cipherKey := sha256.Sum256(append(bytes.Clone(masterSeed), transformed...))
+	hmacKey := sha512.Sum512(append(append(bytes.Clone(masterSeed), transformed...), 0x01))
+	return fileKeys{cipherKey: cipherKey[:], hmacKey: hmacKey[:]}
+}
+
+// headerBlockIndex is the block index used to authenticate the header
+const headerBlockIndex = ^uint64(0)
+
+// blockHMAC computes the HMAC of a payload block; the header uses headerBlockIndex
+func (k fileKeys) blockHMAC(index uint64, data []byte, withSize bool) []byte {
+	var indexBytes [8]byte
+	binary.LittleEndian.PutUint64(indexBytes[:], index)
+	key := sha512.Sum512(append(indexBytes[:], k.hmacKey...))
+
+	mac := hmac.New(sha256.New, key[:])
+	if withSize {
+		var sizeBytes [4]byte
+		binary.LittleEndian.PutUint32(sizeBytes[:], uint32(len(data)))
+		mac.Write(indexBytes[:])
+		mac.Write(sizeBytes[:])
+	}
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// blockSize is the payload block size used when writing
+const blockSize = 1 << 20
+
+// readBlocks verifies and joins the HMAC-authenticated payload blocks
+func (k fileKeys) readBlocks(r *bytes.Reader) ([]byte, error) {
+	var payload bytes.Buffer
+	for index := uint64(0); ; index++ {
+		var mac [32]byte
+		var size int32
+		if _, err := io.ReadFull(r, mac[:]); err != nil {
+			return nil, fmt.Errorf("%w: truncated block", ErrCorrupt)
+		}
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil || size < 0 || int64(size) > int64(r.Len()) {
+			return nil, fmt.Errorf("%w: invalid block size", ErrCorrupt)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("%w: truncated block", ErrCorrupt)
+		}
+		if !hmac.Equal(mac[:], k.blockHMAC(index, data, true)) {
+			return nil, fmt.Errorf("%w: block %d failed authentication", ErrCorrupt, index)
+		}
+		if size == 0 {
+			return payload.Bytes(), nil
+		}
+		payload.Write(data)
+	}
+}
+
+// writeBlocks splits the payload into HMAC-authenticated blocks followed by an empty block
+func (k fileKeys) writeBlocks(w io.Writer, payload []byte) error {
+	for index := uint64(0); ; index++ {
+		n := min(len(payload), blockSize)
+		data := payload[:n]
+		payload = payload[n:]
+		if _, err := w.Write(k.blockHMAC(index, data, true)); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, int32(n)); err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+// decryptPayload decrypts the joined payload blocks with the outer cipher
+func decryptPayload(cipherID [16]byte, key, iv, data []byte) ([]byte, error) {
+	switch cipherID {
+	case cipherAES256:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(iv) != aes.BlockSize || len(data)%aes.BlockSize != 0 || len(data) == 0 {
+			return nil, fmt.Errorf("%w: invalid AES payload", ErrCorrupt)
+		}
+		plain := make([]byte, len(data))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+		padding := int(plain[len(plain)-1])
+		if padding == 0 || padding > aes.BlockSize {
+			return nil, fmt.Errorf("%w: invalid padding", ErrCorrupt)
+		}
+		return plain[:len(plain)-padding], nil
+
+	case cipherChaCha20:
+		stream, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCorrupt, err)
+		}
+		plain := make([]byte, len(data))
+		stream.XORKeyStream(plain, data)
+		return plain, nil
+
+	default:
+		return nil, fmt.Errorf("%w: unknown cipher", ErrUnsupported)
+	}
+}
+
+// encryptPayload encrypts the payload with ChaCha20, the only cipher used when writing
+func encryptPayload(key, iv, data []byte) ([]byte, error) {
+	stream, err := chacha20.NewUnauthenticatedCipher(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	stream.XORKeyStream(out, data)
+	return out, nil
+}
+
+// newInnerStream creates the stream that encrypts protected XML values
+func newInnerStream(id uint32, key []byte) (cipher.Stream, error) {
+	switch id {
+	case innerStreamChaCha20:
+		hash := sha512.Sum512(key)
+		return chacha20.NewUnauthenticatedCipher(hash[:32], hash[32:44])
+	case innerStreamSalsa20:
+		return &salsa20Stream{key: sha256.Sum256(key), used: 64}, nil
+	default:
+		return nil, fmt.Errorf("%w: inner stream %d", ErrUnsupported, id)
+	}
+}
+
+// salsa20Stream is a Salsa20 key stream that keeps its position across calls
+type salsa20Stream struct {
+	key     [32]byte
+	counter uint64
+	block   [64]byte
+	used    int
+}
+
+func (s *salsa20Stream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.used == len(s.block) {
+			var input [16]byte
+			copy(input[:8], salsa20Nonce[:])
+			binary.LittleEndian.PutUint64(input[8:], s.counter)
+			var zero [64]byte
+			salsa.XORKeyStream(s.block[:], zero[:], &input, &s.key)
+			s.counter++
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.block[s.used]
+		s.used++
+	}
+}
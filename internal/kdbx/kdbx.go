@@ -0,0 +1,348 @@
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrNotKDBX         = errors.New("not a KeePass database")
+	ErrUnsupported     = errors.New("unsupported KeePass database")
+	ErrInvalidPassword = errors.New("wrong password or damaged KeePass database")
+	ErrCorrupt         = errors.New("damaged KeePass database")
+)
+
+// File signature and versions
+const (
+	signature1   = 0x9aa2d903
+	signature2   = 0xb54bfb67
+	majorVersion = 4
+	// writeVersion is KDBX 4.0
+	writeVersion = majorVersion << 16
+)
+
+// Outer header field IDs
+const (
+	headerEnd           = 0
+	headerCipherID      = 2
+	headerCompression   = 3
+	headerMasterSeed    = 4
+	headerEncryptionIV  = 7
+	headerKdfParameters = 11
+)
+
+// Inner header field IDs
+const (
+	innerHeaderEnd       = 0
+	innerHeaderStreamID  = 1
+	innerHeaderStreamKey = 2
+	innerHeaderBinary    = 3
+)
+
+const compressionGzip = 1
+
+// maxDecompressedLength limits the size of the decompressed payload
+const maxDecompressedLength = 256 << 20
+
+// Argon2id settings used when writing, in line with the KeePassXC defaults
+const (
+	writeArgon2Memory     = 64 << 20 // bytes
+	writeArgon2Iterations = 2
+	writeArgon2Lanes      = 2
+)
+
+// Database is a decrypted KDBX 4 database.
+type Database struct {
+	Meta Meta
+	Root Group
+	// Binaries holds attachment content; entries refer to them by index
+	Binaries [][]byte
+}
+
+// Read decrypts a KDBX 4 database with a password. Key files are not supported.
+func Read(r io.Reader, password string) (*Database, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	reader := bytes.NewReader(data)
+
+	var sig1, sig2, version uint32
+	for _, v := range []*uint32{&sig1, &sig2, &version} {
+		if err := binary.Read(reader, binary.LittleEndian, v); err != nil {
+			return nil, ErrNotKDBX
+		}
+	}
+	if sig1 != signature1 || sig2 != signature2 {
+		return nil, ErrNotKDBX
+	}
+	if version>>16 != majorVersion {
+		return nil, fmt.Errorf("%w: KDBX %d.%d, only KDBX 4 is supported", ErrUnsupported, version>>16, version&0xffff)
+	}
+
+	fields, err := readFields(reader)
+	if err != nil {
+		return nil, err
+	}
+	header := data[:len(data)-reader.Len()]
+
+	var headerHash, headerMAC [32]byte
+	if _, err := io.ReadFull(reader, headerHash[:]); err != nil {
+		return nil, fmt.Errorf("%w: truncated header", ErrCorrupt)
+	}
+	if _, err := io.ReadFull(reader, headerMAC[:]); err != nil {
+		return nil, fmt.Errorf("%w: truncated header", ErrCorrupt)
+	}
+	if sum := sha256.Sum256(header); !hmac.Equal(sum[:], headerHash[:]) {
+		return nil, fmt.Errorf("%w: header checksum mismatch", ErrCorrupt)
+	}
+
+	cipherID, seed, iv := fields[headerCipherID], fields[headerMasterSeed], fields[headerEncryptionIV]
+	if len(cipherID) != 16 || len(seed) != 32 || len(iv) == 0 {
+		return nil, fmt.Errorf("%w: incomplete header", ErrCorrupt)
+	}
+	kdfParams, err := parseVariantDictionary(fields[headerKdfParameters])
+	if err != nil {
+		return nil, err
+	}
+	transformed, err := transformKey(compositeKey(password), kdfParams)
+	if err != nil {
+		return nil, err
+	}
+	keys := deriveKeys(seed, transformed)
+	if !hmac.Equal(headerMAC[:], keys.blockHMAC(headerBlockIndex, header, false)) {
+		return nil, ErrInvalidPassword
+	}
+
+	encrypted, err := keys.readBlocks(reader)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := decryptPayload([16]byte(cipherID), keys.cipherKey, iv, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if compression := fields[headerCompression]; len(compression) == 4 && binary.LittleEndian.Uint32(compression) == compressionGzip {
+		if payload, err = gunzip(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	inner := bytes.NewReader(payload)
+	db := &Database{}
+	streamID, streamKey, err := readInnerHeader(inner, db)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := newInnerStream(streamID, streamKey)
+	if err != nil {
+		return nil, err
+	}
+	file, err := decodeXML(payload[len(payload)-inner.Len():], stream)
+	if err != nil {
+		return nil, err
+	}
+	db.Meta, db.Root = file.Meta, file.Root.Group
+	return db, nil
+}
+
+// Write encrypts the database with a password as KDBX 4.0, using Argon2id, ChaCha20 and
+// gzip compression so that KeePass 2.35+ and KeePassXC 2.3+ can open it.
+func Write(w io.Writer, db *Database, password string) error {
+	seed, err := randomBytes(32)
+	if err != nil {
+		return err
+	}
+	iv, err := randomBytes(12)
+	if err != nil {
+		return err
+	}
+	salt, err := randomBytes(32)
+	if err != nil {
+		return err
+	}
+	streamKey, err := randomBytes(64)
+	if err != nil {
+		return err
+	}
+
+	var kdfParams variantDictionary
+	kdfParams.setBytes("$UUID", kdfArgon2id[:])
+	kdfParams.setBytes("S", salt)
+	kdfParams.setUint32("P", writeArgon2Lanes)
+	kdfParams.setUint64("M", writeArgon2Memory)
+	kdfParams.setUint64("I", writeArgon2Iterations)
+	kdfParams.setUint32("V", argon2Version)
+
+	var compression [4]byte
+	binary.LittleEndian.PutUint32(compression[:], compressionGzip)
+
+	var header bytes.Buffer
+	for _, v := range []uint32{signature1, signature2, writeVersion} {
+		binary.Write(&header, binary.LittleEndian, v)
+	}
+	writeField(&header, headerCipherID, cipherChaCha20[:])
+	writeField(&header, headerCompression, compression[:])
+	writeField(&header, headerMasterSeed, seed)
+	writeField(&header, headerEncryptionIV, iv)
+	writeField(&header, headerKdfParameters, kdfParams.encode())
+	writeField(&header, headerEnd, []byte("\r\n\r\n"))
+
+	transformed, err := transformKey(compositeKey(password), kdfParams)
+	if err != nil {
+		return err
+	}
+	keys := deriveKeys(seed, transformed)
+
+	// Inner header and XML
+	var inner bytes.Buffer
+	var streamID [4]byte
+	binary.LittleEndian.PutUint32(streamID[:], innerStreamChaCha20)
+	writeField(&inner, innerHeaderStreamID, streamID[:])
+	writeField(&inner, innerHeaderStreamKey, streamKey)
+	for _, binaryData := range db.Binaries {
+		writeField(&inner, innerHeaderBinary, append([]byte{0}, binaryData...))
+	}
+	writeField(&inner, innerHeaderEnd, nil)
+
+	stream, err := newInnerStream(innerStreamChaCha20, streamKey)
+	if err != nil {
+		return err
+	}
+	meta := db.Meta
+	meta.MemoryProtection.ProtectPassword = true
+	document, err := encodeXML(xmlFile{Meta: meta, Root: xmlRoot{Group: db.Root}}, stream)
+	if err != nil {
+		return fmt.Errorf("failed to encode database: %w", err)
+	}
+	inner.Write(document)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(inner.Bytes()); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	encrypted, err := encryptPayload(keys.cipherKey, iv, compressed.Bytes())
+	if err != nil {
+		return err
+	}
+
+	headerHash := sha256.Sum256(header.Bytes())
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	if _, err := w.Write(headerHash[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(keys.blockHMAC(headerBlockIndex, header.Bytes(), false)); err != nil {
+		return err
+	}
+	return keys.writeBlocks(w, encrypted)
+}
+
+// readFields reads the outer header fields up to the end marker
+func readFields(r *bytes.Reader) (map[byte][]byte, error) {
+	fields := make(map[byte][]byte)
+	for {
+		id, data, err := readField(r)
+		if err != nil {
+			return nil, err
+		}
+		if id == headerEnd {
+			return fields, nil
+		}
+		fields[id] = data
+	}
+}
+
+// readField reads one header field with a 32-bit length
+func readField(r *bytes.Reader) (byte, []byte, error) {
+	id, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: truncated header", ErrCorrupt)
+	}
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil || int64(size) > int64(r.Len()) {
+		return 0, nil, fmt.Errorf("%w: invalid header field", ErrCorrupt)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, fmt.Errorf("%w: truncated header", ErrCorrupt)
+	}
+	return id, data, nil
+}
+
+// readInnerHeader reads the inner header, collecting binaries into db, and returns the
+// inner random stream settings
+func readInnerHeader(r *bytes.Reader, db *Database) (uint32, []byte, error) {
+	var streamID uint32
+	var streamKey []byte
+	for {
+		id, data, err := readField(r)
+		if err != nil {
+			return 0, nil, err
+		}
+		switch id {
+		case innerHeaderEnd:
+			if streamKey == nil {
+				return 0, nil, fmt.Errorf("%w: missing inner stream key", ErrCorrupt)
+			}
+			return streamID, streamKey, nil
+		case innerHeaderStreamID:
+			if len(data) != 4 {
+				return 0, nil, fmt.Errorf("%w: invalid inner stream", ErrCorrupt)
+			}
+			streamID = binary.LittleEndian.Uint32(data)
+		case innerHeaderStreamKey:
+			streamKey = data
+		case innerHeaderBinary:
+			if len(data) == 0 {
+				return 0, nil, fmt.Errorf("%w: invalid binary", ErrCorrupt)
+			}
+			// The first byte holds flags, such as whether the binary is protected in memory
+			db.Binaries = append(db.Binaries, data[1:])
+		}
+	}
+}
+
+// writeField writes one header field
+func writeField(w *bytes.Buffer, id byte, data []byte) {
+	w.WriteByte(id)
+	binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+}
+
+// gunzip decompresses the payload, refusing output larger than maxDecompressedLength
+func gunzip(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+	defer gz.Close()
+	out, err := io.ReadAll(io.LimitReader(gz, maxDecompressedLength+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+	if len(out) > maxDecompressedLength {
+		return nil, fmt.Errorf("%w: database is too large", ErrUnsupported)
+	}
+	return out, nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return b, nil
+}
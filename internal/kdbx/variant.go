@@ -0,0 +1,128 @@
+package kdbx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Variant dictionary value types
+const (
+	variantEnd       = 0x00
+	variantUint32    = 0x04
+	variantUint64    = 0x05
+	variantBool      = 0x08
+	variantInt32     = 0x0c
+	variantInt64     = 0x0d
+	variantString    = 0x18
+	variantByteArray = 0x42
+)
+
+const variantVersion = 0x0100
+
+// variantItem is one typed value of a variant dictionary
+type variantItem struct {
+	kind  byte
+	key   string
+	value []byte
+}
+
+// variantDictionary is the typed key-value list KDBX 4 uses for KDF parameters. Items keep
+// their order so that a dictionary is written back the way it was built.
+type variantDictionary []variantItem
+
+func parseVariantDictionary(data []byte) (variantDictionary, error) {
+	r := bytes.NewReader(data)
+	var version uint16
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil || version&0xff00 != variantVersion&0xff00 {
+		return nil, fmt.Errorf("%w: invalid KDF parameters", ErrCorrupt)
+	}
+
+	var dict variantDictionary
+	for {
+		kind, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: truncated KDF parameters", ErrCorrupt)
+		}
+		if kind == variantEnd {
+			return dict, nil
+		}
+		key, err := readVariantBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readVariantBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		dict = append(dict, variantItem{kind: kind, key: string(key), value: value})
+	}
+}
+
+func readVariantBytes(r *bytes.Reader) ([]byte, error) {
+	var size int32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil || size < 0 || int64(size) > int64(r.Len()) {
+		return nil, fmt.Errorf("%w: invalid KDF parameters", ErrCorrupt)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("%w: truncated KDF parameters", ErrCorrupt)
+	}
+	return data, nil
+}
+
+func (d variantDictionary) encode() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint16(variantVersion))
+	for _, item := range d {
+		buf.WriteByte(item.kind)
+		binary.Write(&buf, binary.LittleEndian, int32(len(item.key)))
+		buf.WriteString(item.key)
+		binary.Write(&buf, binary.LittleEndian, int32(len(item.value)))
+		buf.Write(item.value)
+	}
+	buf.WriteByte(variantEnd)
+	return buf.Bytes()
+}
+
+func (d variantDictionary) get(key string, kind byte) ([]byte, bool) {
+	for _, item := range d {
+		if item.key == key && item.kind == kind {
+			return item.value, true
+		}
+	}
+	return nil, false
+}
+
+func (d variantDictionary) bytes(key string) ([]byte, bool) {
+	return d.get(key, variantByteArray)
+}
+
+func (d variantDictionary) uint32(key string) (uint32, bool) {
+	value, ok := d.get(key, variantUint32)
+	if !ok || len(value) != 4 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(value), true
+}
+
+func (d variantDictionary) uint64(key string) (uint64, bool) {
+	value, ok := d.get(key, variantUint64)
+	if !ok || len(value) != 8 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(value), true
+}
+
+func (d *variantDictionary) setBytes(key string, value []byte) {
+	*d = append(*d, variantItem{kind: variantByteArray, key: key, value: value})
+}
+
+func (d *variantDictionary) setUint32(key string, value uint32) {
+	*d = append(*d, variantItem{kind: variantUint32, key: key, value: binary.LittleEndian.AppendUint32(nil, value)})
+}
+
+func (d *variantDictionary) setUint64(key string, value uint64) {
+	*d = append(*d, variantItem{kind: variantUint64, key: key, value: binary.LittleEndian.AppendUint64(nil, value)})
+}
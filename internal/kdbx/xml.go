@@ -0,0 +1,306 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Standard entry string keys
+const (
+	KeyTitle    = "Title"
+	KeyUserName = "UserName"
+	KeyPassword = "Password"
+	KeyURL      = "URL"
+	KeyNotes    = "Notes"
+)
+
+// xmlFile is the root element of the inner XML document
+type xmlFile struct {
+	XMLName xml.Name `xml:"KeePassFile"`
+	Meta    Meta     `xml:"Meta"`
+	Root    xmlRoot  `xml:"Root"`
+}
+
+type xmlRoot struct {
+	Group Group `xml:"Group"`
+}
+
+// Meta holds the database settings that matter when importing and exporting.
+type Meta struct {
+	Generator         string           `xml:"Generator"`
+	DatabaseName      string           `xml:"DatabaseName"`
+	MemoryProtection  MemoryProtection `xml:"MemoryProtection"`
+	RecycleBinEnabled Bool             `xml:"RecycleBinEnabled"`
+	RecycleBinUUID    UUID             `xml:"RecycleBinUUID"`
+}
+
+// MemoryProtection lists which standard strings are protected in memory.
+type MemoryProtection struct {
+	ProtectTitle    Bool `xml:"ProtectTitle"`
+	ProtectUserName Bool `xml:"ProtectUserName"`
+	ProtectPassword Bool `xml:"ProtectPassword"`
+	ProtectURL      Bool `xml:"ProtectURL"`
+	ProtectNotes    Bool `xml:"ProtectNotes"`
+}
+
+// Group is a folder of entries and subgroups.
+type Group struct {
+	UUID       UUID    `xml:"UUID"`
+	Name       string  `xml:"Name"`
+	Notes      string  `xml:"Notes"`
+	IconID     int     `xml:"IconID"`
+	Times      Times   `xml:"Times"`
+	IsExpanded Bool    `xml:"IsExpanded"`
+	Entries    []Entry `xml:"Entry"`
+	Groups     []Group `xml:"Group"`
+}
+
+// Entry is a single KeePass entry. History holds earlier versions, oldest first.
+type Entry struct {
+	UUID     UUID        `xml:"UUID"`
+	IconID   int         `xml:"IconID"`
+	Tags     string      `xml:"Tags"`
+	Times    Times       `xml:"Times"`
+	Strings  []String    `xml:"String"`
+	Binaries []BinaryRef `xml:"Binary"`
+	History  []Entry     `xml:"History>Entry"`
+}
+
+// Get returns the value of the string with the given key, or "" when it is missing
+func (e *Entry) Get(key string) string {
+	for _, s := range e.Strings {
+		if s.Key == key {
+			return s.Value.Content
+		}
+	}
+	return ""
+}
+
+// TagList splits the tags of an entry; KeePass separates them with ";" or ","
+func (e *Entry) TagList() []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(e.Tags, func(r rune) bool { return r == ';' || r == ',' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// String is a named entry value. Protected values are encrypted with the inner random
+// stream in the file; in memory they are always plaintext.
+type String struct {
+	Key   string `xml:"Key"`
+	Value Value  `xml:"Value"`
+}
+
+// Value is the content of a String.
+type Value struct {
+	Content   string `xml:",chardata"`
+	Protected Bool   `xml:"Protected,attr,omitempty"`
+}
+
+// BinaryRef attaches a binary from the inner header to an entry under a file name.
+type BinaryRef struct {
+	Key   string `xml:"Key"`
+	Value struct {
+		Ref int `xml:"Ref,attr"`
+	} `xml:"Value"`
+}
+
+// Times holds the timestamps of a group or entry.
+type Times struct {
+	CreationTime         Time `xml:"CreationTime"`
+	LastModificationTime Time `xml:"LastModificationTime"`
+	LastAccessTime       Time `xml:"LastAccessTime"`
+	ExpiryTime           Time `xml:"ExpiryTime"`
+	Expires              Bool `xml:"Expires"`
+	UsageCount           int  `xml:"UsageCount"`
+	LocationChanged      Time `xml:"LocationChanged"`
+}
+
+// NewTimes returns timestamps for an item created and last modified at the given times
+func NewTimes(created, modified time.Time) Times {
+	return Times{
+		CreationTime:         Time(created),
+		LastModificationTime: Time(modified),
+		LastAccessTime:       Time(modified),
+		ExpiryTime:           Time(modified),
+		LocationChanged:      Time(modified),
+	}
+}
+
+// Bool is a boolean written as True or False, the way KeePass does.
+type Bool bool
+
+func (b Bool) MarshalText() ([]byte, error) {
+	if b {
+		return []byte("True"), nil
+	}
+	return []byte("False"), nil
+}
+
+func (b *Bool) UnmarshalText(text []byte) error {
+	*b = Bool(strings.EqualFold(strings.TrimSpace(string(text)), "true"))
+	return nil
+}
+
+// UUID is a UUID written as base64 of its 16 bytes.
+type UUID uuid.UUID
+
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(base64.StdEncoding.EncodeToString(u[:])), nil
+}
+
+func (u *UUID) UnmarshalText(text []byte) error {
+	if len(bytes.TrimSpace(text)) == 0 {
+		*u = UUID{}
+		return nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(text)))
+	if err != nil || len(raw) != 16 {
+		return fmt.Errorf("%w: invalid UUID %q", ErrCorrupt, text)
+	}
+	copy(u[:], raw)
+	return nil
+}
+
+// epochOffset is the number of seconds from 0001-01-01, the origin of KDBX 4 timestamps, to
+// the Unix epoch
+const epochOffset = 62135596800
+
+// Time is a timestamp written as base64 of the little-endian seconds since year 1.
+// ISO 8601 timestamps from older writers are accepted when reading.
+type Time time.Time
+
+func (t Time) MarshalText() ([]byte, error) {
+	var raw [8]byte
+	binary.LittleEndian.PutUint64(raw[:], uint64(time.Time(t).Unix()+epochOffset))
+	return []byte(base64.StdEncoding.EncodeToString(raw[:])), nil
+}
+
+func (t *Time) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if s == "" {
+		*t = Time{}
+		return nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, s); err == nil {
+		*t = Time(parsed)
+		return nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(raw) != 8 {
+		return fmt.Errorf("%w: invalid time %q", ErrCorrupt, s)
+	}
+	seconds := int64(binary.LittleEndian.Uint64(raw))
+	*t = Time(time.Unix(seconds-epochOffset, 0).UTC())
+	return nil
+}
+
+// decodeXML parses the inner XML document, decrypting protected values with stream in
+// document order
+func decodeXML(data []byte, stream cipher.Stream) (xmlFile, error) {
+	var file xmlFile
+	reader := &protectedReader{decoder: xml.NewDecoder(bytes.NewReader(data)), stream: stream}
+	if err := xml.NewTokenDecoder(reader).Decode(&file); err != nil {
+		return xmlFile{}, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+	return file, nil
+}
+
+// encodeXML writes the inner XML document, encrypting protected values with stream in
+// document order
+func encodeXML(file xmlFile, stream cipher.Stream) ([]byte, error) {
+	plain, err := xml.Marshal(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	decoder := xml.NewDecoder(bytes.NewReader(plain))
+	protected := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			protected = isProtected(t)
+		case xml.EndElement:
+			protected = false
+		case xml.CharData:
+			if protected {
+				value := bytes.Clone(t)
+				stream.XORKeyStream(value, value)
+				token = xml.CharData(base64.StdEncoding.EncodeToString(value))
+			}
+		}
+		if err := encoder.EncodeToken(token); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// protectedReader decrypts the content of protected values as the document is decoded
+type protectedReader struct {
+	decoder   *xml.Decoder
+	stream    cipher.Stream
+	protected bool
+}
+
+func (r *protectedReader) Token() (xml.Token, error) {
+	token, err := r.decoder.Token()
+	if err != nil {
+		return token, err
+	}
+	token = xml.CopyToken(token)
+	switch t := token.(type) {
+	case xml.StartElement:
+		r.protected = isProtected(t)
+	case xml.EndElement:
+		r.protected = false
+	case xml.CharData:
+		if r.protected {
+			value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(t)))
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid protected value", ErrCorrupt)
+			}
+			r.stream.XORKeyStream(value, value)
+			return xml.CharData(value), nil
+		}
+	}
+	return token, nil
+}
+
+// isProtected reports whether an element is a Value with Protected="True"
+func isProtected(element xml.StartElement) bool {
+	if element.Name.Local != "Value" {
+		return false
+	}
+	for _, attr := range element.Attr {
+		if attr.Name.Local == "Protected" && strings.EqualFold(attr.Value, "true") {
+			return true
+		}
+	}
+	return false
+}
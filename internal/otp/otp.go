@@ -0,0 +1,187 @@
+// Package otp generates one-time passwords for two-factor authentication: time-based
+// codes (TOTP, RFC 6238) and counter-based codes (HOTP, RFC 4226). Keys are read from
+// otpauth:// URIs, as encoded in enrolment QR codes, or from bare base32 secrets.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidKey is returned for an otpauth:// URI or secret that cannot be used
+var ErrInvalidKey = errors.New("invalid one-time password key")
+
+// Key types
+const (
+	TypeTOTP = "totp"
+	TypeHOTP = "hotp"
+)
+
+// Algorithms
+const (
+	AlgorithmSHA1   = "SHA1"
+	AlgorithmSHA256 = "SHA256"
+	AlgorithmSHA512 = "SHA512"
+)
+
+// Defaults used by authenticator apps when a URI leaves a parameter out
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30
+)
+
+// Key is a one-time password key
+type Key struct {
+	Type      string
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	// Period is the lifetime of a TOTP code in seconds
+	Period int
+	// Counter is the next HOTP counter value
+	Counter uint64
+	Issuer  string
+	Account string
+}
+
+// Parse reads an otpauth:// URI or a base32 secret, which is taken as a TOTP key with the
+// default settings. Spaces and lowercase letters in secrets are accepted.
+func Parse(s string) (Key, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(strings.ToLower(s), "otpauth://") {
+		secret, err := decodeSecret(s)
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{Type: TypeTOTP, Secret: secret, Algorithm: AlgorithmSHA1, Digits: DefaultDigits, Period: DefaultPeriod}, nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	key := Key{Type: strings.ToLower(u.Host), Algorithm: AlgorithmSHA1, Digits: DefaultDigits, Period: DefaultPeriod}
+	if key.Type != TypeTOTP && key.Type != TypeHOTP {
+		return Key{}, fmt.Errorf("%w: unknown type %q", ErrInvalidKey, u.Host)
+	}
+
+	// The label is "issuer:account" or just the account
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		key.Issuer, key.Account = strings.TrimSpace(issuer), strings.TrimSpace(account)
+	} else {
+		key.Account = strings.TrimSpace(label)
+	}
+
+	query := u.Query()
+	if key.Secret, err = decodeSecret(query.Get("secret")); err != nil {
+		return Key{}, err
+	}
+	if issuer := query.Get("issuer"); issuer != "" {
+		key.Issuer = issuer
+	}
+	if v := query.Get("algorithm"); v != "" {
+		switch key.Algorithm = strings.ToUpper(v); key.Algorithm {
+		case AlgorithmSHA1, AlgorithmSHA256, AlgorithmSHA512:
+		default:
+			return Key{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidKey, v)
+		}
+	}
+	if v := query.Get("digits"); v != "" {
+		if key.Digits, err = strconv.Atoi(v); err != nil || key.Digits < 6 || key.Digits > 8 {
+			return Key{}, fmt.Errorf("%w: digits must be 6 to 8", ErrInvalidKey)
+		}
+	}
+	if v := query.Get("period"); v != "" {
+		if key.Period, err = strconv.Atoi(v); err != nil || key.Period <= 0 {
+			return Key{}, fmt.Errorf("%w: invalid period %q", ErrInvalidKey, v)
+		}
+	}
+	if key.Type == TypeHOTP {
+		v := query.Get("counter")
+		if v == "" {
+			return Key{}, fmt.Errorf("%w: hotp keys need a counter", ErrInvalidKey)
+		}
+		if key.Counter, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return Key{}, fmt.Errorf("%w: invalid counter %q", ErrInvalidKey, v)
+		}
+	}
+	return key, nil
+}
+
+func decodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(s))
+	s = strings.TrimRight(s, "=")
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil || len(secret) == 0 {
+		return nil, fmt.Errorf("%w: the secret is not base32", ErrInvalidKey)
+	}
+	return secret, nil
+}
+
+// String encodes the key as an otpauth:// URI
+func (k Key) String() string {
+	label := k.Account
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + k.Account
+	}
+	query := url.Values{}
+	query.Set("secret", base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(k.Secret))
+	if k.Issuer != "" {
+		query.Set("issuer", k.Issuer)
+	}
+	query.Set("algorithm", k.Algorithm)
+	query.Set("digits", strconv.Itoa(k.Digits))
+	if k.Type == TypeHOTP {
+		query.Set("counter", strconv.FormatUint(k.Counter, 10))
+	} else {
+		query.Set("period", strconv.Itoa(k.Period))
+	}
+	u := url.URL{Scheme: "otpauth", Host: k.Type, Path: "/" + label, RawQuery: query.Encode()}
+	return u.String()
+}
+
+// TOTP returns the time-based code valid at t and how long it remains valid
+func (k Key) TOTP(t time.Time) (string, time.Duration) {
+	period := int64(k.Period)
+	unix := t.Unix()
+	step := unix / period
+	remaining := time.Duration(period-unix%period) * time.Second
+	return k.HOTP(uint64(step)), remaining
+}
+
+// HOTP returns the code for a counter value
+func (k Key) HOTP(counter uint64) string {
+	var newHash func() hash.Hash
+	switch k.Algorithm {
+	case AlgorithmSHA256:
+		newHash = sha256.New
+	case AlgorithmSHA512:
+		newHash = sha512.New
+	default:
+		newHash = sha1.New
+	}
+	mac := hmac.New(newHash, k.Secret)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation picks four bytes at an offset given by the last nibble
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, value%mod)
+}
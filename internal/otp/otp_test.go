@@ -0,0 +1,100 @@
+package otp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 4226 Appendix D
+func TestHOTP(t *testing.T) {
+	key := Key{Type: TypeHOTP, Secret: []byte("12345678901234567890"), Algorithm: AlgorithmSHA1, Digits: 6}
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := key.HOTP(uint64(counter)); got != code {
+			t.Errorf("HOTP(%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+// RFC 6238 Appendix B
+func TestTOTP(t *testing.T) {
+	secrets := map[string][]byte{
+		AlgorithmSHA1:   []byte("12345678901234567890"),
+		AlgorithmSHA256: []byte(strings.Repeat("1234567890", 3) + "12"),
+		AlgorithmSHA512: []byte(strings.Repeat("1234567890", 6) + "1234"),
+	}
+	tests := []struct {
+		unix      int64
+		algorithm string
+		want      string
+	}{
+		{59, AlgorithmSHA1, "94287082"},
+		{59, AlgorithmSHA256, "46119246"},
+		{59, AlgorithmSHA512, "90693936"},
+		{1111111109, AlgorithmSHA1, "07081804"},
+		{1111111109, AlgorithmSHA256, "68084774"},
+		{1111111109, AlgorithmSHA512, "25091201"},
+		{1111111111, AlgorithmSHA1, "14050471"},
+		{1111111111, AlgorithmSHA256, "67062674"},
+		{1111111111, AlgorithmSHA512, "99943326"},
+		{1234567890, AlgorithmSHA1, "89005924"},
+		{1234567890, AlgorithmSHA256, "91819424"},
+		{1234567890, AlgorithmSHA512, "93441116"},
+		{2000000000, AlgorithmSHA1, "69279037"},
+		{2000000000, AlgorithmSHA256, "90698825"},
+		{2000000000, AlgorithmSHA512, "38618901"},
+		{20000000000, AlgorithmSHA1, "65353130"},
+		{20000000000, AlgorithmSHA256, "77737706"},
+		{20000000000, AlgorithmSHA512, "47863826"},
+	}
+	for _, tt := range tests {
+		key := Key{Type: TypeTOTP, Secret: secrets[tt.algorithm], Algorithm: tt.algorithm, Digits: 8, Period: 30}
+		at := time.Unix(tt.unix, 0)
+		got, remaining := key.TOTP(at)
+		if got != tt.want {
+			t.Errorf("TOTP(%d) with %s = %s, want %s", tt.unix, tt.algorithm, got, tt.want)
+		}
+		if want := time.Duration(30-tt.unix%30) * time.Second; remaining != want {
+			t.Errorf("TOTP(%d) remaining = %v, want %v", tt.unix, remaining, want)
+		}
+	}
+}
+
+func TestParseStringRoundTrip(t *testing.T) {
+	tests := []string{
+		"otpauth://totp/Example:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Example&algorithm=SHA256&digits=8&period=60",
+		"otpauth://hotp/bob?secret=GEZDGNBVGY3TQOJQ&counter=42",
+	}
+	for _, uri := range tests {
+		key, err := Parse(uri)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", uri, err)
+		}
+		again, err := Parse(key.String())
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", key.String(), err)
+		}
+		if !reflect.DeepEqual(again, key) {
+			t.Errorf("Parse(String()) = %+v, want %+v", again, key)
+		}
+	}
+
+	key, err := Parse(tests[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Key{
+		Type:      TypeTOTP,
+		Secret:    []byte("Hello!\xde\xad\xbe\xef"),
+		Algorithm: AlgorithmSHA256,
+		Digits:    8,
+		Period:    60,
+		Issuer:    "Example",
+		Account:   "alice@example.com",
+	}
+	if !reflect.DeepEqual(key, want) {
+		t.Errorf("Parse() = %+v, want %+v", key, want)
+	}
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/attachments"
+	"github.com/nonaxanon/vault-inator/internal/encryption"
+	"github.com/nonaxanon/vault-inator/internal/storage"
+)
+
+// DefaultMaxAttachmentSize is the upload limit used when none is configured
+const DefaultMaxAttachmentSize = 25 << 20
+
+var (
+	ErrAttachmentTooLarge  = errors.New("attachment exceeds the maximum size")
+	ErrAttachmentsDisabled = errors.New("no attachment store configured")
+)
+
+// Attachment describes a file attached to an entry.
+type Attachment struct {
+	ID          uuid.UUID `json:"id"`
+	PasswordID  uuid.UUID `json:"password_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// attachmentFromStorage maps stored attachment metadata to its service representation.
+func attachmentFromStorage(attachment storage.Attachment) Attachment {
+	return Attachment{
+		ID:          attachment.ID,
+		PasswordID:  attachment.PasswordID,
+		Name:        attachment.Name,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		CreatedAt:   attachment.CreatedAt,
+	}
+}
+
+// SetAttachmentStore sets where attachment blobs are kept and the maximum upload size
+func (s *PasswordService) SetAttachmentStore(store attachments.Store, maxSize int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if maxSize <= 0 {
+		maxSize = DefaultMaxAttachmentSize
+	}
+	s.attachments = store
+	s.maxAttachmentSize = maxSize
+}
+
+// MaxAttachmentSize returns the maximum upload size in bytes
+func (s *PasswordService) MaxAttachmentSize() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.maxAttachmentSize
+}
+
+// limitReader fails with ErrAttachmentTooLarge once more than limit bytes have been read
+type limitReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		return n, ErrAttachmentTooLarge
+	}
+	return n, err
+}
+
+// UploadAttachment encrypts the content read from r with a fresh per-attachment key and
+// stores it on the given entry
+func (s *PasswordService) UploadAttachment(passwordID uuid.UUID, name, contentType string, r io.Reader) (Attachment, error) {
+	s.mu.RLock()
+	store, maxSize, locked := s.attachments, s.maxAttachmentSize, s.index == nil
+	s.mu.RUnlock()
+	if locked {
+		return Attachment{}, ErrVaultLocked
+	}
+	if store == nil {
+		return Attachment{}, ErrAttachmentsDisabled
+	}
+
+	if _, err := s.db.GetPassword(passwordID); err != nil {
+		return Attachment{}, fmt.Errorf("failed to get password: %w", err)
+	}
+	return s.storeAttachment(store, maxSize, uuid.Nil, passwordID, name, contentType, r)
+}
+
+// storeAttachment encrypts and stores an attachment on an existing entry without taking the
+// service lock. A new ID is generated when id is uuid.Nil.
+func (s *PasswordService) storeAttachment(store attachments.Store, maxSize int64, id, passwordID uuid.UUID, name, contentType string, r io.Reader) (Attachment, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "attachment"
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	key, err := encryption.NewStreamKey()
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to generate attachment key: %w", err)
+	}
+	if id == uuid.Nil {
+		id = uuid.New()
+	}
+	attachment := storage.Attachment{
+		ID:          id,
+		PasswordID:  passwordID,
+		Name:        name,
+		ContentType: contentType,
+		Key:         key,
+	}
+	if err := s.db.AddAttachment(attachment); err != nil {
+		return Attachment{}, fmt.Errorf("failed to add attachment: %w", err)
+	}
+
+	// Encrypt while streaming into the store so the plaintext is never buffered in full
+	limited := &limitReader{r: r, limit: maxSize}
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := encryption.EncryptStream(pw, limited, key)
+		pw.CloseWithError(err)
+	}()
+	err = store.Put(attachment.ID, pr)
+	pr.CloseWithError(err)
+	if err != nil {
+		s.removeAttachment(store, attachment.ID)
+		if errors.Is(err, ErrAttachmentTooLarge) {
+			return Attachment{}, ErrAttachmentTooLarge
+		}
+		return Attachment{}, fmt.Errorf("failed to store attachment: %w", err)
+	}
+
+	attachment.Size = limited.read
+	if err := s.db.SetAttachmentSize(attachment.ID, attachment.Size); err != nil {
+		s.removeAttachment(store, attachment.ID)
+		return Attachment{}, fmt.Errorf("failed to add attachment: %w", err)
+	}
+
+	stored, err := s.db.GetAttachment(attachment.ID)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return attachmentFromStorage(stored), nil
+}
+
+// removeAttachment deletes the metadata and blob of a failed upload
+func (s *PasswordService) removeAttachment(store attachments.Store, id uuid.UUID) {
+	if err := s.db.DeleteAttachment(id); err != nil {
+		log.Printf("Failed to remove attachment %s: %v", id, err)
+	}
+	if err := store.Delete(id); err != nil {
+		log.Printf("Failed to remove attachment blob %s: %v", id, err)
+	}
+}
+
+// GetAttachments returns the attachments of an entry
+func (s *PasswordService) GetAttachments(passwordID uuid.UUID) ([]Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, err := s.db.GetAttachments(passwordID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+
+	result := make([]Attachment, len(stored))
+	for i, attachment := range stored {
+		result[i] = attachmentFromStorage(attachment)
+	}
+	return result, nil
+}
+
+// GetAttachment returns the metadata of a single attachment
+func (s *PasswordService) GetAttachment(id uuid.UUID) (Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.index == nil {
+		return Attachment{}, ErrVaultLocked
+	}
+
+	attachment, err := s.db.GetAttachment(id)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return attachmentFromStorage(attachment), nil
+}
+
+// WriteAttachment decrypts an attachment and streams its content to w
+func (s *PasswordService) WriteAttachment(id uuid.UUID, w io.Writer) error {
+	s.mu.RLock()
+	store, locked := s.attachments, s.index == nil
+	s.mu.RUnlock()
+	if locked {
+		return ErrVaultLocked
+	}
+	if store == nil {
+		return ErrAttachmentsDisabled
+	}
+
+	attachment, err := s.db.GetAttachment(id)
+	if err != nil {
+		return fmt.Errorf("failed to get attachment: %w", err)
+	}
+
+	blob, err := store.Get(id)
+	if err != nil {
+		return fmt.Errorf("failed to open attachment: %w", err)
+	}
+	defer blob.Close()
+
+	if _, err := encryption.DecryptStream(w, blob, attachment.Key); err != nil {
+		return fmt.Errorf("failed to decrypt attachment: %w", err)
+	}
+	return nil
+}
+
+// DeleteAttachment removes an attachment and its blob
+func (s *PasswordService) DeleteAttachment(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.DeleteAttachment(id); err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	s.deleteBlobs([]uuid.UUID{id})
+	return nil
+}
+
+// deleteBlobs removes attachment blobs whose metadata is already gone. Failures are logged
+// rather than returned because the database change has been committed.
+func (s *PasswordService) deleteBlobs(ids []uuid.UUID) {
+	if s.attachments == nil {
+		return
+	}
+	for _, id := range ids {
+		if err := s.attachments.Delete(id); err != nil {
+			log.Printf("Failed to delete attachment blob %s: %v", id, err)
+		}
+	}
+}
@@ -63,6 +63,11 @@ func (s *AuthService) InitializeMasterPassword(password string) error {
 		return fmt.Errorf("failed to set encryption key: %w", err)
 	}
 
+	// A freshly initialized vault starts unlocked
+	if err := s.passwordService.Unlock(); err != nil {
+		return fmt.Errorf("failed to unlock vault: %w", err)
+	}
+
 	return nil
 }
 
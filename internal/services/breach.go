@@ -0,0 +1,38 @@
+package services
+
+import (
+	"fmt"
+)
+
+// BreachChecker counts how often a password appears in known data breaches
+type BreachChecker interface {
+	Count(password string) (int, error)
+}
+
+// SetBreachChecker sets the breach dataset used to check passwords; nil turns the checks off
+func (s *PasswordService) SetBreachChecker(checker BreachChecker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.breaches = checker
+}
+
+// BreachCount returns how often password appears in known breaches, or nil when no breach
+// dataset is configured or the password is empty
+func (s *PasswordService) BreachCount(password string) (*int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.breachCount(password)
+}
+
+func (s *PasswordService) breachCount(password string) (*int, error) {
+	if s.breaches == nil || password == "" {
+		return nil, nil
+	}
+	count, err := s.breaches.Count(password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check breaches: %w", err)
+	}
+	return &count, nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/storage"
+)
+
+// MaxBulkSize is the maximum number of entries in one bulk request
+const MaxBulkSize = 1000
+
+// Bulk operations accepted by ApplyBulk
+const (
+	BulkMove       = storage.BulkMove
+	BulkAddTags    = storage.BulkAddTags
+	BulkRemoveTags = storage.BulkRemoveTags
+	BulkTrash      = storage.BulkTrash
+	BulkRestore    = storage.BulkRestore
+	BulkPurge      = storage.BulkPurge
+)
+
+// ErrInvalidBulkRequest is returned when a bulk request is malformed
+var ErrInvalidBulkRequest = errors.New("invalid bulk request")
+
+// BulkRequest applies one operation to a list of entries.
+type BulkRequest struct {
+	IDs       []uuid.UUID `json:"ids"`
+	Operation string      `json:"operation"`
+	// FolderID is the destination of a move, omitted or null for the root
+	FolderID *uuid.UUID `json:"folder_id,omitempty"`
+	// Tags are the tags added or removed by add_tags and remove_tags
+	Tags []string `json:"tags,omitempty"`
+}
+
+// BulkItemResult is the outcome of one entry of a bulk request.
+type BulkItemResult struct {
+	ID    uuid.UUID `json:"id"`
+	OK    bool      `json:"ok"`
+	Error string    `json:"error,omitempty"`
+}
+
+// BulkResult reports the outcome of a bulk request. Applied is false when any item failed,
+// in which case nothing was changed and OK items would have succeeded on their own.
+type BulkResult struct {
+	Applied bool             `json:"applied"`
+	Results []BulkItemResult `json:"results"`
+}
+
+// ApplyBulk applies an operation to every entry of a request in a single transaction.
+// If any item fails, nothing is changed and the error wraps storage.ErrBatchFailed; the
+// returned result still reports the outcome of each item.
+func (s *PasswordService) ApplyBulk(req BulkRequest) (BulkResult, error) {
+	switch req.Operation {
+	case BulkMove, BulkTrash, BulkRestore, BulkPurge:
+	case BulkAddTags, BulkRemoveTags:
+		if len(req.Tags) == 0 {
+			return BulkResult{}, fmt.Errorf("%w: %s requires tags", ErrInvalidBulkRequest, req.Operation)
+		}
+	default:
+		return BulkResult{}, fmt.Errorf("%w: unknown operation %q", ErrInvalidBulkRequest, req.Operation)
+	}
+	if len(req.IDs) == 0 {
+		return BulkResult{}, fmt.Errorf("%w: no entries given", ErrInvalidBulkRequest)
+	}
+	if len(req.IDs) > MaxBulkSize {
+		return BulkResult{}, fmt.Errorf("%w: at most %d entries per request", ErrInvalidBulkRequest, MaxBulkSize)
+	}
+
+	// Each entry is processed once even if it is listed several times
+	ids := make([]uuid.UUID, 0, len(req.IDs))
+	seen := make(map[uuid.UUID]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return BulkResult{}, ErrVaultLocked
+	}
+
+	op := storage.BulkOperation{Kind: req.Operation, FolderID: req.FolderID, Tags: req.Tags}
+	itemErrs, attachmentIDs, err := s.db.ApplyBulk(ids, op)
+	if itemErrs == nil {
+		return BulkResult{}, fmt.Errorf("failed to apply bulk %s: %w", req.Operation, err)
+	}
+
+	result := BulkResult{
+		Applied: err == nil,
+		Results: make([]BulkItemResult, len(ids)),
+	}
+	for i, id := range ids {
+		result.Results[i] = BulkItemResult{ID: id, OK: itemErrs[i] == nil}
+		if itemErrs[i] != nil {
+			result.Results[i].Error = itemErrs[i].Error()
+		}
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to apply bulk %s: %w", req.Operation, err)
+	}
+
+	s.deleteBlobs(attachmentIDs)
+	for _, id := range ids {
+		switch req.Operation {
+		case BulkTrash, BulkPurge:
+			s.unindex(id)
+		default:
+			s.reindex(id)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/encryption"
+)
+
+// VaultExport is a decrypted snapshot of every folder and non-trashed entry, used to write
+// exports in other formats.
+type VaultExport struct {
+	Folders   []Folder
+	Passwords []Password
+	// History holds the earlier passwords and hidden fields of each entry, most recently
+	// replaced first
+	History map[uuid.UUID][]PasswordHistory
+	// Attachments holds the attachments of each entry when ExportOptions.Attachments is set
+	Attachments map[uuid.UUID][]ExportedAttachment
+}
+
+// ExportedAttachment is an attachment together with its decrypted content.
+type ExportedAttachment struct {
+	Attachment
+	Data []byte `json:"data"`
+}
+
+// ExportOptions controls ExportVault.
+type ExportOptions struct {
+	// Attachments loads attachment content, for formats that can carry files
+	Attachments bool
+}
+
+// ExportVault returns a decrypted snapshot of the vault. It fails with ErrVaultLocked while
+// the vault is locked.
+func (s *PasswordService) ExportVault(opts ExportOptions) (VaultExport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.index == nil {
+		return VaultExport{}, ErrVaultLocked
+	}
+
+	storedFolders, err := s.db.GetAllFolders()
+	if err != nil {
+		return VaultExport{}, fmt.Errorf("failed to get folders: %w", err)
+	}
+	folders := make([]Folder, len(storedFolders))
+	for i, folder := range storedFolders {
+		folders[i] = folderFromStorage(folder)
+	}
+
+	entries, err := s.db.GetAllPasswords()
+	if err != nil {
+		return VaultExport{}, fmt.Errorf("failed to get passwords: %w", err)
+	}
+	passwords, err := passwordsFromEntries(entries)
+	if err != nil {
+		return VaultExport{}, err
+	}
+
+	stored, err := s.db.GetAllPasswordHistory()
+	if err != nil {
+		return VaultExport{}, fmt.Errorf("failed to get password history: %w", err)
+	}
+	history := make(map[uuid.UUID][]PasswordHistory)
+	for _, item := range stored {
+		history[item.PasswordID] = append(history[item.PasswordID], historyFromStorage(item))
+	}
+
+	vault := VaultExport{Folders: folders, Passwords: passwords, History: history}
+	if opts.Attachments && s.attachments != nil {
+		vault.Attachments = make(map[uuid.UUID][]ExportedAttachment)
+		for _, password := range passwords {
+			files, err := s.exportAttachments(password.ID)
+			if err != nil {
+				return VaultExport{}, err
+			}
+			if len(files) > 0 {
+				vault.Attachments[password.ID] = files
+			}
+		}
+	}
+	return vault, nil
+}
+
+// exportAttachments loads and decrypts the attachments of an entry
+func (s *PasswordService) exportAttachments(passwordID uuid.UUID) ([]ExportedAttachment, error) {
+	stored, err := s.db.GetAttachments(passwordID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+
+	files := make([]ExportedAttachment, 0, len(stored))
+	for _, attachment := range stored {
+		blob, err := s.attachments.Get(attachment.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open attachment: %w", err)
+		}
+		var data bytes.Buffer
+		_, err = encryption.DecryptStream(&data, blob, attachment.Key)
+		blob.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt attachment: %w", err)
+		}
+		files = append(files, ExportedAttachment{Attachment: attachmentFromStorage(attachment), Data: data.Bytes()})
+	}
+	return files, nil
+}
+
+// FolderPaths returns the path from the root of every folder, keyed by folder ID
+func (v VaultExport) FolderPaths() map[uuid.UUID][]string {
+	byID := make(map[uuid.UUID]Folder, len(v.Folders))
+	for _, folder := range v.Folders {
+		byID[folder.ID] = folder
+	}
+
+	paths := make(map[uuid.UUID][]string, len(v.Folders))
+	var pathOf func(id uuid.UUID, depth int) []string
+	pathOf = func(id uuid.UUID, depth int) []string {
+		if path, ok := paths[id]; ok {
+			return path
+		}
+		folder, ok := byID[id]
+		if !ok || depth > len(v.Folders) {
+			return nil
+		}
+		var path []string
+		if folder.ParentID != nil {
+			path = append(path, pathOf(*folder.ParentID, depth+1)...)
+		}
+		path = append(path, folder.Name)
+		paths[id] = path
+		return path
+	}
+	for _, folder := range v.Folders {
+		pathOf(folder.ID, 0)
+	}
+	return paths
+}
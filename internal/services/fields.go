@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/nonaxanon/vault-inator/internal/storage"
+)
+
+// ErrInvalidCustomField is returned when a custom field fails validation
+var ErrInvalidCustomField = errors.New("invalid custom field")
+
+// Custom field types
+const (
+	FieldTypeText    = "text"
+	FieldTypeHidden  = "hidden"
+	FieldTypeBoolean = "boolean"
+	FieldTypeURL     = "url"
+)
+
+// CustomField is a typed, user-defined field on an entry.
+// Hidden values are encrypted at rest and should be masked like the password.
+type CustomField struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// validateCustomFields checks field names, types and values, defaulting an empty type to text
+func validateCustomFields(fields []CustomField) error {
+	for i := range fields {
+		field := &fields[i]
+		field.Name = strings.TrimSpace(field.Name)
+		if field.Name == "" {
+			return fmt.Errorf("%w: field %d has no name", ErrInvalidCustomField, i+1)
+		}
+
+		switch field.Type {
+		case "":
+			field.Type = FieldTypeText
+		case FieldTypeText, FieldTypeHidden:
+		case FieldTypeBoolean:
+			if field.Value == "" {
+				field.Value = "false"
+			}
+			if field.Value != "true" && field.Value != "false" {
+				return fmt.Errorf("%w: %q must be true or false", ErrInvalidCustomField, field.Name)
+			}
+		case FieldTypeURL:
+			if field.Value != "" {
+				if !validURL(field.Value) {
+					return fmt.Errorf("%w: %q is not a valid URL", ErrInvalidCustomField, field.Name)
+				}
+			}
+		default:
+			return fmt.Errorf("%w: %q has unknown type %q", ErrInvalidCustomField, field.Name, field.Type)
+		}
+	}
+	return nil
+}
+
+// validURL reports whether value is an absolute URL with a scheme and a host
+func validURL(value string) bool {
+	u, err := url.ParseRequestURI(value)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// customFieldsFromStorage maps stored custom fields to their service representation
+func customFieldsFromStorage(fields []storage.CustomField) []CustomField {
+	if fields == nil {
+		return nil
+	}
+	out := make([]CustomField, len(fields))
+	for i, field := range fields {
+		out[i] = CustomField{Name: field.Name, Type: field.Type, Value: field.Value}
+	}
+	return out
+}
+
+// customFieldsToStorage maps service custom fields to their storage representation
+func customFieldsToStorage(fields []CustomField) []storage.CustomField {
+	if fields == nil {
+		return nil
+	}
+	out := make([]storage.CustomField, len(fields))
+	for i, field := range fields {
+		out[i] = storage.CustomField{Name: field.Name, Type: field.Type, Value: field.Value}
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateCustomFieldsURL(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"", true},
+		{"https://example.com", true},
+		{"https://example.com:8443/login?next=/", true},
+		{"ftp://files.example.com/pub", true},
+		{"not a url", false},
+		{"example.com", false},
+		{"/relative/path", false},
+		{"https://", false},
+		{"mailto:someone@example.com", false},
+	}
+	for _, tt := range tests {
+		fields := []CustomField{{Name: "site", Type: FieldTypeURL, Value: tt.value}}
+		err := validateCustomFields(fields)
+		if tt.valid && err != nil {
+			t.Errorf("validateCustomFields(%q) = %v, want nil", tt.value, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidCustomField) {
+			t.Errorf("validateCustomFields(%q) = %v, want ErrInvalidCustomField", tt.value, err)
+		}
+	}
+}
+
+func TestPrepareImportedInvalidURLField(t *testing.T) {
+	password := Password{
+		Title: "Router",
+		Fields: []CustomField{
+			{Name: "admin", Type: FieldTypeURL, Value: "192.168.1.1"},
+			{Name: "docs", Type: FieldTypeURL, Value: "https://example.com/docs"},
+		},
+	}
+	prepareImported(&password)
+
+	if password.Fields[0].Type != FieldTypeText {
+		t.Errorf("invalid URL field has type %q, want %q", password.Fields[0].Type, FieldTypeText)
+	}
+	if password.Fields[1].Type != FieldTypeURL {
+		t.Errorf("valid URL field has type %q, want %q", password.Fields[1].Type, FieldTypeURL)
+	}
+	if err := validateCustomFields(password.Fields); err != nil {
+		t.Errorf("validateCustomFields after prepareImported = %v, want nil", err)
+	}
+}
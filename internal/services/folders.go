@@ -0,0 +1,163 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/storage"
+)
+
+// ErrInvalidFolderName is returned when a folder name is empty
+var ErrInvalidFolderName = errors.New("folder name must not be empty")
+
+// Folder represents a folder in the service layer.
+type Folder struct {
+	ID        uuid.UUID  `json:"id"`
+	ParentID  *uuid.UUID `json:"parent_id"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+	// RotationDays is the rotation interval inherited by entries in the folder, 0 for none
+	RotationDays int `json:"rotation_days"`
+}
+
+// folderFromStorage maps a storage folder to its service representation.
+func folderFromStorage(folder storage.Folder) Folder {
+	return Folder{
+		ID:        folder.ID,
+		ParentID:  folder.ParentID,
+		Name:      folder.Name,
+		CreatedAt: folder.CreatedAt,
+
+		RotationDays: folder.RotationDays,
+	}
+}
+
+// GetAllFolders returns every folder as a flat list
+func (s *PasswordService) GetAllFolders() ([]Folder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, err := s.db.GetAllFolders()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folders: %w", err)
+	}
+
+	folders := make([]Folder, len(stored))
+	for i, folder := range stored {
+		folders[i] = folderFromStorage(folder)
+	}
+	return folders, nil
+}
+
+// GetFolder returns a single folder
+func (s *PasswordService) GetFolder(id uuid.UUID) (Folder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	folder, err := s.db.GetFolder(id)
+	if err != nil {
+		return Folder{}, fmt.Errorf("failed to get folder: %w", err)
+	}
+	return folderFromStorage(folder), nil
+}
+
+// CreateFolder adds a new folder under the given parent, or at the root when parentID is nil
+func (s *PasswordService) CreateFolder(name string, parentID *uuid.UUID) (Folder, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Folder{}, ErrInvalidFolderName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return Folder{}, ErrVaultLocked
+	}
+
+	if parentID != nil {
+		if _, err := s.db.GetFolder(*parentID); err != nil {
+			return Folder{}, fmt.Errorf("failed to get parent folder: %w", err)
+		}
+	}
+
+	folder, err := s.db.AddFolder(storage.Folder{ParentID: parentID, Name: name})
+	if err != nil {
+		return Folder{}, fmt.Errorf("failed to add folder: %w", err)
+	}
+	return folderFromStorage(folder), nil
+}
+
+// RenameFolder changes the name of a folder
+func (s *PasswordService) RenameFolder(id uuid.UUID, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrInvalidFolderName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.RenameFolder(id, name); err != nil {
+		return fmt.Errorf("failed to rename folder: %w", err)
+	}
+	return nil
+}
+
+// MoveFolder moves a folder under a new parent, or to the root when parentID is nil
+func (s *PasswordService) MoveFolder(id uuid.UUID, parentID *uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.MoveFolder(id, parentID); err != nil {
+		return fmt.Errorf("failed to move folder: %w", err)
+	}
+	return nil
+}
+
+// DeleteFolder removes a folder, moving its contents up to its parent
+func (s *PasswordService) DeleteFolder(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.DeleteFolder(id); err != nil {
+		return fmt.Errorf("failed to delete folder: %w", err)
+	}
+	// Entries of the deleted folder moved to its parent, so their folder IDs changed
+	if err := s.rebuildIndex(); err != nil {
+		log.Printf("Failed to rebuild search index: %v", err)
+	}
+	return nil
+}
+
+// MovePassword files a password entry into a folder, or at the root when folderID is nil
+func (s *PasswordService) MovePassword(id uuid.UUID, folderID *uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.MovePassword(id, folderID); err != nil {
+		return fmt.Errorf("failed to move password: %w", err)
+	}
+	s.reindex(id)
+	return nil
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"bufio"
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidGeneratorOptions is returned for generator options that cannot be satisfied
+var ErrInvalidGeneratorOptions = errors.New("invalid generator options")
+
+// Generator modes
+const (
+	// GeneratorModeCharacters draws characters from the enabled character classes
+	GeneratorModeCharacters = "characters"
+	// GeneratorModePassphrase joins words from the EFF large wordlist, diceware style
+	GeneratorModePassphrase = "passphrase"
+)
+
+// Passphrase capitalization
+const (
+	CapitalizeNone   = "none"
+	CapitalizeFirst  = "first"
+	CapitalizeAll    = "all"
+	CapitalizeRandom = "random"
+)
+
+// Character classes
+const (
+	lowercaseCharacters = "abcdefghijklmnopqrstuvwxyz"
+	uppercaseCharacters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitCharacters     = "0123456789"
+	symbolCharacters    = "!@#$%^&*()-_=+[]{};:,.<>?/~"
+	// ambiguousCharacters are easily confused when read or typed
+	ambiguousCharacters = "0Oo1lI|"
+)
+
+// Generator limits
+const (
+	minGeneratedLength = 4
+	maxGeneratedLength = 256
+	maxPassphraseWords = 64
+)
+
+//go:embed eff_large_wordlist.txt
+var effLargeWordlist string
+
+// passphraseWords returns the 7776 words of the EFF large wordlist
+var passphraseWords = sync.OnceValue(func() []string {
+	words := make([]string, 0, 7776)
+	scanner := bufio.NewScanner(strings.NewReader(effLargeWordlist))
+	for scanner.Scan() {
+		// Each line is a dice roll and a word separated by a tab
+		if _, word, ok := strings.Cut(scanner.Text(), "\t"); ok {
+			words = append(words, word)
+		}
+	}
+	return words
+})
+
+// GeneratorOptions configures GeneratePassword. Start from DefaultGeneratorOptions so
+// omitted settings keep their defaults.
+type GeneratorOptions struct {
+	Mode string `json:"mode"`
+
+	// Length, the classes and their minimum counts apply to the characters mode
+	Length       int  `json:"length"`
+	Lowercase    bool `json:"lowercase"`
+	Uppercase    bool `json:"uppercase"`
+	Digits       bool `json:"digits"`
+	Symbols      bool `json:"symbols"`
+	MinLowercase int  `json:"min_lowercase"`
+	MinUppercase int  `json:"min_uppercase"`
+	MinDigits    int  `json:"min_digits"`
+	MinSymbols   int  `json:"min_symbols"`
+	// ExcludeAmbiguous leaves out characters such as 0, O, 1, l and I
+	ExcludeAmbiguous bool `json:"exclude_ambiguous"`
+	// Exclude lists characters that must not appear, such as symbols a site rejects. In the
+	// passphrase mode, words containing them are skipped.
+	Exclude string `json:"exclude,omitempty"`
+
+	// Words, Separator and Capitalize apply to the passphrase mode
+	Words      int    `json:"words"`
+	Separator  string `json:"separator"`
+	Capitalize string `json:"capitalize"`
+}
+
+// GeneratedPassword is a generated password with its entropy. The entropy is exact for
+// the options used: every password the options allow is equally likely.
+type GeneratedPassword struct {
+	Password string  `json:"password"`
+	Entropy  float64 `json:"entropy_bits"`
+	// ProfileID and ProfileName identify the generator profile whose rules were applied
+	ProfileID   *uuid.UUID `json:"profile_id,omitempty"`
+	ProfileName string     `json:"profile_name,omitempty"`
+}
+
+// DefaultGeneratorOptions returns 20 characters from all four classes, or six words
+// separated by dashes in the passphrase mode
+func DefaultGeneratorOptions() GeneratorOptions {
+	return GeneratorOptions{
+		Mode:       GeneratorModeCharacters,
+		Length:     20,
+		Lowercase:  true,
+		Uppercase:  true,
+		Digits:     true,
+		Symbols:    true,
+		Words:      6,
+		Separator:  "-",
+		Capitalize: CapitalizeNone,
+	}
+}
+
+// GeneratePassword generates a password or passphrase from crypto/rand
+func GeneratePassword(opts GeneratorOptions) (GeneratedPassword, error) {
+	switch opts.Mode {
+	case "", GeneratorModeCharacters:
+		return generateCharacters(opts)
+	case GeneratorModePassphrase:
+		return generatePassphrase(opts)
+	default:
+		return GeneratedPassword{}, fmt.Errorf("%w: unknown mode %q", ErrInvalidGeneratorOptions, opts.Mode)
+	}
+}
+
+// characterClass is a set of characters with the number that must be used
+type characterClass struct {
+	characters []rune
+	min        int
+}
+
+func generateCharacters(opts GeneratorOptions) (GeneratedPassword, error) {
+	if opts.Length < minGeneratedLength || opts.Length > maxGeneratedLength {
+		return GeneratedPassword{}, fmt.Errorf("%w: length must be between %d and %d", ErrInvalidGeneratorOptions, minGeneratedLength, maxGeneratedLength)
+	}
+
+	excluded := opts.Exclude
+	if opts.ExcludeAmbiguous {
+		excluded += ambiguousCharacters
+	}
+
+	var classes []characterClass
+	required := 0
+	for _, class := range []struct {
+		name       string
+		enabled    bool
+		characters string
+		min        int
+	}{
+		{"lowercase", opts.Lowercase, lowercaseCharacters, opts.MinLowercase},
+		{"uppercase", opts.Uppercase, uppercaseCharacters, opts.MinUppercase},
+		{"digits", opts.Digits, digitCharacters, opts.MinDigits},
+		{"symbols", opts.Symbols, symbolCharacters, opts.MinSymbols},
+	} {
+		if class.min < 0 || (class.min > 0 && !class.enabled) {
+			return GeneratedPassword{}, fmt.Errorf("%w: minimum %s count needs %s enabled", ErrInvalidGeneratorOptions, class.name, class.name)
+		}
+		if !class.enabled {
+			continue
+		}
+		characters := []rune(strings.Map(func(r rune) rune {
+			if strings.ContainsRune(excluded, r) {
+				return -1
+			}
+			return r
+		}, class.characters))
+		if len(characters) == 0 {
+			if class.min > 0 {
+				return GeneratedPassword{}, fmt.Errorf("%w: every %s character is excluded", ErrInvalidGeneratorOptions, class.name)
+			}
+			continue
+		}
+		classes = append(classes, characterClass{characters: characters, min: class.min})
+		required += class.min
+	}
+	if len(classes) == 0 {
+		return GeneratedPassword{}, fmt.Errorf("%w: enable at least one character class with characters left", ErrInvalidGeneratorOptions)
+	}
+	if required > opts.Length {
+		return GeneratedPassword{}, fmt.Errorf("%w: minimum counts add up to more than the length", ErrInvalidGeneratorOptions)
+	}
+
+	// binomial[n][k] is n choose k and powers[i][k] the size of class i to the power k
+	binomial := make([][]*big.Int, opts.Length+1)
+	for n := range binomial {
+		binomial[n] = make([]*big.Int, n+1)
+		binomial[n][0], binomial[n][n] = big.NewInt(1), big.NewInt(1)
+		for k := 1; k < n; k++ {
+			binomial[n][k] = new(big.Int).Add(binomial[n-1][k-1], binomial[n-1][k])
+		}
+	}
+	powers := make([][]*big.Int, len(classes))
+	for i, class := range classes {
+		powers[i] = make([]*big.Int, opts.Length+1)
+		powers[i][0] = big.NewInt(1)
+		for k := 1; k <= opts.Length; k++ {
+			powers[i][k] = new(big.Int).Mul(powers[i][k-1], big.NewInt(int64(len(class.characters))))
+		}
+	}
+	// term counts the strings of length n in which k positions use class i and the rest
+	// one of ways[i][n-k] strings over the classes before it
+	term := func(i, n, k int, ways [][]*big.Int) *big.Int {
+		t := new(big.Int).Mul(binomial[n][k], powers[i][k])
+		return t.Mul(t, ways[i][n-k])
+	}
+
+	// ways[i][n] counts the strings of length n over the first i classes meeting their minimums
+	ways := make([][]*big.Int, len(classes)+1)
+	ways[0] = make([]*big.Int, opts.Length+1)
+	for n := range ways[0] {
+		ways[0][n] = new(big.Int)
+	}
+	ways[0][0].SetInt64(1)
+	for i := range classes {
+		ways[i+1] = make([]*big.Int, opts.Length+1)
+		for n := 0; n <= opts.Length; n++ {
+			ways[i+1][n] = new(big.Int)
+			for k := classes[i].min; k <= n; k++ {
+				ways[i+1][n].Add(ways[i+1][n], term(i, n, k, ways))
+			}
+		}
+	}
+	total := ways[len(classes)][opts.Length]
+
+	// Pick how many characters each class gets with the probability of that split among all
+	// valid passwords, then place them at random, so every valid password is equally likely
+	counts := make([]int, len(classes))
+	remaining := opts.Length
+	for i := len(classes) - 1; i >= 0; i-- {
+		roll, err := rand.Int(rand.Reader, ways[i+1][remaining])
+		if err != nil {
+			return GeneratedPassword{}, err
+		}
+		for k := classes[i].min; k <= remaining; k++ {
+			t := term(i, remaining, k, ways)
+			if roll.Cmp(t) < 0 {
+				counts[i] = k
+				break
+			}
+			roll.Sub(roll, t)
+		}
+		remaining -= counts[i]
+	}
+
+	password := make([]rune, 0, opts.Length)
+	for i, class := range classes {
+		for j := 0; j < counts[i]; j++ {
+			r, err := randomIndex(len(class.characters))
+			if err != nil {
+				return GeneratedPassword{}, err
+			}
+			password = append(password, class.characters[r])
+		}
+	}
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomIndex(i + 1)
+		if err != nil {
+			return GeneratedPassword{}, err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return GeneratedPassword{Password: string(password), Entropy: roundBits(log2(total))}, nil
+}
+
+func generatePassphrase(opts GeneratorOptions) (GeneratedPassword, error) {
+	if opts.Words < 1 || opts.Words > maxPassphraseWords {
+		return GeneratedPassword{}, fmt.Errorf("%w: words must be between 1 and %d", ErrInvalidGeneratorOptions, maxPassphraseWords)
+	}
+	if utf8.RuneCountInString(opts.Separator) > 8 {
+		return GeneratedPassword{}, fmt.Errorf("%w: separator is too long", ErrInvalidGeneratorOptions)
+	}
+
+	switch opts.Capitalize {
+	case "", CapitalizeNone, CapitalizeFirst, CapitalizeAll, CapitalizeRandom:
+	default:
+		return GeneratedPassword{}, fmt.Errorf("%w: unknown capitalization %q", ErrInvalidGeneratorOptions, opts.Capitalize)
+	}
+
+	if strings.ContainsAny(opts.Separator, opts.Exclude) {
+		return GeneratedPassword{}, fmt.Errorf("%w: separator uses an excluded character", ErrInvalidGeneratorOptions)
+	}
+
+	words := passphraseWords()
+	if opts.Exclude != "" {
+		words = allowedWords(words, opts.Exclude, opts.Capitalize)
+		if len(words) == 0 {
+			return GeneratedPassword{}, fmt.Errorf("%w: every word uses an excluded character", ErrInvalidGeneratorOptions)
+		}
+	}
+	entropy := float64(opts.Words) * math.Log2(float64(len(words)))
+	if opts.Capitalize == CapitalizeRandom {
+		// Each word is capitalized or not with equal chance, adding a bit per word
+		entropy += float64(opts.Words)
+	}
+	chosen := make([]string, opts.Words)
+	for i := range chosen {
+		n, err := randomIndex(len(words))
+		if err != nil {
+			return GeneratedPassword{}, err
+		}
+		word := words[n]
+
+		switch opts.Capitalize {
+		case CapitalizeFirst:
+			word = capitalizeFirst(word)
+		case CapitalizeAll:
+			word = strings.ToUpper(word)
+		case CapitalizeRandom:
+			coin, err := randomIndex(2)
+			if err != nil {
+				return GeneratedPassword{}, err
+			}
+			if coin == 1 {
+				word = capitalizeFirst(word)
+			}
+		}
+		chosen[i] = word
+	}
+	return GeneratedPassword{Password: strings.Join(chosen, opts.Separator), Entropy: roundBits(entropy)}, nil
+}
+
+// allowedWords returns the words that, capitalized as requested, use none of the excluded characters
+func allowedWords(words []string, excluded, capitalize string) []string {
+	allowed := make([]string, 0, len(words))
+	for _, word := range words {
+		var forms []string
+		switch capitalize {
+		case CapitalizeFirst:
+			forms = []string{capitalizeFirst(word)}
+		case CapitalizeAll:
+			forms = []string{strings.ToUpper(word)}
+		case CapitalizeRandom:
+			forms = []string{word, capitalizeFirst(word)}
+		default:
+			forms = []string{word}
+		}
+		ok := true
+		for _, form := range forms {
+			if strings.ContainsAny(form, excluded) {
+				ok = false
+			}
+		}
+		if ok {
+			allowed = append(allowed, word)
+		}
+	}
+	return allowed
+}
+
+func capitalizeFirst(word string) string {
+	first, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(first)) + word[size:]
+}
+
+// randomIndex returns a uniformly random integer in [0, n)
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return int(i.Int64()), nil
+}
+
+// log2 returns the base 2 logarithm of a positive integer
+func log2(n *big.Int) float64 {
+	// Keep the top 53 bits as a float and count the rest
+	shift := n.BitLen() - 53
+	if shift < 0 {
+		shift = 0
+	}
+	top, _ := new(big.Float).SetInt(new(big.Int).Rsh(n, uint(shift))).Float64()
+	return math.Log2(top) + float64(shift)
+}
+
+// roundBits rounds an entropy to a tenth of a bit
+func roundBits(bits float64) float64 {
+	return math.Round(bits*10) / 10
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultMaxPasswordAge is how long a password may stay unchanged before the health
+// report lists it as old
+const DefaultMaxPasswordAge = 365 * 24 * time.Hour
+
+// weakScore is the strength score below which the health report lists a password as weak
+const weakScore = 3
+
+// HealthOptions configures the vault health report
+type HealthOptions struct {
+	// MaxPasswordAge is how long a password may stay unchanged; 0 means DefaultMaxPasswordAge
+	MaxPasswordAge time.Duration
+}
+
+// HealthEntry identifies an entry in the health report without any of its secrets
+type HealthEntry struct {
+	ID       uuid.UUID `json:"id"`
+	Title    string    `json:"title"`
+	Username string    `json:"username"`
+	URL      string    `json:"url"`
+}
+
+// ReusedPassword is a group of entries sharing one password, identified by a keyed hash
+// of the password so the report never contains it
+type ReusedPassword struct {
+	Hash    string        `json:"hash"`
+	Entries []HealthEntry `json:"entries"`
+}
+
+// WeakPassword is an entry whose password scores below 3
+type WeakPassword struct {
+	HealthEntry
+	Score   int    `json:"score"`
+	Warning string `json:"warning"`
+}
+
+// BreachedPassword is an entry whose password appears in the breach dataset
+type BreachedPassword struct {
+	HealthEntry
+	Count int `json:"count"`
+}
+
+// OldPassword is an entry whose password has not changed for longer than the maximum age
+type OldPassword struct {
+	HealthEntry
+	ChangedAt time.Time `json:"changed_at"`
+	AgeDays   int       `json:"age_days"`
+}
+
+// DuplicateEntries is a group of entries for the same username on the same site
+type DuplicateEntries struct {
+	Entries []HealthEntry `json:"entries"`
+}
+
+// HealthReport lists what to fix in the vault. Score is the percentage of checked
+// entries without a weak, reused, breached, old or insecure password or a duplicate.
+// Breached passwords are only checked when BreachCheck is set.
+type HealthReport struct {
+	Score          int                `json:"score"`
+	CheckedEntries int                `json:"checked_entries"`
+	MaxAgeDays     int                `json:"max_age_days"`
+	Reused         []ReusedPassword   `json:"reused"`
+	Weak           []WeakPassword     `json:"weak"`
+	BreachCheck    bool               `json:"breach_check"`
+	Breached       []BreachedPassword `json:"breached"`
+	Old            []OldPassword      `json:"old"`
+	Duplicates     []DuplicateEntries `json:"duplicates"`
+	InsecureURL    []HealthEntry      `json:"insecure_url"`
+	MissingURL     []HealthEntry      `json:"missing_url"`
+	MissingUser    []HealthEntry      `json:"missing_username"`
+}
+
+// HealthReport analyses every login in the vault in memory. The vault must be unlocked.
+func (s *PasswordService) HealthReport(opts HealthOptions) (HealthReport, error) {
+	if opts.MaxPasswordAge <= 0 {
+		opts.MaxPasswordAge = DefaultMaxPasswordAge
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.index == nil {
+		return HealthReport{}, ErrVaultLocked
+	}
+	entries, err := s.db.GetAllPasswords()
+	if err != nil {
+		return HealthReport{}, fmt.Errorf("failed to get passwords: %w", err)
+	}
+	passwords, err := passwordsFromEntries(entries)
+	if err != nil {
+		return HealthReport{}, err
+	}
+	changedAt, err := s.db.GetPasswordChangeTimes()
+	if err != nil {
+		return HealthReport{}, fmt.Errorf("failed to get password changes: %w", err)
+	}
+
+	report := HealthReport{
+		MaxAgeDays:  int(opts.MaxPasswordAge / (24 * time.Hour)),
+		Reused:      []ReusedPassword{},
+		Weak:        []WeakPassword{},
+		BreachCheck: s.breaches != nil,
+		Breached:    []BreachedPassword{},
+		Old:         []OldPassword{},
+		Duplicates:  []DuplicateEntries{},
+		InsecureURL: []HealthEntry{},
+		MissingURL:  []HealthEntry{},
+		MissingUser: []HealthEntry{},
+	}
+	issues := map[uuid.UUID]bool{}
+	byHash := map[string][]HealthEntry{}
+	byLogin := map[string][]HealthEntry{}
+	now := time.Now()
+
+	for i := range passwords {
+		password := &passwords[i]
+		if password.Type != EntryTypeLogin {
+			continue
+		}
+		report.CheckedEntries++
+		entry := HealthEntry{ID: password.ID, Title: password.Title, Username: password.Username, URL: password.URL}
+
+		if len(password.URIs) == 0 {
+			report.MissingURL = append(report.MissingURL, entry)
+		}
+		for _, uri := range password.URIs {
+			if u, err := url.Parse(uri.URL); err == nil && strings.EqualFold(u.Scheme, "http") {
+				report.InsecureURL = append(report.InsecureURL, entry)
+				issues[password.ID] = true
+				break
+			}
+		}
+		if strings.TrimSpace(password.Username) == "" {
+			report.MissingUser = append(report.MissingUser, entry)
+		}
+		if key := duplicateKey(password.URL, password.Username); key != "" && strings.TrimSpace(password.Username) != "" {
+			byLogin[key] = append(byLogin[key], entry)
+		}
+		if password.Password == "" {
+			continue
+		}
+
+		hash := s.db.PasswordIndex(password.Password)
+		byHash[hash] = append(byHash[hash], entry)
+
+		if result := EstimateStrength(password); result.Score < weakScore {
+			report.Weak = append(report.Weak, WeakPassword{HealthEntry: entry, Score: result.Score, Warning: result.Feedback.Warning})
+			issues[password.ID] = true
+		}
+
+		count, err := s.breachCount(password.Password)
+		if err != nil {
+			return HealthReport{}, err
+		}
+		if count != nil && *count > 0 {
+			report.Breached = append(report.Breached, BreachedPassword{HealthEntry: entry, Count: *count})
+			issues[password.ID] = true
+		}
+
+		changed, ok := changedAt[password.ID]
+		if !ok {
+			changed = password.CreatedAt
+		}
+		if age := now.Sub(changed); age > opts.MaxPasswordAge {
+			report.Old = append(report.Old, OldPassword{HealthEntry: entry, ChangedAt: changed, AgeDays: int(age / (24 * time.Hour))})
+			issues[password.ID] = true
+		}
+	}
+
+	for hash, group := range byHash {
+		if len(group) > 1 {
+			report.Reused = append(report.Reused, ReusedPassword{Hash: hash, Entries: group})
+			for _, entry := range group {
+				issues[entry.ID] = true
+			}
+		}
+	}
+	for _, group := range byLogin {
+		if len(group) > 1 {
+			report.Duplicates = append(report.Duplicates, DuplicateEntries{Entries: group})
+			for _, entry := range group {
+				issues[entry.ID] = true
+			}
+		}
+	}
+	// Largest groups first, so the report starts with what fixes the most
+	sort.Slice(report.Reused, func(i, j int) bool {
+		if len(report.Reused[i].Entries) != len(report.Reused[j].Entries) {
+			return len(report.Reused[i].Entries) > len(report.Reused[j].Entries)
+		}
+		return report.Reused[i].Hash < report.Reused[j].Hash
+	})
+	sort.Slice(report.Duplicates, func(i, j int) bool {
+		if len(report.Duplicates[i].Entries) != len(report.Duplicates[j].Entries) {
+			return len(report.Duplicates[i].Entries) > len(report.Duplicates[j].Entries)
+		}
+		return report.Duplicates[i].Entries[0].Title < report.Duplicates[j].Entries[0].Title
+	})
+	sort.SliceStable(report.Weak, func(i, j int) bool { return report.Weak[i].Score < report.Weak[j].Score })
+	sort.SliceStable(report.Breached, func(i, j int) bool { return report.Breached[i].Count > report.Breached[j].Count })
+	sort.SliceStable(report.Old, func(i, j int) bool { return report.Old[i].AgeDays > report.Old[j].AgeDays })
+
+	report.Score = 100
+	if report.CheckedEntries > 0 {
+		healthy := report.CheckedEntries - len(issues)
+		report.Score = int(math.Round(100 * float64(healthy) / float64(report.CheckedEntries)))
+	}
+	return report, nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/storage"
+)
+
+// PasswordHistory is what an update of an entry replaced. It is recorded whenever an update
+// changes the password or a hidden custom field; Password is empty when only fields changed.
+type PasswordHistory struct {
+	Password string `json:"password"`
+	// Fields are the earlier values of hidden custom fields that were changed or removed
+	Fields []CustomField `json:"fields,omitempty"`
+	// ChangedAt is when the password was replaced
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// historyFromStorage maps stored history to its service representation
+func historyFromStorage(item storage.PasswordHistory) PasswordHistory {
+	return PasswordHistory{Password: item.Password, Fields: customFieldsFromStorage(item.Fields), ChangedAt: item.ChangedAt}
+}
+
+// historyToStorage maps an entry's history item to its storage representation
+func historyToStorage(passwordID uuid.UUID, item PasswordHistory) storage.PasswordHistory {
+	return storage.PasswordHistory{PasswordID: passwordID, Password: item.Password, Fields: customFieldsToStorage(item.Fields), ChangedAt: item.ChangedAt}
+}
+
+// GetPasswordHistory returns the earlier passwords and hidden fields of an entry, most
+// recently replaced first
+func (s *PasswordService) GetPasswordHistory(id uuid.UUID) ([]PasswordHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.index == nil {
+		return nil, ErrVaultLocked
+	}
+
+	if _, err := s.db.GetPassword(id); err != nil {
+		return nil, fmt.Errorf("failed to get password: %w", err)
+	}
+	stored, err := s.db.GetPasswordHistory(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password history: %w", err)
+	}
+
+	history := make([]PasswordHistory, len(stored))
+	for i, item := range stored {
+		history[i] = historyFromStorage(item)
+	}
+	return history, nil
+}
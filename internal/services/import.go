@@ -0,0 +1,429 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/otp"
+	"github.com/nonaxanon/vault-inator/internal/storage"
+	"github.com/nonaxanon/vault-inator/internal/urlmatch"
+)
+
+// Import actions reported for each imported record
+const (
+	ImportCreate = "create"
+	ImportMerge  = "merge"
+	ImportSkip   = "skip"
+)
+
+// ImportRecord is one entry parsed from an export together with the folder it was filed in.
+type ImportRecord struct {
+	Password Password
+	// Folder is the folder path from the root, empty for unfiled entries. Missing folders
+	// are created; existing ones are matched by name, ignoring case.
+	Folder []string
+	// History and Attachments are only imported when the record creates a new entry
+	History     []PasswordHistory
+	Attachments []ImportAttachment
+}
+
+// ImportAttachment is a file attached to an imported entry.
+type ImportAttachment struct {
+	Name string
+	Data []byte
+}
+
+// ImportOptions controls ImportPasswords.
+type ImportOptions struct {
+	// DryRun reports what would happen without writing anything
+	DryRun bool
+}
+
+// ImportItem is the outcome of one imported record.
+type ImportItem struct {
+	// Row is the 1-based position of the record in the imported file
+	Row      int    `json:"row"`
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Folder   string `json:"folder,omitempty"`
+	Action   string `json:"action"`
+	Reason   string `json:"reason,omitempty"`
+	// ID is the entry that was or would be created or merged into
+	ID *uuid.UUID `json:"id,omitempty"`
+	// Attachments is the number of attachments stored or to be stored on a created entry
+	Attachments int `json:"attachments,omitempty"`
+}
+
+// ImportReport summarizes an import.
+type ImportReport struct {
+	DryRun         bool         `json:"dry_run"`
+	Created        int          `json:"created"`
+	Merged         int          `json:"merged"`
+	Skipped        int          `json:"skipped"`
+	FoldersCreated int          `json:"folders_created"`
+	Items          []ImportItem `json:"items"`
+}
+
+// importTarget is an entry an imported record can be merged into
+type importTarget struct {
+	password *Password
+	existing bool
+	row      int
+}
+
+// importedAttachments are the attachments of a created entry and its report item
+type importedAttachments struct {
+	passwordID  uuid.UUID
+	attachments []ImportAttachment
+	item        int
+}
+
+// ImportPasswords adds parsed entries to the vault. Records matching an existing entry or an
+// earlier record by URL and username are merged into it, or skipped when they add nothing.
+// Invalid records are skipped, and so are records that would add a password below the
+// minimum strength. All writes happen in a single transaction; attachments of created
+// entries are stored once it has committed.
+func (s *PasswordService) ImportPasswords(records []ImportRecord, opts ImportOptions) (ImportReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ImportReport{}, ErrVaultLocked
+	}
+
+	entries, err := s.db.GetAllPasswords()
+	if err != nil {
+		return ImportReport{}, fmt.Errorf("failed to get passwords: %w", err)
+	}
+	existing, err := passwordsFromEntries(entries)
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	folders, err := s.db.GetAllFolders()
+	if err != nil {
+		return ImportReport{}, fmt.Errorf("failed to get folders: %w", err)
+	}
+	resolver := newFolderResolver(folders)
+
+	targets := make(map[string]*importTarget)
+	for i := range existing {
+		if key := duplicateKey(existing[i].URL, existing[i].Username); key != "" {
+			targets[key] = &importTarget{password: &existing[i], existing: true}
+		}
+	}
+
+	report := ImportReport{DryRun: opts.DryRun, Items: make([]ImportItem, 0, len(records))}
+	var creates []*Password
+	var history []storage.PasswordHistory
+	var files []importedAttachments
+	updates := make(map[uuid.UUID]*Password)
+	for i, record := range records {
+		password := record.Password
+		prepareImported(&password)
+		item := ImportItem{
+			Row:      i + 1,
+			Title:    password.Title,
+			URL:      password.URL,
+			Username: password.Username,
+			Folder:   strings.Join(record.Folder, "/"),
+		}
+
+		if err := validateEntry(&password); err != nil {
+			item.Action, item.Reason = ImportSkip, err.Error()
+		} else if err := validateCustomFields(password.Fields); err != nil {
+			item.Action, item.Reason = ImportSkip, err.Error()
+		} else if err := s.checkImportedStrength(&password, targets[duplicateKey(password.URL, password.Username)]); err != nil {
+			item.Action, item.Reason = ImportSkip, err.Error()
+		} else if target := targets[duplicateKey(password.URL, password.Username)]; target != nil {
+			id := target.password.ID
+			item.ID = &id
+			if !mergeImported(target.password, password) {
+				item.Action, item.Reason = ImportSkip, "duplicate with nothing new"
+			} else {
+				item.Action = ImportMerge
+				if target.existing {
+					item.Reason = "merged into existing entry"
+					updates[id] = target.password
+				} else {
+					item.Reason = fmt.Sprintf("merged into row %d", target.row)
+				}
+			}
+		} else {
+			password.ID = uuid.New()
+			password.FolderID = resolver.resolve(record.Folder)
+			created := password
+			creates = append(creates, &created)
+			if key := duplicateKey(created.URL, created.Username); key != "" {
+				targets[key] = &importTarget{password: &created, row: item.Row}
+			}
+			id := created.ID
+			item.ID = &id
+			item.Action = ImportCreate
+
+			for _, earlier := range record.History {
+				history = append(history, historyToStorage(id, earlier))
+			}
+			if len(record.Attachments) > 0 {
+				if s.attachments == nil {
+					item.Reason = "attachments skipped: " + ErrAttachmentsDisabled.Error()
+				} else {
+					item.Attachments = len(record.Attachments)
+					files = append(files, importedAttachments{passwordID: id, attachments: record.Attachments, item: len(report.Items)})
+				}
+			}
+		}
+
+		switch item.Action {
+		case ImportCreate:
+			report.Created++
+		case ImportMerge:
+			report.Merged++
+		default:
+			report.Skipped++
+		}
+		report.Items = append(report.Items, item)
+	}
+	report.FoldersCreated = len(resolver.created)
+
+	if opts.DryRun || (len(creates) == 0 && len(updates) == 0) {
+		return report, nil
+	}
+
+	createEntries := make([]storage.PasswordEntry, 0, len(creates))
+	for _, password := range creates {
+		entry, err := entryFromPassword(password)
+		if err != nil {
+			return ImportReport{}, err
+		}
+		createEntries = append(createEntries, entry)
+	}
+	updateEntries := make([]storage.PasswordEntry, 0, len(updates))
+	for _, password := range updates {
+		entry, err := entryFromPassword(password)
+		if err != nil {
+			return ImportReport{}, err
+		}
+		updateEntries = append(updateEntries, entry)
+	}
+	if err := s.db.ImportPasswords(resolver.created, createEntries, updateEntries, history); err != nil {
+		return ImportReport{}, fmt.Errorf("failed to import passwords: %w", err)
+	}
+
+	// Attachment blobs live outside the database, so they are stored once the entries exist.
+	// A failed attachment does not undo the import; it is reported on its item instead.
+	for _, file := range files {
+		item := &report.Items[file.item]
+		for _, attachment := range file.attachments {
+			contentType := mime.TypeByExtension(path.Ext(attachment.Name))
+			if _, err := s.storeAttachment(s.attachments, s.maxAttachmentSize, uuid.Nil, file.passwordID, attachment.Name, contentType, bytes.NewReader(attachment.Data)); err != nil {
+				log.Printf("Failed to import attachment %q: %v", attachment.Name, err)
+				item.Attachments--
+				item.Reason = fmt.Sprintf("attachment %q not imported: %v", attachment.Name, err)
+			}
+		}
+	}
+
+	for _, password := range creates {
+		s.reindex(password.ID)
+	}
+	for id := range updates {
+		s.reindex(id)
+	}
+	return report, nil
+}
+
+// folderKey identifies a folder by its parent and lower-cased name; the parent is uuid.Nil at the root
+type folderKey struct {
+	parent uuid.UUID
+	name   string
+}
+
+// folderResolver maps imported folder paths to folder IDs, planning the folders that do not exist yet
+type folderResolver struct {
+	ids     map[folderKey]uuid.UUID
+	created []storage.Folder
+}
+
+// newFolderResolver creates a resolver that reuses the given existing folders
+func newFolderResolver(folders []storage.Folder) *folderResolver {
+	r := &folderResolver{ids: make(map[folderKey]uuid.UUID, len(folders))}
+	for _, folder := range folders {
+		var parent uuid.UUID
+		if folder.ParentID != nil {
+			parent = *folder.ParentID
+		}
+		key := folderKey{parent: parent, name: strings.ToLower(strings.TrimSpace(folder.Name))}
+		if _, ok := r.ids[key]; !ok {
+			r.ids[key] = folder.ID
+		}
+	}
+	return r
+}
+
+// resolve returns the ID of the folder at path, planning any missing folders. It returns nil
+// for an empty path.
+func (r *folderResolver) resolve(path []string) *uuid.UUID {
+	var parent *uuid.UUID
+	for _, name := range path {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		key := folderKey{name: strings.ToLower(name)}
+		if parent != nil {
+			key.parent = *parent
+		}
+		id, ok := r.ids[key]
+		if !ok {
+			id = uuid.New()
+			r.ids[key] = id
+			r.created = append(r.created, storage.Folder{ID: id, ParentID: parent, Name: name})
+		}
+		parent = &id
+	}
+	return parent
+}
+
+// checkImportedStrength applies the minimum strength policy to a record whose password is
+// new to the vault: one that creates an entry or replaces the password of its merge target
+func (s *PasswordService) checkImportedStrength(password *Password, target *importTarget) error {
+	if target != nil && (password.Password == "" || password.Password == target.password.Password) {
+		return nil
+	}
+	return s.checkStrength(password)
+}
+
+// prepareImported fills in defaults for an imported record before validation
+func prepareImported(password *Password) {
+	password.Title = strings.TrimSpace(password.Title)
+	password.URL = strings.TrimSpace(password.URL)
+	password.Username = strings.TrimSpace(password.Username)
+	// Match modes a URI cannot be matched under fall back to the default
+	primary := ""
+	for i := range password.URIs {
+		uri := &password.URIs[i]
+		uri.URL = strings.TrimSpace(uri.URL)
+		if uri.Match != "" && urlmatch.Validate(uri.URL, uri.Match) != nil {
+			uri.Match = ""
+		}
+		if primary == "" {
+			primary = uri.URL
+		}
+	}
+	// Exports that carry both keep the URIs, so the URL is the first of them
+	if primary != "" {
+		password.URL = primary
+	}
+	if password.Type == "" {
+		password.Type = EntryTypeLogin
+	}
+	if password.Title == "" {
+		if u, err := url.Parse(withScheme(password.URL)); err == nil && u.Host != "" {
+			password.Title = strings.TrimPrefix(u.Hostname(), "www.")
+		}
+	}
+	if password.Title == "" {
+		password.Title = password.Username
+	}
+	// Seeds in formats other than otpauth:// or base32, such as Steam's, are kept as a
+	// hidden field rather than failing the record
+	if password.TOTP = strings.TrimSpace(password.TOTP); password.TOTP != "" {
+		if _, err := otp.Parse(password.TOTP); err != nil || password.Type != EntryTypeLogin {
+			password.Fields = append(password.Fields, CustomField{Name: "totp", Type: FieldTypeHidden, Value: password.TOTP})
+			password.TOTP = ""
+		}
+	}
+	// URL fields holding something other than an absolute URL are kept as text
+	for i := range password.Fields {
+		field := &password.Fields[i]
+		if field.Type == FieldTypeURL && field.Value != "" && !validURL(field.Value) {
+			field.Type = FieldTypeText
+		}
+	}
+}
+
+// mergeImported merges an imported record into target and reports whether anything changed.
+// The imported password wins because exports are usually newer than the vault; empty title
+// notes and one-time password are filled in, and URIs, tags and custom fields are added.
+func mergeImported(target *Password, imported Password) bool {
+	changed := false
+	if imported.Password != "" && imported.Password != target.Password {
+		target.Password = imported.Password
+		changed = true
+	}
+	if target.Title == "" && imported.Title != "" {
+		target.Title = imported.Title
+		changed = true
+	}
+	if target.Notes == "" && imported.Notes != "" {
+		target.Notes = imported.Notes
+		changed = true
+	}
+	if target.TOTP == "" && imported.TOTP != "" {
+		target.TOTP = imported.TOTP
+		changed = true
+	}
+	for _, uri := range imported.URIs {
+		if !hasURI(target.URIs, uri.URL) {
+			target.URIs = append(target.URIs, uri)
+			changed = true
+		}
+	}
+
+	for _, tag := range imported.Tags {
+		found := false
+		for _, existing := range target.Tags {
+			if strings.EqualFold(strings.TrimSpace(existing), strings.TrimSpace(tag)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			target.Tags = append(target.Tags, tag)
+			changed = true
+		}
+	}
+
+	for _, field := range imported.Fields {
+		found := false
+		for _, existing := range target.Fields {
+			if existing.Name == field.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			target.Fields = append(target.Fields, field)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// duplicateKey identifies a login by host, path and username, ignoring scheme, a leading
+// www., query, fragment, trailing slashes and case. It is empty when the URL has no host.
+func duplicateKey(rawURL, username string) string {
+	u, err := url.Parse(withScheme(strings.TrimSpace(rawURL)))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	path := strings.TrimRight(u.Path, "/")
+	return host + path + "\x00" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// withScheme prefixes a URL without a scheme with https:// so that it parses with a host
+func withScheme(rawURL string) string {
+	if rawURL != "" && !strings.Contains(rawURL, "://") {
+		return "https://" + rawURL
+	}
+	return rawURL
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckImportedStrength(t *testing.T) {
+	s := &PasswordService{minStrength: 3}
+	existing := &importTarget{password: &Password{Title: "Example", Username: "alice", Password: "password1"}, existing: true}
+	const strong = "vX7#qLm2!pR9zTk4"
+
+	tests := []struct {
+		name     string
+		password string
+		target   *importTarget
+		weak     bool
+	}{
+		{"weak new entry", "password1", nil, true},
+		{"strong new entry", strong, nil, false},
+		{"merge keeping the password", "password1", existing, false},
+		{"merge without a password", "", existing, false},
+		{"merge replacing with a weak password", "letmein", existing, true},
+		{"merge replacing with a strong password", strong, existing, false},
+	}
+	for _, tt := range tests {
+		password := &Password{Title: "Example", Username: "alice", Password: tt.password}
+		err := s.checkImportedStrength(password, tt.target)
+		if tt.weak && !errors.Is(err, ErrPasswordTooWeak) {
+			t.Errorf("%s: checkImportedStrength = %v, want ErrPasswordTooWeak", tt.name, err)
+		}
+		if !tt.weak && err != nil {
+			t.Errorf("%s: checkImportedStrength = %v, want nil", tt.name, err)
+		}
+	}
+}
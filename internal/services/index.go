@@ -0,0 +1,362 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// ErrVaultLocked is returned while the vault is locked by every call that reveals secrets
+// or decrypted metadata and by every call that changes the vault
+var ErrVaultLocked = errors.New("vault is locked")
+
+// fuzzyThreshold is the minimum trigram similarity for a term to match a word it does not contain
+const fuzzyThreshold = 0.4
+
+// Match qualities, from best to worst
+const (
+	matchFuzzy     = 1
+	matchSubstring = 2
+	matchPrefix    = 3
+)
+
+// titleWeight boosts matches in the title over the other indexed fields
+const titleWeight = 2
+
+// searchDocument is the indexed metadata of one entry. Text fields are lower-cased.
+type searchDocument struct {
+	summary    PasswordSummary
+	title      string
+	username   string
+	urls       []string
+	tags       []string
+	fieldNames []string
+	trigrams   []string
+	// rotationDays is the entry's own rotation interval, resolved against folders when searching
+	rotationDays int
+}
+
+// searchIndex is an in-memory trigram index over the title, username, URLs, tags and custom
+// field names of every non-trashed entry. It only ever holds decrypted metadata in process
+// memory and is discarded when the vault locks. It is not safe for concurrent use; the
+// PasswordService mutex guards it.
+type searchIndex struct {
+	docs     map[uuid.UUID]*searchDocument
+	postings map[string]map[uuid.UUID]struct{}
+}
+
+// newSearchIndex creates an empty index
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		docs:     make(map[uuid.UUID]*searchDocument),
+		postings: make(map[string]map[uuid.UUID]struct{}),
+	}
+}
+
+// add indexes an entry, replacing any previous version of it
+func (idx *searchIndex) add(password Password) {
+	idx.remove(password.ID)
+
+	doc := &searchDocument{
+		summary:  summarize(password),
+		title:    strings.ToLower(password.Title),
+		username: strings.ToLower(password.Username),
+
+		rotationDays: password.RotationDays,
+	}
+	for _, uri := range password.URIs {
+		doc.urls = append(doc.urls, strings.ToLower(uri.URL))
+	}
+	for _, tag := range password.Tags {
+		doc.tags = append(doc.tags, strings.ToLower(tag))
+	}
+	for _, field := range password.Fields {
+		doc.fieldNames = append(doc.fieldNames, strings.ToLower(field.Name))
+	}
+
+	seen := make(map[string]bool)
+	for _, value := range doc.values() {
+		for _, word := range words(value) {
+			for _, trigram := range trigrams(word) {
+				if seen[trigram] {
+					continue
+				}
+				seen[trigram] = true
+				doc.trigrams = append(doc.trigrams, trigram)
+				if idx.postings[trigram] == nil {
+					idx.postings[trigram] = make(map[uuid.UUID]struct{})
+				}
+				idx.postings[trigram][password.ID] = struct{}{}
+			}
+		}
+	}
+	idx.docs[password.ID] = doc
+}
+
+// remove drops an entry from the index. Removing an unknown entry is a no-op.
+func (idx *searchIndex) remove(id uuid.UUID) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for _, trigram := range doc.trigrams {
+		delete(idx.postings[trigram], id)
+		if len(idx.postings[trigram]) == 0 {
+			delete(idx.postings, trigram)
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// searchHit is a matching entry and its relevance score
+type searchHit struct {
+	doc   *searchDocument
+	score int
+}
+
+// search returns the entries matching every term. When allowed is non-nil only those
+// entries are considered. Without terms every considered entry matches with score 0.
+func (idx *searchIndex) search(terms []searchTerm, allowed map[uuid.UUID]bool) []searchHit {
+	candidates := make(map[uuid.UUID]bool)
+	if allowed != nil {
+		for id := range allowed {
+			if _, ok := idx.docs[id]; ok {
+				candidates[id] = true
+			}
+		}
+	} else {
+		for id := range idx.docs {
+			candidates[id] = true
+		}
+	}
+
+	// Narrow the candidates with the posting lists of each term long enough to have trigrams.
+	// Any entry that contains or fuzzily matches a term shares at least one trigram with it.
+	for _, term := range terms {
+		if len([]rune(term.value)) < 3 {
+			continue
+		}
+		related := make(map[uuid.UUID]bool)
+		for _, word := range words(term.value) {
+			for _, trigram := range trigrams(word) {
+				for id := range idx.postings[trigram] {
+					related[id] = true
+				}
+			}
+		}
+		for id := range candidates {
+			if !related[id] {
+				delete(candidates, id)
+			}
+		}
+	}
+
+	hits := make([]searchHit, 0, len(candidates))
+	for id := range candidates {
+		doc := idx.docs[id]
+		total := 0
+		for _, term := range terms {
+			score := doc.score(term)
+			if score == 0 {
+				total = 0
+				break
+			}
+			total += score
+		}
+		if total > 0 || len(terms) == 0 {
+			hits = append(hits, searchHit{doc: doc, score: total})
+		}
+	}
+	return hits
+}
+
+// values returns every indexed text of the document
+func (doc *searchDocument) values() []string {
+	values := []string{doc.title, doc.username}
+	values = append(values, doc.urls...)
+	values = append(values, doc.tags...)
+	return append(values, doc.fieldNames...)
+}
+
+// score rates how well the document matches a term; 0 means no match. Unscoped terms
+// match the title, username, URLs and tags; custom field names are only searched when scoped.
+func (doc *searchDocument) score(term searchTerm) int {
+	best := func(values []string) int {
+		score := 0
+		for _, value := range values {
+			if quality := matchQuality(value, term.value); quality > score {
+				score = quality
+			}
+		}
+		return score
+	}
+
+	switch term.field {
+	case "title":
+		return matchQuality(doc.title, term.value)
+	case "username":
+		return matchQuality(doc.username, term.value)
+	case "url":
+		return best(doc.urls)
+	case "tag":
+		return best(doc.tags)
+	case "field":
+		return best(doc.fieldNames)
+	default:
+		score := titleWeight * matchQuality(doc.title, term.value)
+		if other := best(append(append([]string{doc.username}, doc.urls...), doc.tags...)); other > score {
+			score = other
+		}
+		return score
+	}
+}
+
+// matchQuality rates how well value matches a lower-cased term: a word prefix beats a
+// substring, which beats a fuzzy match on trigram similarity
+func matchQuality(value, term string) int {
+	valueWords := words(value)
+	for _, word := range valueWords {
+		if strings.HasPrefix(word, term) {
+			return matchPrefix
+		}
+	}
+	if strings.Contains(value, term) {
+		return matchSubstring
+	}
+	if len([]rune(term)) < 3 {
+		return 0
+	}
+	termTrigrams := trigrams(term)
+	for _, word := range valueWords {
+		if similarity(termTrigrams, trigrams(word)) >= fuzzyThreshold {
+			return matchFuzzy
+		}
+	}
+	return 0
+}
+
+// words splits a lower-cased value into its alphanumeric words
+func words(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// trigrams returns the distinct trigrams of a word padded with two leading spaces and one
+// trailing space, so that short words and word starts produce trigrams too
+func trigrams(word string) []string {
+	runes := []rune("  " + word + " ")
+	seen := make(map[string]bool)
+	var result []string
+	for i := 0; i+3 <= len(runes); i++ {
+		trigram := string(runes[i : i+3])
+		if !seen[trigram] {
+			seen[trigram] = true
+			result = append(result, trigram)
+		}
+	}
+	return result
+}
+
+// similarity returns the Jaccard similarity of two trigram sets
+func similarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := make(map[string]bool, len(a))
+	for _, trigram := range a {
+		set[trigram] = true
+	}
+	shared := 0
+	for _, trigram := range b {
+		if set[trigram] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// Unlock builds the search index from the decrypted vault
+func (s *PasswordService) Unlock() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rebuildIndex()
+}
+
+// Lock discards the search index and the decrypted metadata it holds. Until the vault is
+// unlocked again, calls that would return secrets or change the vault fail with
+// ErrVaultLocked.
+func (s *PasswordService) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.index = nil
+	log.Printf("Vault locked, search index discarded")
+}
+
+// IsUnlocked reports whether the search index is available
+func (s *PasswordService) IsUnlocked() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.index != nil
+}
+
+// rebuildIndex replaces the search index with one built from every non-trashed entry;
+// the caller holds s.mu
+func (s *PasswordService) rebuildIndex() error {
+	entries, err := s.db.GetAllPasswords()
+	if err != nil {
+		return fmt.Errorf("failed to get passwords: %w", err)
+	}
+	passwords, err := passwordsFromEntries(entries)
+	if err != nil {
+		return err
+	}
+
+	index := newSearchIndex()
+	for _, password := range passwords {
+		index.add(password)
+	}
+	s.index = index
+	log.Printf("Built search index with %d entries", len(passwords))
+	return nil
+}
+
+// reindex refreshes one entry in the search index after it was written, dropping it if
+// it is now trashed or gone. Failures are logged because the write itself succeeded.
+// The caller holds s.mu.
+func (s *PasswordService) reindex(id uuid.UUID) {
+	if s.index == nil {
+		return
+	}
+	entry, err := s.db.GetPassword(id)
+	if err != nil {
+		s.index.remove(id)
+		log.Printf("Failed to reindex password %s: %v", id, err)
+		return
+	}
+	if entry.DeletedAt != nil {
+		s.index.remove(id)
+		return
+	}
+	password, err := passwordFromEntry(entry)
+	if err != nil {
+		s.index.remove(id)
+		log.Printf("Failed to reindex password %s: %v", id, err)
+		return
+	}
+	s.index.add(password)
+}
+
+// unindex drops an entry from the search index; the caller holds s.mu
+func (s *PasswordService) unindex(id uuid.UUID) {
+	if s.index != nil {
+		s.index.remove(id)
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/storage"
+	"github.com/nonaxanon/vault-inator/internal/urlmatch"
+)
+
+// ErrInvalidEquivalentDomains is returned for a group of fewer than two valid domains
+var ErrInvalidEquivalentDomains = errors.New("invalid equivalent domains")
+
+// EquivalentDomains is a group of domains treated as one site when matching URLs by base
+// domain, such as a service reachable under several country domains
+type EquivalentDomains struct {
+	ID        uuid.UUID `json:"id"`
+	Domains   []string  `json:"domains"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// URLMatch is an entry with a URI matching a page, the most specific such URI and the mode
+// it matched under
+type URLMatch struct {
+	PasswordSummary
+	MatchedURL string `json:"matched_url"`
+	MatchMode  string `json:"match_mode"`
+}
+
+// equivalentDomainsFromStorage maps a stored group to its service representation
+func equivalentDomainsFromStorage(stored storage.EquivalentDomains) (EquivalentDomains, error) {
+	group := EquivalentDomains{ID: stored.ID, CreatedAt: stored.CreatedAt}
+	if err := json.Unmarshal([]byte(stored.Domains), &group.Domains); err != nil {
+		return EquivalentDomains{}, fmt.Errorf("failed to decode equivalent domains: %w", err)
+	}
+	return group, nil
+}
+
+// encodeEquivalentDomains validates a group, reducing each domain to its base domain, and
+// returns the data to store
+func encodeEquivalentDomains(domains []string) (string, error) {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, domain := range domains {
+		domain = strings.TrimSpace(domain)
+		if domain == "" {
+			continue
+		}
+		u, err := urlmatch.ParseURL(domain)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidEquivalentDomains, err)
+		}
+		if base := u.BaseDomain(); !seen[base] {
+			seen[base] = true
+			normalized = append(normalized, base)
+		}
+	}
+	if len(normalized) < 2 {
+		return "", fmt.Errorf("%w: at least two different domains are required", ErrInvalidEquivalentDomains)
+	}
+
+	encoded, err := json.Marshal(normalized)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode equivalent domains: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// GetEquivalentDomains returns every group of equivalent domains
+func (s *PasswordService) GetEquivalentDomains() ([]EquivalentDomains, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.equivalentDomains()
+}
+
+func (s *PasswordService) equivalentDomains() ([]EquivalentDomains, error) {
+	stored, err := s.db.GetEquivalentDomains()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get equivalent domains: %w", err)
+	}
+	groups := make([]EquivalentDomains, 0, len(stored))
+	for _, group := range stored {
+		g, err := equivalentDomainsFromStorage(group)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// CreateEquivalentDomains adds a new group of equivalent domains
+func (s *PasswordService) CreateEquivalentDomains(domains []string) (EquivalentDomains, error) {
+	data, err := encodeEquivalentDomains(domains)
+	if err != nil {
+		return EquivalentDomains{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return EquivalentDomains{}, ErrVaultLocked
+	}
+
+	stored, err := s.db.AddEquivalentDomains(data)
+	if err != nil {
+		return EquivalentDomains{}, fmt.Errorf("failed to create equivalent domains: %w", err)
+	}
+	return equivalentDomainsFromStorage(stored)
+}
+
+// UpdateEquivalentDomains replaces the domains of a group
+func (s *PasswordService) UpdateEquivalentDomains(id uuid.UUID, domains []string) error {
+	data, err := encodeEquivalentDomains(domains)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.UpdateEquivalentDomains(id, data); err != nil {
+		return fmt.Errorf("failed to update equivalent domains: %w", err)
+	}
+	return nil
+}
+
+// DeleteEquivalentDomains deletes a group of equivalent domains
+func (s *PasswordService) DeleteEquivalentDomains(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.DeleteEquivalentDomains(id); err != nil {
+		return fmt.Errorf("failed to delete equivalent domains: %w", err)
+	}
+	return nil
+}
+
+// MatchURL returns the login entries with a URI matching a page URL under that URI's match
+// mode, the most specific matches first, then pinned, favorite and most used entries. It
+// uses the search index and returns summaries only, failing with ErrVaultLocked while the
+// vault is locked.
+func (s *PasswordService) MatchURL(rawURL string) ([]URLMatch, error) {
+	page, err := urlmatch.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.index == nil {
+		return nil, ErrVaultLocked
+	}
+	groups, err := s.equivalentDomains()
+	if err != nil {
+		return nil, err
+	}
+	equivalents := make([][]string, len(groups))
+	for i, group := range groups {
+		equivalents[i] = group.Domains
+	}
+	matcher := urlmatch.NewMatcher(equivalents)
+
+	matches := []URLMatch{}
+	for _, doc := range s.index.docs {
+		summary := doc.summary
+		if summary.Type != EntryTypeLogin {
+			continue
+		}
+		var best *URLMatch
+		for _, uri := range summary.URIs {
+			mode := uri.Match
+			if mode == "" {
+				mode = urlmatch.DefaultMode
+			}
+			if !matcher.Match(page, uri.URL, mode) {
+				continue
+			}
+			if best == nil || urlmatch.Specificity(mode) > urlmatch.Specificity(best.MatchMode) {
+				best = &URLMatch{PasswordSummary: summary, MatchedURL: uri.URL, MatchMode: mode}
+			}
+		}
+		if best != nil {
+			matches = append(matches, *best)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if sa, sb := urlmatch.Specificity(a.MatchMode), urlmatch.Specificity(b.MatchMode); sa != sb {
+			return sa > sb
+		}
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		if a.Favorite != b.Favorite {
+			return a.Favorite
+		}
+		if a.UseCount != b.UseCount {
+			return a.UseCount > b.UseCount
+		}
+		if a.Title != b.Title {
+			return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	return matches, nil
+}
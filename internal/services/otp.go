@@ -0,0 +1,150 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/otp"
+	"github.com/nonaxanon/vault-inator/internal/storage"
+)
+
+var (
+	// ErrNoTOTP is returned when a code is requested for an entry without a one-time password seed
+	ErrNoTOTP = errors.New("entry has no one-time password")
+	// ErrHOTPNotIdempotent is returned when an HOTP code is read without advancing its counter
+	ErrHOTPNotIdempotent = errors.New("HOTP codes advance the counter and must be generated with POST")
+)
+
+// OTPCode is a generated one-time password
+type OTPCode struct {
+	Code      string `json:"code"`
+	Type      string `json:"type"`
+	Algorithm string `json:"algorithm"`
+	Digits    int    `json:"digits"`
+	// Period and Remaining are the lifetime of a TOTP code and the seconds it stays valid
+	Period    int `json:"period,omitempty"`
+	Remaining int `json:"remaining,omitempty"`
+	// Counter is the HOTP counter value the code was generated for
+	Counter *uint64 `json:"counter,omitempty"`
+}
+
+// CurrentOTP returns the current TOTP code of an entry. HOTP entries are refused with
+// ErrHOTPNotIdempotent, as reading their code uses up a counter value.
+func (s *PasswordService) CurrentOTP(id uuid.UUID) (OTPCode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.index == nil {
+		return OTPCode{}, ErrVaultLocked
+	}
+
+	key, err := s.otpKey(id)
+	if err != nil {
+		return OTPCode{}, err
+	}
+	if key.Type == otp.TypeHOTP {
+		return OTPCode{}, ErrHOTPNotIdempotent
+	}
+	return totpCode(key), nil
+}
+
+// GenerateOTP returns the next code of an entry's one-time password. An HOTP code uses the
+// stored counter, which is advanced so every request yields the next code; a TOTP code is
+// the current one.
+func (s *PasswordService) GenerateOTP(id uuid.UUID) (OTPCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return OTPCode{}, ErrVaultLocked
+	}
+
+	key, err := s.otpKey(id)
+	if err != nil {
+		return OTPCode{}, err
+	}
+	if key.Type != otp.TypeHOTP {
+		return totpCode(key), nil
+	}
+
+	counter, err := s.db.NextHOTPCounter(id, key.Counter)
+	if err != nil {
+		return OTPCode{}, fmt.Errorf("failed to advance counter: %w", err)
+	}
+	return OTPCode{
+		Code:      key.HOTP(counter),
+		Type:      key.Type,
+		Algorithm: key.Algorithm,
+		Digits:    key.Digits,
+		Counter:   &counter,
+	}, nil
+}
+
+// otpKey returns the parsed one-time password seed of an entry
+func (s *PasswordService) otpKey(id uuid.UUID) (otp.Key, error) {
+	entry, err := s.db.GetPassword(id)
+	if err != nil {
+		return otp.Key{}, fmt.Errorf("failed to get password: %w", err)
+	}
+	if entry.TOTP == "" {
+		return otp.Key{}, ErrNoTOTP
+	}
+	key, err := otp.Parse(entry.TOTP)
+	if err != nil {
+		return otp.Key{}, fmt.Errorf("failed to parse one-time password: %w", err)
+	}
+	return key, nil
+}
+
+// totpCode returns the current code of a TOTP key with the seconds it stays valid
+func totpCode(key otp.Key) OTPCode {
+	code := OTPCode{Type: key.Type, Algorithm: key.Algorithm, Digits: key.Digits, Period: key.Period}
+	var remaining time.Duration
+	code.Code, remaining = key.TOTP(time.Now())
+	code.Remaining = int(remaining / time.Second)
+	return code
+}
+
+// totpWithCounter returns an HOTP seed with its counter replaced by the stored one, so the
+// seed shown and exported continues where the entry's codes left off. Other seeds, and
+// seeds without a stored counter, are returned unchanged.
+func totpWithCounter(totp string, counter *uint64) string {
+	if counter == nil {
+		return totp
+	}
+	key, err := otp.Parse(totp)
+	if err != nil || key.Type != otp.TypeHOTP {
+		return totp
+	}
+	key.Counter = *counter
+	return key.String()
+}
+
+// hotpCounterOf returns the next HOTP counter value of an entry: the stored counter, or
+// the counter of its seed for entries that have not generated a code since counters were
+// stored apart. It is nil for entries without an HOTP seed.
+func hotpCounterOf(entry storage.PasswordEntry) *uint64 {
+	if entry.HOTPCounter != nil {
+		return entry.HOTPCounter
+	}
+	key, err := otp.Parse(entry.TOTP)
+	if err != nil || key.Type != otp.TypeHOTP {
+		return nil
+	}
+	return &key.Counter
+}
+
+// sameOTPSecret reports whether two one-time password seeds are the same key, ignoring
+// the HOTP counter and the labels
+func sameOTPSecret(a, b string) bool {
+	keyA, errA := otp.Parse(a)
+	keyB, errB := otp.Parse(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return keyA.Type == keyB.Type && bytes.Equal(keyA.Secret, keyB.Secret) &&
+		keyA.Algorithm == keyB.Algorithm && keyA.Digits == keyB.Digits
+}
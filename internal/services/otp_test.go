@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/nonaxanon/vault-inator/internal/otp"
+)
+
+const testHOTP = "otpauth://hotp/Example:alice?secret=JBSWY3DPEHPK3PXP&counter=3&issuer=Example"
+
+func TestTOTPWithCounter(t *testing.T) {
+	counter := uint64(42)
+	got := totpWithCounter(testHOTP, &counter)
+	if !sameOTPSecret(got, testHOTP) {
+		t.Fatalf("totpWithCounter changed the key: %s", got)
+	}
+	if key, err := otp.Parse(got); err != nil || key.Counter != 42 {
+		t.Errorf("counter of %s = %d, %v, want 42", got, key.Counter, err)
+	}
+	if got := totpWithCounter(testHOTP, nil); got != testHOTP {
+		t.Errorf("without a stored counter = %s, want %s", got, testHOTP)
+	}
+	if got := totpWithCounter("JBSWY3DPEHPK3PXP", &counter); got != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("TOTP seed = %s, want it unchanged", got)
+	}
+}
+
+func TestSameOTPSecret(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{testHOTP, "otpauth://hotp/Other?secret=JBSWY3DPEHPK3PXP&counter=0", true},
+		{testHOTP, "otpauth://hotp/Example:alice?secret=KRSXG5CTMVRXEZLU&counter=3", false},
+		{testHOTP, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP", false},
+		{"JBSWY3DPEHPK3PXP", "jbsw y3dp ehpk 3pxp", true},
+		{"", testHOTP, false},
+	}
+	for _, tt := range tests {
+		if got := sameOTPSecret(tt.a, tt.b); got != tt.same {
+			t.Errorf("sameOTPSecret(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package services
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/attachments"
 	"github.com/nonaxanon/vault-inator/internal/encryption"
 	"github.com/nonaxanon/vault-inator/internal/storage"
 )
@@ -12,11 +14,114 @@ import (
 // Password represents a password entry in the service layer.
 type Password struct {
 	ID       uuid.UUID `json:"id"`
+	Type     string    `json:"type"`
 	Title    string    `json:"title"`
 	Username string    `json:"username"`
 	Password string    `json:"password"`
-	URL      string    `json:"url"`
-	Notes    string    `json:"notes"`
+	// URL is the first of URIs; an entry given only a URL gets it as its single URI.
+	// URLMatch is the match mode of that URI, for clients written before URIs.
+	URL      string `json:"url"`
+	URLMatch string `json:"url_match,omitempty"`
+	URIs     []URI  `json:"uris"`
+	Notes    string `json:"notes"`
+	// TOTP is the two-factor seed of a login, an otpauth:// URI or a base32 secret
+	TOTP string `json:"totp,omitempty"`
+	// RotationDays is how often the password must be changed; 0 inherits the interval of
+	// the nearest folder that sets one
+	RotationDays int `json:"rotation_days,omitempty"`
+
+	FolderID  *uuid.UUID    `json:"folder_id,omitempty"`
+	Tags      []string      `json:"tags"`
+	Fields    []CustomField `json:"fields"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	DeletedAt *time.Time    `json:"deleted_at,omitempty"`
+
+	Favorite bool `json:"favorite"`
+	Pinned   bool `json:"pinned"`
+	// UseCount and LastUsedAt are maintained by RecordUse and ignored on write
+	UseCount   int64      `json:"use_count"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+
+	// Type-specific payloads; only the one matching Type is set
+	Card          *Card               `json:"card,omitempty"`
+	Identity      *Identity           `json:"identity,omitempty"`
+	SSHKey        *SSHKey             `json:"ssh_key,omitempty"`
+	APICredential *APICredential      `json:"api_credential,omitempty"`
+	Database      *DatabaseConnection `json:"database,omitempty"`
+}
+
+// passwordFromEntry maps a storage entry to its service representation.
+func passwordFromEntry(entry storage.PasswordEntry) (Password, error) {
+	password := Password{
+		ID:        entry.ID,
+		Type:      entry.Type,
+		Title:     entry.Title,
+		Username:  entry.Username,
+		Password:  entry.Password,
+		URL:       entry.URL,
+		URIs:      urisFromStorage(entry.URIs),
+		URLMatch:  firstURIMatch(entry.URIs),
+		Notes:     entry.Notes,
+		TOTP:      totpWithCounter(entry.TOTP, entry.HOTPCounter),
+		FolderID:  entry.FolderID,
+		Tags:      entry.Tags,
+		Fields:    customFieldsFromStorage(entry.Fields),
+		CreatedAt: entry.CreatedAt,
+		UpdatedAt: entry.UpdatedAt,
+		DeletedAt: entry.DeletedAt,
+
+		Favorite:   entry.Favorite,
+		Pinned:     entry.Pinned,
+		UseCount:   entry.UseCount,
+		LastUsedAt: entry.LastUsedAt,
+
+		RotationDays: entry.RotationDays,
+	}
+	if err := decodeEntryData(&password, entry.Data); err != nil {
+		return Password{}, err
+	}
+	return password, nil
+}
+
+// entryFromPassword maps a service password to its storage representation.
+func entryFromPassword(password *Password) (storage.PasswordEntry, error) {
+	data, err := encodeEntryData(password)
+	if err != nil {
+		return storage.PasswordEntry{}, err
+	}
+	return storage.PasswordEntry{
+		ID:       password.ID,
+		Type:     password.Type,
+		Title:    password.Title,
+		Username: password.Username,
+		Password: password.Password,
+		URL:      password.URL,
+		URIs:     urisToStorage(password.URIs),
+		Notes:    password.Notes,
+		TOTP:     password.TOTP,
+		FolderID: password.FolderID,
+		Tags:     password.Tags,
+		Fields:   customFieldsToStorage(password.Fields),
+		Data:     data,
+		Favorite: password.Favorite,
+		Pinned:   password.Pinned,
+
+		RotationDays: password.RotationDays,
+	}, nil
+}
+
+// passwordsFromEntries maps a slice of storage entries to their service representation.
+func passwordsFromEntries(entries []storage.PasswordEntry) ([]Password, error) {
+	passwords := make([]Password, len(entries))
+	for i, entry := range entries {
+		password, err := passwordFromEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		passwords[i] = password
+	}
+	return passwords, nil
 }
 
 // PasswordService handles password storage and retrieval
@@ -24,6 +129,20 @@ type PasswordService struct {
 	db        *storage.DB
 	mu        sync.RWMutex
 	encryptor *encryption.Encryptor
+
+	attachments       attachments.Store
+	maxAttachmentSize int64
+
+	// minStrength is the strength score new and changed passwords must reach, 0 for none
+	minStrength int
+	// breaches is the local breach dataset, nil when none is configured
+	breaches BreachChecker
+	// rotationReminder is how long before its due date a rotation is reported as due, 0
+	// until SetRotationReminder is called
+	rotationReminder time.Duration
+
+	// index is the in-memory search index, nil while the vault is locked
+	index *searchIndex
 }
 
 // NewPasswordService creates a new password service instance
@@ -48,70 +167,128 @@ func (s *PasswordService) GetAllPasswords() ([]Password, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	if s.index == nil {
+		return nil, ErrVaultLocked
+	}
+
 	entries, err := s.db.GetAllPasswords()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get passwords: %w", err)
 	}
 
-	passwords := make([]Password, len(entries))
-	for i, entry := range entries {
-		passwords[i] = Password{
-			ID:       entry.ID,
-			Title:    entry.Title,
-			Username: entry.Username,
-			Password: entry.Password,
-			Notes:    entry.Notes,
-		}
+	return passwordsFromEntries(entries)
+}
+
+// GetPassword returns a single password entry, including trashed entries
+func (s *PasswordService) GetPassword(id uuid.UUID) (Password, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.index == nil {
+		return Password{}, ErrVaultLocked
 	}
 
-	return passwords, nil
+	entry, err := s.db.GetPassword(id)
+	if err != nil {
+		return Password{}, fmt.Errorf("failed to get password: %w", err)
+	}
+
+	return passwordFromEntry(entry)
 }
 
 // CreatePassword adds a new password entry
 func (s *PasswordService) CreatePassword(password *Password) error {
+	if err := validateEntry(password); err != nil {
+		return err
+	}
+	if err := validateCustomFields(password.Fields); err != nil {
+		return err
+	}
+	entry, err := entryFromPassword(password)
+	if err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	entry := storage.PasswordEntry{
-		Title:    password.Title,
-		Username: password.Username,
-		Password: password.Password,
-		Notes:    password.Notes,
+	if s.index == nil {
+		return ErrVaultLocked
 	}
 
+	if err := s.checkStrength(password); err != nil {
+		return err
+	}
+	if password.ID == uuid.Nil {
+		password.ID = uuid.New()
+	}
+	entry.ID = password.ID
 	if err := s.db.AddPassword(entry); err != nil {
 		return fmt.Errorf("failed to add password: %w", err)
 	}
+	s.reindex(password.ID)
 
 	return nil
 }
 
 // UpdatePassword updates an existing password entry
 func (s *PasswordService) UpdatePassword(password *Password) error {
+	if err := validateEntry(password); err != nil {
+		return err
+	}
+	if err := validateCustomFields(password.Fields); err != nil {
+		return err
+	}
+	entry, err := entryFromPassword(password)
+	if err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := s.db.UpdatePassword(storage.PasswordEntry{
-		ID:       password.ID,
-		Title:    password.Title,
-		Username: password.Username,
-		Password: password.Password,
-		Notes:    password.Notes,
-	}); err != nil {
+	// The updated entry is read back by the caller, which needs an unlocked vault
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	current, err := s.db.GetPassword(entry.ID)
+	if err != nil {
 		return fmt.Errorf("failed to update password: %w", err)
 	}
+	// The policy applies to changed passwords only, so other fields of an entry with an
+	// older, weaker password can still be edited
+	if s.minStrength > 0 && current.Password != password.Password {
+		if err := s.checkStrength(password); err != nil {
+			return err
+		}
+	}
+	// An HOTP counter only restarts from the seed when the seed changes, so saving an entry
+	// read before codes were generated does not hand out the same codes again
+	if sameOTPSecret(current.TOTP, entry.TOTP) {
+		entry.HOTPCounter = hotpCounterOf(current)
+	}
+	if err := s.db.UpdatePassword(entry); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	s.reindex(entry.ID)
 
 	return nil
 }
 
-// DeletePassword removes a password entry
+// DeletePassword moves a password entry to the trash
 func (s *PasswordService) DeletePassword(id uuid.UUID) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
 	if err := s.db.DeletePassword(id); err != nil {
 		return fmt.Errorf("failed to delete password: %w", err)
 	}
+	s.unindex(id)
 
 	return nil
 }
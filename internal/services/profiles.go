@@ -0,0 +1,261 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/storage"
+	"github.com/nonaxanon/vault-inator/internal/urlmatch"
+)
+
+// ErrInvalidGeneratorProfile is returned when a generator profile has no name or an
+// invalid URL pattern
+var ErrInvalidGeneratorProfile = errors.New("invalid generator profile")
+
+// GeneratorProfile is a named set of generator rules, such as a site's maximum length
+// and the symbols it rejects. A profile linked to URL patterns is used automatically when
+// generating a password for a matching site.
+type GeneratorProfile struct {
+	ID      uuid.UUID        `json:"id"`
+	Name    string           `json:"name"`
+	Options GeneratorOptions `json:"options"`
+	// URLPatterns are hosts such as "intranet.example.com", optionally with a port, a
+	// "*." prefix matching any subdomain, a scheme and a path prefix
+	URLPatterns []string  `json:"url_patterns"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// profileData is the part of a profile stored encrypted
+type profileData struct {
+	Name        string           `json:"name"`
+	Options     GeneratorOptions `json:"options"`
+	URLPatterns []string         `json:"url_patterns"`
+}
+
+// GenerateRequest asks for a password generated with Options, or with the rules of a
+// profile chosen by ProfileID or by the URL of the site the password is for. A URL
+// without a matching profile falls back to Options.
+type GenerateRequest struct {
+	GeneratorOptions
+	ProfileID *uuid.UUID `json:"profile_id,omitempty"`
+	URL       string     `json:"url,omitempty"`
+}
+
+// profileFromStorage maps a stored generator profile to its service representation
+func profileFromStorage(stored storage.GeneratorProfile) (GeneratorProfile, error) {
+	data := profileData{Options: DefaultGeneratorOptions()}
+	if err := json.Unmarshal([]byte(stored.Data), &data); err != nil {
+		return GeneratorProfile{}, fmt.Errorf("failed to decode generator profile: %w", err)
+	}
+	return GeneratorProfile{
+		ID:          stored.ID,
+		Name:        data.Name,
+		Options:     data.Options,
+		URLPatterns: data.URLPatterns,
+		CreatedAt:   stored.CreatedAt,
+		UpdatedAt:   stored.UpdatedAt,
+	}, nil
+}
+
+// encodeProfile validates a profile and returns the data to store
+func encodeProfile(profile GeneratorProfile) (string, error) {
+	data := profileData{
+		Name:        strings.TrimSpace(profile.Name),
+		Options:     profile.Options,
+		URLPatterns: []string{},
+	}
+	if data.Name == "" {
+		return "", fmt.Errorf("%w: name must not be empty", ErrInvalidGeneratorProfile)
+	}
+	for _, pattern := range profile.URLPatterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := urlmatch.ParsePattern(pattern); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidGeneratorProfile, err)
+		}
+		data.URLPatterns = append(data.URLPatterns, pattern)
+	}
+	// A profile must be able to generate something
+	if _, err := GeneratePassword(data.Options); err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode generator profile: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// GetGeneratorProfiles returns every generator profile
+func (s *PasswordService) GetGeneratorProfiles() ([]GeneratorProfile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.generatorProfiles()
+}
+
+func (s *PasswordService) generatorProfiles() ([]GeneratorProfile, error) {
+	stored, err := s.db.GetGeneratorProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get generator profiles: %w", err)
+	}
+	profiles := make([]GeneratorProfile, 0, len(stored))
+	for _, profile := range stored {
+		p, err := profileFromStorage(profile)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// GetGeneratorProfile returns a single generator profile
+func (s *PasswordService) GetGeneratorProfile(id uuid.UUID) (GeneratorProfile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, err := s.db.GetGeneratorProfile(id)
+	if err != nil {
+		return GeneratorProfile{}, fmt.Errorf("failed to get generator profile: %w", err)
+	}
+	return profileFromStorage(stored)
+}
+
+// CreateGeneratorProfile adds a new generator profile
+func (s *PasswordService) CreateGeneratorProfile(profile GeneratorProfile) (GeneratorProfile, error) {
+	data, err := encodeProfile(profile)
+	if err != nil {
+		return GeneratorProfile{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return GeneratorProfile{}, ErrVaultLocked
+	}
+
+	stored, err := s.db.AddGeneratorProfile(data)
+	if err != nil {
+		return GeneratorProfile{}, fmt.Errorf("failed to create generator profile: %w", err)
+	}
+	log.Printf("Created generator profile with ID: %s", stored.ID)
+	return profileFromStorage(stored)
+}
+
+// UpdateGeneratorProfile replaces the name, options and URL patterns of a generator profile
+func (s *PasswordService) UpdateGeneratorProfile(id uuid.UUID, profile GeneratorProfile) (GeneratorProfile, error) {
+	data, err := encodeProfile(profile)
+	if err != nil {
+		return GeneratorProfile{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return GeneratorProfile{}, ErrVaultLocked
+	}
+
+	if err := s.db.UpdateGeneratorProfile(id, data); err != nil {
+		return GeneratorProfile{}, fmt.Errorf("failed to update generator profile: %w", err)
+	}
+	stored, err := s.db.GetGeneratorProfile(id)
+	if err != nil {
+		return GeneratorProfile{}, fmt.Errorf("failed to get generator profile: %w", err)
+	}
+	return profileFromStorage(stored)
+}
+
+// DeleteGeneratorProfile deletes a generator profile
+func (s *PasswordService) DeleteGeneratorProfile(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.DeleteGeneratorProfile(id); err != nil {
+		return fmt.Errorf("failed to delete generator profile: %w", err)
+	}
+	return nil
+}
+
+// MatchGeneratorProfile returns the profile whose URL patterns match rawURL most
+// specifically, or nil when none does
+func (s *PasswordService) MatchGeneratorProfile(rawURL string) (*GeneratorProfile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.matchGeneratorProfile(rawURL)
+}
+
+func (s *PasswordService) matchGeneratorProfile(rawURL string) (*GeneratorProfile, error) {
+	target, err := urlmatch.ParseURL(rawURL)
+	if err != nil {
+		return nil, nil
+	}
+	profiles, err := s.generatorProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var best *GeneratorProfile
+	var bestScore urlmatch.Score
+	for i := range profiles {
+		for _, raw := range profiles[i].URLPatterns {
+			pattern, err := urlmatch.ParsePattern(raw)
+			if err != nil {
+				continue
+			}
+			if score, ok := pattern.Match(target); ok && (best == nil || score.Beats(bestScore)) {
+				best, bestScore = &profiles[i], score
+			}
+		}
+	}
+	return best, nil
+}
+
+// Generate generates a password for a request, applying the profile it names or the one
+// matching its URL
+func (s *PasswordService) Generate(req GenerateRequest) (GeneratedPassword, error) {
+	var profile *GeneratorProfile
+	switch {
+	case req.ProfileID != nil:
+		p, err := s.GetGeneratorProfile(*req.ProfileID)
+		if err != nil {
+			return GeneratedPassword{}, err
+		}
+		profile = &p
+	case req.URL != "":
+		p, err := s.MatchGeneratorProfile(req.URL)
+		if err != nil {
+			return GeneratedPassword{}, err
+		}
+		profile = p
+	}
+
+	opts := req.GeneratorOptions
+	if profile != nil {
+		opts = profile.Options
+	}
+	generated, err := GeneratePassword(opts)
+	if err != nil {
+		return GeneratedPassword{}, err
+	}
+	if profile != nil {
+		generated.ProfileID, generated.ProfileName = &profile.ID, profile.Name
+	}
+	return generated, nil
+}
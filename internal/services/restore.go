@@ -0,0 +1,304 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/storage"
+)
+
+// Restore modes
+const (
+	// RestoreMerge adds what the backup has and the vault lacks; entries in both keep the
+	// most recently updated version
+	RestoreMerge = "merge"
+	// RestoreReplace deletes every folder and entry, including the trash, before restoring
+	RestoreReplace = "replace"
+)
+
+// ErrInvalidRestoreMode is returned for a restore mode other than merge or replace
+var ErrInvalidRestoreMode = errors.New("restore mode must be merge or replace")
+
+// RestoreOptions controls RestoreVault.
+type RestoreOptions struct {
+	// Mode is RestoreMerge or RestoreReplace; empty means RestoreMerge
+	Mode string
+	// DryRun reports what would happen without writing anything
+	DryRun bool
+}
+
+// RestoreReport summarizes a restore.
+type RestoreReport struct {
+	DryRun bool   `json:"dry_run"`
+	Mode   string `json:"mode"`
+	// Removed is the number of entries deleted by a replace
+	Removed        int `json:"removed"`
+	FoldersCreated int `json:"folders_created"`
+	FoldersUpdated int `json:"folders_updated"`
+	Created        int `json:"created"`
+	Updated        int `json:"updated"`
+	// Unchanged entries already exist in the vault and are at least as recent
+	Unchanged        int `json:"unchanged"`
+	HistoryAdded     int `json:"history_added"`
+	AttachmentsAdded int `json:"attachments_added"`
+	// Errors lists attachments that could not be restored
+	Errors []string `json:"errors,omitempty"`
+}
+
+// restoredAttachment is an attachment of the backup that the vault does not have yet
+type restoredAttachment struct {
+	ExportedAttachment
+	passwordID uuid.UUID
+}
+
+// RestoreVault restores a backup written from ExportVault. Folders, entries, attachments
+// and history keep their IDs, so restoring the same backup again changes nothing. When
+// merging, an entry in both the vault and the backup is overwritten only if the backup
+// version was updated later; its replaced password goes to the history. Attachments are
+// stored once the entries have been written; failures are listed in the report. The
+// minimum strength policy does not apply, as a backup brings back passwords the vault
+// already held.
+func (s *PasswordService) RestoreVault(vault VaultExport, opts RestoreOptions) (RestoreReport, error) {
+	mode := opts.Mode
+	if mode == "" {
+		mode = RestoreMerge
+	}
+	if mode != RestoreMerge && mode != RestoreReplace {
+		return RestoreReport{}, fmt.Errorf("%w: %q", ErrInvalidRestoreMode, mode)
+	}
+	replace := mode == RestoreReplace
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return RestoreReport{}, ErrVaultLocked
+	}
+
+	storedFolders, err := s.db.GetAllFolders()
+	if err != nil {
+		return RestoreReport{}, fmt.Errorf("failed to get folders: %w", err)
+	}
+	live, err := s.db.GetAllPasswords()
+	if err != nil {
+		return RestoreReport{}, fmt.Errorf("failed to get passwords: %w", err)
+	}
+	trashed, err := s.db.GetTrashedPasswords()
+	if err != nil {
+		return RestoreReport{}, fmt.Errorf("failed to get trash: %w", err)
+	}
+	storedHistory, err := s.db.GetAllPasswordHistory()
+	if err != nil {
+		return RestoreReport{}, fmt.Errorf("failed to get password history: %w", err)
+	}
+
+	report := RestoreReport{DryRun: opts.DryRun, Mode: mode}
+	existingFolders := make(map[uuid.UUID]storage.Folder)
+	existing := make(map[uuid.UUID]storage.PasswordEntry)
+	// knownHistory holds the history already stored, by entry and replacement time
+	knownHistory := make(map[uuid.UUID]map[int64]bool)
+	if replace {
+		report.Removed = len(live) + len(trashed)
+	} else {
+		for _, folder := range storedFolders {
+			existingFolders[folder.ID] = folder
+		}
+		for _, entry := range append(live, trashed...) {
+			existing[entry.ID] = entry
+		}
+		for _, item := range storedHistory {
+			if knownHistory[item.PasswordID] == nil {
+				knownHistory[item.PasswordID] = make(map[int64]bool)
+			}
+			knownHistory[item.PasswordID][item.ChangedAt.UnixMicro()] = true
+		}
+	}
+
+	// Folders missing from both the backup and the vault leave their children at the root
+	folders := orderFolders(vault.Folders, existingFolders)
+	var writeFolders []storage.Folder
+	for _, folder := range folders {
+		current, ok := existingFolders[folder.ID]
+		if ok && current.Name == folder.Name && sameFolder(current.ParentID, folder.ParentID) && current.RotationDays == folder.RotationDays {
+			continue
+		}
+		if ok {
+			report.FoldersUpdated++
+		} else {
+			report.FoldersCreated++
+		}
+		writeFolders = append(writeFolders, storage.Folder{ID: folder.ID, ParentID: folder.ParentID, Name: folder.Name, CreatedAt: folder.CreatedAt, RotationDays: folder.RotationDays})
+	}
+	knownFolders := make(map[uuid.UUID]bool, len(folders)+len(existingFolders))
+	for _, folder := range folders {
+		knownFolders[folder.ID] = true
+	}
+	for id := range existingFolders {
+		knownFolders[id] = true
+	}
+
+	now := time.Now()
+	var entries []storage.PasswordEntry
+	var history []storage.PasswordHistory
+	for _, password := range vault.Passwords {
+		if password.FolderID != nil && !knownFolders[*password.FolderID] {
+			password.FolderID = nil
+		}
+		// Archives written before entries carried URIs only have the URL
+		if len(password.URIs) == 0 && password.URL != "" {
+			password.URIs = []URI{{URL: password.URL}}
+		}
+		earlier := vault.History[password.ID]
+
+		current, ok := existing[password.ID]
+		if ok && !password.UpdatedAt.After(current.UpdatedAt) {
+			report.Unchanged++
+		} else {
+			entry, err := entryFromPassword(&password)
+			if err != nil {
+				return RestoreReport{}, err
+			}
+			entry.CreatedAt, entry.UpdatedAt = password.CreatedAt, password.UpdatedAt
+			entry.UseCount, entry.LastUsedAt = password.UseCount, password.LastUsedAt
+			entries = append(entries, entry)
+
+			if ok {
+				report.Updated++
+				// What the backup version replaces is kept like an update would keep it
+				replaced := storage.PasswordHistory{PasswordID: password.ID, Fields: storage.ReplacedHiddenFields(current.Fields, entry.Fields), ChangedAt: now}
+				if current.Password != "" && current.Password != password.Password && !inHistory(earlier, current.Password) {
+					replaced.Password = current.Password
+				}
+				if replaced.Password != "" || len(replaced.Fields) > 0 {
+					history = append(history, replaced)
+				}
+			} else {
+				report.Created++
+			}
+		}
+
+		for _, item := range earlier {
+			changedAt := item.ChangedAt.UnixMicro()
+			if knownHistory[password.ID][changedAt] {
+				continue
+			}
+			if knownHistory[password.ID] == nil {
+				knownHistory[password.ID] = make(map[int64]bool)
+			}
+			knownHistory[password.ID][changedAt] = true
+			history = append(history, historyToStorage(password.ID, item))
+		}
+	}
+	report.HistoryAdded = len(history)
+
+	var files []restoredAttachment
+	for passwordID, attachments := range vault.Attachments {
+		known := make(map[uuid.UUID]bool)
+		if _, ok := existing[passwordID]; ok {
+			stored, err := s.db.GetAttachments(passwordID)
+			if err != nil {
+				return RestoreReport{}, fmt.Errorf("failed to get attachments: %w", err)
+			}
+			for _, attachment := range stored {
+				known[attachment.ID] = true
+			}
+		}
+		for _, attachment := range attachments {
+			if known[attachment.ID] {
+				continue
+			}
+			if s.attachments == nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("attachment %q skipped: %v", attachment.Name, ErrAttachmentsDisabled))
+				continue
+			}
+			files = append(files, restoredAttachment{ExportedAttachment: attachment, passwordID: passwordID})
+		}
+	}
+	report.AttachmentsAdded = len(files)
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	removed, err := s.db.RestoreVault(replace, writeFolders, entries, history)
+	if err != nil {
+		return RestoreReport{}, fmt.Errorf("failed to restore vault: %w", err)
+	}
+	s.deleteBlobs(removed)
+
+	// Attachment blobs live outside the database, so they are stored once the entries exist
+	for _, file := range files {
+		if _, err := s.storeAttachment(s.attachments, s.maxAttachmentSize, file.ID, file.passwordID, file.Name, file.ContentType, bytes.NewReader(file.Data)); err != nil {
+			log.Printf("Failed to restore attachment %s: %v", file.ID, err)
+			report.AttachmentsAdded--
+			report.Errors = append(report.Errors, fmt.Sprintf("attachment %q not restored: %v", file.Name, err))
+		}
+	}
+
+	if err := s.rebuildIndex(); err != nil {
+		return RestoreReport{}, err
+	}
+	return report, nil
+}
+
+// orderFolders returns the backup folders with every parent before its children. A parent
+// that is neither in the backup nor in existing is dropped, placing the folder at the root.
+func orderFolders(folders []Folder, existing map[uuid.UUID]storage.Folder) []Folder {
+	byID := make(map[uuid.UUID]Folder, len(folders))
+	for _, folder := range folders {
+		byID[folder.ID] = folder
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	ordered := make([]Folder, 0, len(folders))
+	state := make(map[uuid.UUID]int, len(folders))
+	var visit func(folder Folder)
+	visit = func(folder Folder) {
+		if state[folder.ID] != 0 {
+			return
+		}
+		state[folder.ID] = visiting
+		if folder.ParentID != nil {
+			parent, inBackup := byID[*folder.ParentID]
+			_, inVault := existing[*folder.ParentID]
+			if inBackup {
+				visit(parent)
+			}
+			// A parent that is still being visited is part of a cycle
+			if (inBackup && state[parent.ID] != visited) || (!inBackup && !inVault) {
+				folder.ParentID = nil
+			}
+		}
+		state[folder.ID] = visited
+		ordered = append(ordered, folder)
+	}
+	for _, folder := range folders {
+		visit(folder)
+	}
+	return ordered
+}
+
+// sameFolder reports whether two parent references point at the same folder
+func sameFolder(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// inHistory reports whether password is one of the earlier passwords
+func inHistory(history []PasswordHistory, password string) bool {
+	for _, item := range history {
+		if item.Password == password {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/storage"
+)
+
+// ErrInvalidRotation is returned for a rotation interval outside 0 to MaxRotationDays
+var ErrInvalidRotation = errors.New("invalid rotation interval")
+
+// MaxRotationDays is the longest rotation interval, ten years
+const MaxRotationDays = 3650
+
+// Rotation states
+const (
+	RotationOK      = "ok"
+	RotationDue     = "due"
+	RotationOverdue = "overdue"
+)
+
+// RotationStatus is where an entry stands against its rotation interval
+type RotationStatus struct {
+	IntervalDays int `json:"interval_days"`
+	// FolderID is the folder the interval is inherited from, nil when the entry sets it
+	FolderID  *uuid.UUID `json:"folder_id,omitempty"`
+	ChangedAt time.Time  `json:"changed_at"`
+	DueAt     time.Time  `json:"due_at"`
+	// DaysLeft is the number of whole days until DueAt, negative once overdue
+	DaysLeft int    `json:"days_left"`
+	State    string `json:"state"`
+}
+
+// SetRotationReminder sets how long before its due date a rotation is reported as due
+func (s *PasswordService) SetRotationReminder(window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rotationReminder = window
+}
+
+// SetFolderRotation sets the rotation interval inherited by entries in a folder and its
+// subfolders that do not set their own; 0 removes it
+func (s *PasswordService) SetFolderRotation(id uuid.UUID, days int) error {
+	if days < 0 || days > MaxRotationDays {
+		return fmt.Errorf("%w: must be between 0 and %d days", ErrInvalidRotation, MaxRotationDays)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.SetFolderRotation(id, days); err != nil {
+		return fmt.Errorf("failed to set folder rotation: %w", err)
+	}
+	return nil
+}
+
+// rotationPolicy resolves rotation intervals and password change times of the vault
+type rotationPolicy struct {
+	folders  map[uuid.UUID]storage.Folder
+	changes  map[uuid.UUID]time.Time
+	reminder time.Duration
+	now      time.Time
+}
+
+// loadRotation reads the folder intervals and password change times; the caller holds s.mu
+func (s *PasswordService) loadRotation() (*rotationPolicy, error) {
+	folders, err := s.db.GetAllFolders()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folders: %w", err)
+	}
+	changes, err := s.db.GetPasswordChangeTimes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password changes: %w", err)
+	}
+
+	policy := &rotationPolicy{
+		folders:  make(map[uuid.UUID]storage.Folder, len(folders)),
+		changes:  changes,
+		reminder: s.rotationReminder,
+		now:      time.Now(),
+	}
+	for _, folder := range folders {
+		policy.folders[folder.ID] = folder
+	}
+	return policy, nil
+}
+
+// interval returns the rotation interval of an entry in folderID setting days, and the
+// folder it is inherited from. Only login passwords are rotated; their changes are the
+// ones kept in the history.
+func (p *rotationPolicy) interval(entryType string, folderID *uuid.UUID, days int) (int, *uuid.UUID) {
+	if entryType != EntryTypeLogin {
+		return 0, nil
+	}
+	if days > 0 {
+		return days, nil
+	}
+	// The depth bound guards against a cycle in corrupted data
+	for depth := 0; folderID != nil && depth <= len(p.folders); depth++ {
+		folder, ok := p.folders[*folderID]
+		if !ok {
+			break
+		}
+		if folder.RotationDays > 0 {
+			id := folder.ID
+			return folder.RotationDays, &id
+		}
+		folderID = folder.ParentID
+	}
+	return 0, nil
+}
+
+// status computes the rotation status of an entry, nil when no interval applies
+func (p *rotationPolicy) status(id uuid.UUID, entryType string, folderID *uuid.UUID, days int, createdAt time.Time) *RotationStatus {
+	interval, from := p.interval(entryType, folderID, days)
+	if interval == 0 {
+		return nil
+	}
+	changedAt, ok := p.changes[id]
+	if !ok {
+		changedAt = createdAt
+	}
+
+	status := &RotationStatus{
+		IntervalDays: interval,
+		FolderID:     from,
+		ChangedAt:    changedAt,
+		DueAt:        changedAt.AddDate(0, 0, interval),
+		State:        RotationOK,
+	}
+	left := status.DueAt.Sub(p.now)
+	status.DaysLeft = int(math.Floor(left.Hours() / 24))
+	switch {
+	case left <= 0:
+		status.State = RotationOverdue
+	case left <= p.reminder:
+		status.State = RotationDue
+	}
+	return status
+}
+
+// isDue reports whether a status is due or overdue
+func (status *RotationStatus) isDue() bool {
+	return status != nil && status.State != RotationOK
+}
+
+// RotationReminder is an entry whose password is due or overdue for rotation
+type RotationReminder struct {
+	ID       uuid.UUID      `json:"id"`
+	Title    string         `json:"title"`
+	Rotation RotationStatus `json:"rotation"`
+}
+
+// DueRotations lists the entries whose password is due or overdue for rotation, most
+// overdue first. It fails with ErrVaultLocked while the vault is locked.
+func (s *PasswordService) DueRotations() ([]RotationReminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.index == nil {
+		return nil, ErrVaultLocked
+	}
+	rotation, err := s.loadRotation()
+	if err != nil {
+		return nil, err
+	}
+
+	reminders := []RotationReminder{}
+	for _, doc := range s.index.docs {
+		if status := rotation.docStatus(doc); status.isDue() {
+			reminders = append(reminders, RotationReminder{ID: doc.summary.ID, Title: doc.summary.Title, Rotation: *status})
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		return reminders[i].Rotation.DueAt.Before(reminders[j].Rotation.DueAt)
+	})
+	return reminders, nil
+}
+
+// docStatus computes the rotation status of an indexed entry
+func (p *rotationPolicy) docStatus(doc *searchDocument) *RotationStatus {
+	summary := doc.summary
+	return p.status(summary.ID, summary.Type, summary.FolderID, doc.rotationDays, summary.CreatedAt)
+}
+
+// RunRotationReminders logs a reminder for every entry due or overdue for rotation once
+// immediately and then on every interval until the context is cancelled. Nothing is
+// reported while the vault is locked.
+func (s *PasswordService) RunRotationReminders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		reminders, err := s.DueRotations()
+		switch {
+		case errors.Is(err, ErrVaultLocked):
+		case err != nil:
+			log.Printf("Rotation reminder check failed: %v", err)
+		default:
+			for _, reminder := range reminders {
+				if reminder.Rotation.State == RotationOverdue {
+					log.Printf("Password %s is overdue for rotation by %d days", reminder.ID, -reminder.Rotation.DaysLeft)
+				} else {
+					log.Printf("Password %s is due for rotation in %d days", reminder.ID, reminder.Rotation.DaysLeft)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/storage"
+)
+
+// Listing limits
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+var (
+	ErrInvalidCursor  = errors.New("invalid cursor")
+	ErrInvalidSortKey = errors.New("invalid sort key")
+)
+
+// Sort keys accepted by SearchPasswords
+const (
+	SortByTitle    = "title"
+	SortByUsername = "username"
+	SortByURL      = "url"
+	SortByType     = "type"
+	SortByCreated  = "created"
+	SortByUpdated  = "updated"
+	// SortByRelevance orders by how well entries match the query, best first
+	SortByRelevance = "relevance"
+	// SortByFavorites lists pinned entries, then favorites, then the rest, each by title
+	SortByFavorites = "favorites"
+	// SortByMostUsed orders by use count, most used first
+	SortByMostUsed = "most_used"
+)
+
+// SortKeys lists every supported sort key
+var SortKeys = []string{SortByTitle, SortByUsername, SortByURL, SortByType, SortByCreated, SortByUpdated, SortByRelevance, SortByFavorites, SortByMostUsed}
+
+// PasswordFilter selects which entries ListPasswords returns. Zero values do not filter.
+type PasswordFilter struct {
+	// FolderID limits results to a folder, and to its subfolders when IncludeSubfolders is set
+	FolderID          *uuid.UUID
+	IncludeSubfolders bool
+	// Unfiled limits results to entries that are not in any folder
+	Unfiled bool
+	// Tags limits results to entries carrying all (MatchAllTags) or any of the given tags
+	Tags         []string
+	MatchAllTags bool
+	// Types limits results to entries of the given types
+	Types []string
+	// Due limits results to entries whose password rotation is due or overdue
+	Due bool
+}
+
+// SearchOptions controls SearchPasswords.
+type SearchOptions struct {
+	Filter PasswordFilter
+	// Query is a space separated list of terms that must all match by prefix, substring or
+	// fuzzily. A term may be scoped to one field with a prefix: title:, username:, url:, tag:
+	// or field:
+	Query string
+	// Sort defaults to relevance when there is a query and to title otherwise
+	Sort       string
+	Descending bool
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+	Limit  int
+}
+
+// PasswordSummary is the listing projection of an entry. It never carries secrets;
+// the full entry is fetched explicitly with GetPassword.
+type PasswordSummary struct {
+	ID        uuid.UUID  `json:"id"`
+	Type      string     `json:"type"`
+	Title     string     `json:"title"`
+	Username  string     `json:"username"`
+	URL       string     `json:"url"`
+	URIs      []URI      `json:"uris,omitempty"`
+	FolderID  *uuid.UUID `json:"folder_id,omitempty"`
+	Tags      []string   `json:"tags"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	// HasTOTP tells whether a one-time password can be generated for the entry
+	HasTOTP bool `json:"has_totp"`
+	// Rotation is set for entries with a rotation interval, directly or from a folder
+	Rotation *RotationStatus `json:"rotation,omitempty"`
+
+	Favorite   bool       `json:"favorite"`
+	Pinned     bool       `json:"pinned"`
+	UseCount   int64      `json:"use_count"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// PasswordPage is one page of search results.
+type PasswordPage struct {
+	Items      []PasswordSummary `json:"items"`
+	Total      int               `json:"total"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// summarize projects an entry to its listing representation
+func summarize(password Password) PasswordSummary {
+	return PasswordSummary{
+		ID:        password.ID,
+		Type:      password.Type,
+		Title:     password.Title,
+		Username:  password.Username,
+		URL:       password.URL,
+		URIs:      password.URIs,
+		FolderID:  password.FolderID,
+		Tags:      password.Tags,
+		CreatedAt: password.CreatedAt,
+		UpdatedAt: password.UpdatedAt,
+		HasTOTP:   password.TOTP != "",
+
+		Favorite:   password.Favorite,
+		Pinned:     password.Pinned,
+		UseCount:   password.UseCount,
+		LastUsedAt: password.LastUsedAt,
+	}
+}
+
+// ListPasswords returns the non-trashed entries matching the filter
+func (s *PasswordService) ListPasswords(filter PasswordFilter) ([]Password, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.index == nil {
+		return nil, ErrVaultLocked
+	}
+
+	return s.listPasswords(filter)
+}
+
+// listPasswords implements ListPasswords; the caller holds s.mu
+func (s *PasswordService) listPasswords(filter PasswordFilter) ([]Password, error) {
+	storageFilter, err := s.storageFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.db.FindPasswords(storageFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get passwords: %w", err)
+	}
+	if filter.Due {
+		rotation, err := s.loadRotation()
+		if err != nil {
+			return nil, err
+		}
+		due := entries[:0]
+		for _, entry := range entries {
+			if rotation.status(entry.ID, entry.Type, entry.FolderID, entry.RotationDays, entry.CreatedAt).isDue() {
+				due = append(due, entry)
+			}
+		}
+		entries = due
+	}
+	return passwordsFromEntries(entries)
+}
+
+// storageFilter resolves a filter to its storage form, expanding subfolders; the caller holds s.mu
+func (s *PasswordService) storageFilter(filter PasswordFilter) (storage.PasswordFilter, error) {
+	storageFilter := storage.PasswordFilter{
+		Unfiled:      filter.Unfiled,
+		Tags:         filter.Tags,
+		MatchAllTags: filter.MatchAllTags,
+		Types:        filter.Types,
+	}
+	if filter.FolderID != nil && !filter.Unfiled {
+		storageFilter.FolderIDs = []uuid.UUID{*filter.FolderID}
+		if filter.IncludeSubfolders {
+			ids, err := s.db.GetFolderDescendantIDs(*filter.FolderID)
+			if err != nil {
+				return storage.PasswordFilter{}, fmt.Errorf("failed to get subfolders: %w", err)
+			}
+			storageFilter.FolderIDs = ids
+		}
+	}
+	return storageFilter, nil
+}
+
+// isEmpty reports whether the filter selects every entry
+func (filter PasswordFilter) isEmpty() bool {
+	return filter.FolderID == nil && !filter.Unfiled && len(filter.Tags) == 0 && len(filter.Types) == 0
+}
+
+// SearchPasswords filters, searches, sorts and paginates the vault using the in-memory
+// search index, returning summaries only. It fails with ErrVaultLocked while the vault is locked.
+func (s *PasswordService) SearchPasswords(opts SearchOptions) (PasswordPage, error) {
+	terms := parseSearchQuery(opts.Query)
+	if opts.Sort == "" {
+		opts.Sort = SortByTitle
+		if len(terms) > 0 {
+			opts.Sort = SortByRelevance
+		}
+	}
+	if !isSortKey(opts.Sort) {
+		return PasswordPage{}, fmt.Errorf("%w: %q", ErrInvalidSortKey, opts.Sort)
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultPageSize
+	}
+	if opts.Limit > MaxPageSize {
+		opts.Limit = MaxPageSize
+	}
+
+	var after *pageCursor
+	if opts.Cursor != "" {
+		cursor, err := decodeCursor(opts.Cursor)
+		if err != nil || cursor.Sort != opts.Sort || cursor.Desc != opts.Descending {
+			return PasswordPage{}, ErrInvalidCursor
+		}
+		after = &cursor
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.index == nil {
+		return PasswordPage{}, ErrVaultLocked
+	}
+
+	// Folder, tag and type filters run in the database on IDs only, without decrypting entries
+	var allowed map[uuid.UUID]bool
+	if !opts.Filter.isEmpty() {
+		storageFilter, err := s.storageFilter(opts.Filter)
+		if err != nil {
+			return PasswordPage{}, err
+		}
+		ids, err := s.db.FindPasswordIDs(storageFilter)
+		if err != nil {
+			return PasswordPage{}, fmt.Errorf("failed to get passwords: %w", err)
+		}
+		allowed = make(map[uuid.UUID]bool, len(ids))
+		for _, id := range ids {
+			allowed[id] = true
+		}
+	}
+
+	hits := s.index.search(terms, allowed)
+	rotation, err := s.loadRotation()
+	if err != nil {
+		return PasswordPage{}, err
+	}
+	statuses := make(map[uuid.UUID]*RotationStatus)
+	matches := hits[:0]
+	for _, hit := range hits {
+		status := rotation.docStatus(hit.doc)
+		if opts.Filter.Due && !status.isDue() {
+			continue
+		}
+		statuses[hit.doc.summary.ID] = status
+		matches = append(matches, hit)
+	}
+
+	page := paginate(matches, opts, after)
+	for i := range page.Items {
+		page.Items[i].Rotation = statuses[page.Items[i].ID]
+	}
+	return page, nil
+}
+
+// paginate sorts matches by the requested key and returns the page following the cursor
+func paginate(matches []searchHit, opts SearchOptions, after *pageCursor) PasswordPage {
+	less := func(aValue string, aID uuid.UUID, bValue string, bID uuid.UUID) bool {
+		if aValue != bValue {
+			if opts.Descending {
+				return aValue > bValue
+			}
+			return aValue < bValue
+		}
+		// IDs break ties so the order is total and cursors are stable
+		return aID.String() < bID.String()
+	}
+	values := make(map[uuid.UUID]string, len(matches))
+	for _, match := range matches {
+		values[match.doc.summary.ID] = sortValue(match, opts.Sort)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i].doc.summary.ID, matches[j].doc.summary.ID
+		return less(values[a], a, values[b], b)
+	})
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(matches), func(i int) bool {
+			id := matches[i].doc.summary.ID
+			return less(after.Value, after.ID, values[id], id)
+		})
+	}
+	end := start + opts.Limit
+	if end > len(matches) {
+		end = len(matches)
+	}
+
+	page := PasswordPage{
+		Items: make([]PasswordSummary, 0, end-start),
+		Total: len(matches),
+	}
+	for _, match := range matches[start:end] {
+		page.Items = append(page.Items, match.doc.summary)
+	}
+	if end < len(matches) {
+		last := matches[end-1].doc.summary.ID
+		page.NextCursor = encodeCursor(pageCursor{
+			Sort:  opts.Sort,
+			Desc:  opts.Descending,
+			Value: values[last],
+			ID:    last,
+		})
+	}
+	return page
+}
+
+// isSortKey reports whether key is a supported sort key
+func isSortKey(key string) bool {
+	for _, sortKey := range SortKeys {
+		if key == sortKey {
+			return true
+		}
+	}
+	return false
+}
+
+// sortValue returns the comparable value of a match for a sort key. Timestamps and
+// relevance scores are formatted so that they sort lexicographically, best match first.
+func sortValue(match searchHit, key string) string {
+	doc := match.doc
+	switch key {
+	case SortByUsername:
+		return doc.username
+	case SortByURL:
+		return strings.ToLower(doc.summary.URL)
+	case SortByType:
+		return doc.summary.Type
+	case SortByCreated:
+		return doc.summary.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000000")
+	case SortByUpdated:
+		return doc.summary.UpdatedAt.UTC().Format("2006-01-02T15:04:05.000000000")
+	case SortByRelevance:
+		return fmt.Sprintf("%06d %s", 999999-match.score, doc.title)
+	case SortByFavorites:
+		rank := 2
+		if doc.summary.Pinned {
+			rank = 0
+		} else if doc.summary.Favorite {
+			rank = 1
+		}
+		return fmt.Sprintf("%d %s", rank, doc.title)
+	case SortByMostUsed:
+		return fmt.Sprintf("%019d %s", math.MaxInt64-doc.summary.UseCount, doc.title)
+	default:
+		return doc.title
+	}
+}
+
+// pageCursor identifies the last entry of a page
+type pageCursor struct {
+	Sort  string    `json:"s"`
+	Desc  bool      `json:"d,omitempty"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+// encodeCursor serializes a cursor to an opaque string
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor produced by encodeCursor
+func decodeCursor(encoded string) (pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return pageCursor{}, err
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return pageCursor{}, err
+	}
+	return cursor, nil
+}
+
+// searchTerm is one lower-cased term of a search query, optionally scoped to a field
+type searchTerm struct {
+	field string
+	value string
+}
+
+// searchFields are the prefixes that scope a term to one field
+var searchFields = map[string]bool{
+	"title": true, "username": true, "url": true, "tag": true, "field": true,
+}
+
+// parseSearchQuery splits a query into terms. Double quotes group words into one term.
+func parseSearchQuery(query string) []searchTerm {
+	var terms []searchTerm
+	var current strings.Builder
+	quoted := false
+	flush := func() {
+		token := current.String()
+		current.Reset()
+		if token == "" {
+			return
+		}
+		term := searchTerm{value: strings.ToLower(token)}
+		if i := strings.Index(token, ":"); i > 0 && searchFields[strings.ToLower(token[:i])] {
+			term.field = strings.ToLower(token[:i])
+			term.value = strings.ToLower(strings.Trim(token[i+1:], `"`))
+		}
+		if term.value != "" {
+			terms = append(terms, term)
+		}
+	}
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return terms
+}
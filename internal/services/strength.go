@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nonaxanon/vault-inator/internal/strength"
+)
+
+// ErrPasswordTooWeak is returned when a new or changed password scores below the minimum
+// strength policy
+var ErrPasswordTooWeak = errors.New("password is too weak")
+
+// MaxStrengthScore is the highest strength score
+const MaxStrengthScore = 4
+
+// SetMinStrength sets the score, 1 to MaxStrengthScore, that new and changed passwords
+// must reach; 0 turns the policy off
+func (s *PasswordService) SetMinStrength(score int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.minStrength = min(max(score, 0), MaxStrengthScore)
+}
+
+// MinStrength returns the minimum strength score, 0 when there is no policy
+func (s *PasswordService) MinStrength() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.minStrength
+}
+
+// EstimateStrength estimates the strength of an entry's password, treating its title
+// and username as words an attacker would try first. Entries without a password, such as
+// secure notes, have no estimate.
+func EstimateStrength(password *Password) *strength.Result {
+	if password.Password == "" {
+		return nil
+	}
+	result := strength.Estimate(password.Password, strength.SplitUserInputs(password.Title, password.Username)...)
+	return &result
+}
+
+// checkStrength enforces the minimum strength policy on an entry's password
+func (s *PasswordService) checkStrength(password *Password) error {
+	if s.minStrength == 0 {
+		return nil
+	}
+	result := EstimateStrength(password)
+	if result == nil || result.Score >= s.minStrength {
+		return nil
+	}
+	err := fmt.Errorf("%w: scored %d of %d, at least %d is required", ErrPasswordTooWeak, result.Score, MaxStrengthScore, s.minStrength)
+	if result.Feedback.Warning != "" {
+		err = fmt.Errorf("%w (%s)", err, result.Feedback.Warning)
+	}
+	return err
+}
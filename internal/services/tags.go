@@ -0,0 +1,46 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// TagCount is a tag with the number of entries carrying it.
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// GetTagCounts returns every tag with the number of entries carrying it
+func (s *PasswordService) GetTagCounts() ([]TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, err := s.db.GetTagCounts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	counts := make([]TagCount, len(stored))
+	for i, count := range stored {
+		counts[i] = TagCount{Name: count.Name, Count: count.Count}
+	}
+	return counts, nil
+}
+
+// SetPasswordTags replaces the tags of a password entry
+func (s *PasswordService) SetPasswordTags(id uuid.UUID, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.SetPasswordTags(id, tags); err != nil {
+		return fmt.Errorf("failed to set tags: %w", err)
+	}
+	s.reindex(id)
+	return nil
+}
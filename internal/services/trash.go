@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GetTrashedPasswords returns all password entries currently in the trash
+func (s *PasswordService) GetTrashedPasswords() ([]Password, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.index == nil {
+		return nil, ErrVaultLocked
+	}
+
+	entries, err := s.db.GetTrashedPasswords()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trashed passwords: %w", err)
+	}
+
+	return passwordsFromEntries(entries)
+}
+
+// RestorePassword moves a trashed password entry back into the vault
+func (s *PasswordService) RestorePassword(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.RestorePassword(id); err != nil {
+		return fmt.Errorf("failed to restore password: %w", err)
+	}
+	s.reindex(id)
+
+	return nil
+}
+
+// PurgePassword permanently deletes a trashed password entry and its attachments
+func (s *PasswordService) PurgePassword(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	attachmentIDs, err := s.db.PurgePassword(id)
+	if err != nil {
+		return fmt.Errorf("failed to purge password: %w", err)
+	}
+	s.deleteBlobs(attachmentIDs)
+
+	return nil
+}
+
+// EmptyTrash permanently deletes every trashed password entry
+func (s *PasswordService) EmptyTrash() (int64, error) {
+	if !s.IsUnlocked() {
+		return 0, ErrVaultLocked
+	}
+	return s.PurgeExpiredTrash(0)
+}
+
+// PurgeExpiredTrash permanently deletes trashed entries older than the retention period.
+// It runs on a timer and works while the vault is locked, as it only removes entries
+// already past their retention.
+func (s *PasswordService) PurgeExpiredTrash(retention time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged, attachmentIDs, err := s.db.PurgeTrash(time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge trash: %w", err)
+	}
+	s.deleteBlobs(attachmentIDs)
+
+	return purged, nil
+}
+
+// RunTrashPurger purges expired trash once immediately and then on every interval
+// until the context is cancelled
+func (s *PasswordService) RunTrashPurger(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.PurgeExpiredTrash(retention); err != nil {
+			log.Printf("Trash purge failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/nonaxanon/vault-inator/internal/otp"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrInvalidEntry is returned when an entry fails validation for its type
+var ErrInvalidEntry = errors.New("invalid entry")
+
+// Entry types
+const (
+	EntryTypeLogin         = "login"
+	EntryTypeSecureNote    = "secure_note"
+	EntryTypeCard          = "card"
+	EntryTypeIdentity      = "identity"
+	EntryTypeSSHKey        = "ssh_key"
+	EntryTypeAPICredential = "api_credential"
+	EntryTypeDatabase      = "database"
+)
+
+// EntryTypes lists every supported entry type
+var EntryTypes = []string{
+	EntryTypeLogin,
+	EntryTypeSecureNote,
+	EntryTypeCard,
+	EntryTypeIdentity,
+	EntryTypeSSHKey,
+	EntryTypeAPICredential,
+	EntryTypeDatabase,
+}
+
+// IsEntryType reports whether t is a supported entry type
+func IsEntryType(t string) bool {
+	for _, entryType := range EntryTypes {
+		if t == entryType {
+			return true
+		}
+	}
+	return false
+}
+
+// Card holds the details of a payment card entry.
+type Card struct {
+	Cardholder  string `json:"cardholder"`
+	Brand       string `json:"brand,omitempty"`
+	Number      string `json:"number"`
+	ExpiryMonth int    `json:"expiry_month"`
+	ExpiryYear  int    `json:"expiry_year"`
+	CVV         string `json:"cvv,omitempty"`
+	PIN         string `json:"pin,omitempty"`
+}
+
+// Identity holds the details of an identity entry.
+type Identity struct {
+	Title          string `json:"title,omitempty"`
+	FirstName      string `json:"first_name"`
+	MiddleName     string `json:"middle_name,omitempty"`
+	LastName       string `json:"last_name"`
+	Email          string `json:"email,omitempty"`
+	Phone          string `json:"phone,omitempty"`
+	Company        string `json:"company,omitempty"`
+	Address1       string `json:"address1,omitempty"`
+	Address2       string `json:"address2,omitempty"`
+	City           string `json:"city,omitempty"`
+	State          string `json:"state,omitempty"`
+	PostalCode     string `json:"postal_code,omitempty"`
+	Country        string `json:"country,omitempty"`
+	SSN            string `json:"ssn,omitempty"`
+	PassportNumber string `json:"passport_number,omitempty"`
+	LicenseNumber  string `json:"license_number,omitempty"`
+}
+
+// SSHKey holds an SSH key pair. The public key is derived from the private key when
+// omitted, and the fingerprint is always computed.
+type SSHKey struct {
+	PrivateKey  string `json:"private_key"`
+	PublicKey   string `json:"public_key,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Passphrase  string `json:"passphrase,omitempty"`
+}
+
+// APICredential holds an API key pair and where it is used.
+type APICredential struct {
+	KeyID    string `json:"key_id,omitempty"`
+	Secret   string `json:"secret"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+}
+
+// DatabaseConnection holds the details needed to connect to a database.
+type DatabaseConnection struct {
+	Engine   string `json:"engine"`
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Database string `json:"database,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Options  string `json:"options,omitempty"`
+}
+
+// validateEntry normalizes the entry type and checks that the entry carries exactly the
+// payload its type expects.
+func validateEntry(password *Password) error {
+	if password.Type == "" {
+		password.Type = EntryTypeLogin
+	}
+	if !IsEntryType(password.Type) {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidEntry, password.Type)
+	}
+	if strings.TrimSpace(password.Title) == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidEntry)
+	}
+	if password.RotationDays < 0 || password.RotationDays > MaxRotationDays {
+		return fmt.Errorf("%w: rotation interval must be between 0 and %d days", ErrInvalidEntry, MaxRotationDays)
+	}
+	if err := normalizeURIs(password); err != nil {
+		return err
+	}
+	if password.TOTP = strings.TrimSpace(password.TOTP); password.TOTP != "" {
+		if password.Type != EntryTypeLogin {
+			return fmt.Errorf("%w: one-time passwords are only allowed on login entries", ErrInvalidEntry)
+		}
+		key, err := otp.Parse(password.TOTP)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidEntry, err)
+		}
+		// Counters are stored as a signed 64-bit integer
+		if key.Counter > math.MaxInt64 {
+			return fmt.Errorf("%w: HOTP counter must be at most %d", ErrInvalidEntry, int64(math.MaxInt64))
+		}
+	}
+
+	payloads := map[string]bool{
+		EntryTypeCard:          password.Card != nil,
+		EntryTypeIdentity:      password.Identity != nil,
+		EntryTypeSSHKey:        password.SSHKey != nil,
+		EntryTypeAPICredential: password.APICredential != nil,
+		EntryTypeDatabase:      password.Database != nil,
+	}
+	for entryType, present := range payloads {
+		if present && entryType != password.Type {
+			return fmt.Errorf("%w: %s data is not allowed on %s entries", ErrInvalidEntry, entryType, password.Type)
+		}
+	}
+
+	switch password.Type {
+	case EntryTypeSecureNote:
+		if password.Password != "" {
+			return fmt.Errorf("%w: secure notes cannot carry a password", ErrInvalidEntry)
+		}
+	case EntryTypeCard:
+		return validateCard(password.Card)
+	case EntryTypeIdentity:
+		return validateIdentity(password.Identity)
+	case EntryTypeSSHKey:
+		return validateSSHKey(password.SSHKey)
+	case EntryTypeAPICredential:
+		return validateAPICredential(password.APICredential)
+	case EntryTypeDatabase:
+		return validateDatabase(password.Database)
+	}
+	return nil
+}
+
+// validateCard checks the card number with the Luhn algorithm and the expiry and CVV formats
+func validateCard(card *Card) error {
+	if card == nil {
+		return fmt.Errorf("%w: card details are required", ErrInvalidEntry)
+	}
+
+	card.Number = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, card.Number)
+	if len(card.Number) < 12 || len(card.Number) > 19 || !isDigits(card.Number) || !luhnValid(card.Number) {
+		return fmt.Errorf("%w: card number is not valid", ErrInvalidEntry)
+	}
+
+	if card.ExpiryMonth < 1 || card.ExpiryMonth > 12 {
+		return fmt.Errorf("%w: expiry month must be between 1 and 12", ErrInvalidEntry)
+	}
+	if card.ExpiryYear < 100 {
+		card.ExpiryYear += 2000
+	}
+	if card.ExpiryYear < 1970 || card.ExpiryYear > time.Now().Year()+50 {
+		return fmt.Errorf("%w: expiry year is not valid", ErrInvalidEntry)
+	}
+
+	if card.CVV != "" && (len(card.CVV) < 3 || len(card.CVV) > 4 || !isDigits(card.CVV)) {
+		return fmt.Errorf("%w: CVV must be 3 or 4 digits", ErrInvalidEntry)
+	}
+	if card.PIN != "" && !isDigits(card.PIN) {
+		return fmt.Errorf("%w: PIN must be numeric", ErrInvalidEntry)
+	}
+	return nil
+}
+
+// validateIdentity requires a name and checks the email address format
+func validateIdentity(identity *Identity) error {
+	if identity == nil {
+		return fmt.Errorf("%w: identity details are required", ErrInvalidEntry)
+	}
+	if strings.TrimSpace(identity.FirstName) == "" && strings.TrimSpace(identity.LastName) == "" {
+		return fmt.Errorf("%w: identity needs a first or last name", ErrInvalidEntry)
+	}
+	if identity.Email != "" {
+		if _, err := mail.ParseAddress(identity.Email); err != nil {
+			return fmt.Errorf("%w: email address is not valid", ErrInvalidEntry)
+		}
+	}
+	return nil
+}
+
+// validateSSHKey parses the key pair and fills in the public key and fingerprint
+func validateSSHKey(key *SSHKey) error {
+	if key == nil {
+		return fmt.Errorf("%w: SSH key details are required", ErrInvalidEntry)
+	}
+	if strings.TrimSpace(key.PrivateKey) == "" && strings.TrimSpace(key.PublicKey) == "" {
+		return fmt.Errorf("%w: SSH key needs a private or public key", ErrInvalidEntry)
+	}
+
+	var publicKey ssh.PublicKey
+	if key.PrivateKey != "" {
+		var signer ssh.Signer
+		var err error
+		if key.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key.PrivateKey), []byte(key.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(key.PrivateKey))
+		}
+		if err != nil {
+			return fmt.Errorf("%w: private key cannot be parsed: %v", ErrInvalidEntry, err)
+		}
+		publicKey = signer.PublicKey()
+	}
+
+	if key.PublicKey != "" {
+		parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key.PublicKey))
+		if err != nil {
+			return fmt.Errorf("%w: public key cannot be parsed: %v", ErrInvalidEntry, err)
+		}
+		if publicKey != nil && ssh.FingerprintSHA256(parsed) != ssh.FingerprintSHA256(publicKey) {
+			return fmt.Errorf("%w: public key does not match private key", ErrInvalidEntry)
+		}
+		publicKey = parsed
+	} else {
+		key.PublicKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey)))
+	}
+
+	key.Fingerprint = ssh.FingerprintSHA256(publicKey)
+	return nil
+}
+
+// validateAPICredential requires a secret
+func validateAPICredential(credential *APICredential) error {
+	if credential == nil {
+		return fmt.Errorf("%w: API credential details are required", ErrInvalidEntry)
+	}
+	if credential.Secret == "" {
+		return fmt.Errorf("%w: API credential needs a secret", ErrInvalidEntry)
+	}
+	return nil
+}
+
+// validateDatabase requires an engine and host and checks the port range
+func validateDatabase(database *DatabaseConnection) error {
+	if database == nil {
+		return fmt.Errorf("%w: database connection details are required", ErrInvalidEntry)
+	}
+	if strings.TrimSpace(database.Engine) == "" || strings.TrimSpace(database.Host) == "" {
+		return fmt.Errorf("%w: database connection needs an engine and host", ErrInvalidEntry)
+	}
+	if database.Port < 0 || database.Port > 65535 {
+		return fmt.Errorf("%w: port must be between 1 and 65535", ErrInvalidEntry)
+	}
+	return nil
+}
+
+// encodeEntryData serializes the type-specific payload of an entry
+func encodeEntryData(password *Password) (string, error) {
+	var payload interface{}
+	switch password.Type {
+	case EntryTypeCard:
+		payload = password.Card
+	case EntryTypeIdentity:
+		payload = password.Identity
+	case EntryTypeSSHKey:
+		payload = password.SSHKey
+	case EntryTypeAPICredential:
+		payload = password.APICredential
+	case EntryTypeDatabase:
+		payload = password.Database
+	default:
+		return "", nil
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s data: %w", password.Type, err)
+	}
+	return string(data), nil
+}
+
+// decodeEntryData parses a stored type-specific payload into the matching field of the entry
+func decodeEntryData(password *Password, data string) error {
+	if data == "" {
+		return nil
+	}
+
+	var target interface{}
+	switch password.Type {
+	case EntryTypeCard:
+		password.Card = &Card{}
+		target = password.Card
+	case EntryTypeIdentity:
+		password.Identity = &Identity{}
+		target = password.Identity
+	case EntryTypeSSHKey:
+		password.SSHKey = &SSHKey{}
+		target = password.SSHKey
+	case EntryTypeAPICredential:
+		password.APICredential = &APICredential{}
+		target = password.APICredential
+	case EntryTypeDatabase:
+		password.Database = &DatabaseConnection{}
+		target = password.Database
+	default:
+		return nil
+	}
+
+	if err := json.Unmarshal([]byte(data), target); err != nil {
+		return fmt.Errorf("failed to decode %s data: %w", password.Type, err)
+	}
+	return nil
+}
+
+// isDigits reports whether s consists only of ASCII digits
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// luhnValid reports whether a digit string passes the Luhn checksum
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nonaxanon/vault-inator/internal/storage"
+	"github.com/nonaxanon/vault-inator/internal/urlmatch"
+)
+
+// MaxURIs is the maximum number of URIs on one entry
+const MaxURIs = 50
+
+// URI is one of the URLs an entry is used on, with the mode it is matched with; an empty
+// mode uses urlmatch.DefaultMode
+type URI struct {
+	URL   string `json:"url"`
+	Match string `json:"match,omitempty"`
+}
+
+// normalizeURIs trims the URIs of an entry and drops empty ones. An entry given only a URL
+// gets it as its single URI, matched with URLMatch; otherwise URL and URLMatch, when given,
+// must agree with the first URI. URL and URLMatch are then set from the first URI.
+// Free-form URLs are accepted; a URI with an explicit match mode must work with it.
+func normalizeURIs(password *Password) error {
+	uris := make([]URI, 0, len(password.URIs))
+	for _, uri := range password.URIs {
+		uri.URL = strings.TrimSpace(uri.URL)
+		uri.Match = strings.TrimSpace(uri.Match)
+		if uri.URL == "" {
+			continue
+		}
+		uris = append(uris, uri)
+	}
+	url := strings.TrimSpace(password.URL)
+	match := strings.TrimSpace(password.URLMatch)
+	if len(uris) == 0 {
+		if url != "" {
+			uris = append(uris, URI{URL: url, Match: match})
+		} else if match != "" {
+			return fmt.Errorf("%w: url_match requires a URL", ErrInvalidEntry)
+		}
+	} else {
+		if url != "" && url != uris[0].URL {
+			return fmt.Errorf("%w: url %q differs from the first URI %q", ErrInvalidEntry, url, uris[0].URL)
+		}
+		if match != "" {
+			if uris[0].Match != "" && uris[0].Match != match {
+				return fmt.Errorf("%w: url_match %q differs from the match mode of the first URI %q", ErrInvalidEntry, match, uris[0].Match)
+			}
+			uris[0].Match = match
+		}
+	}
+	if len(uris) > MaxURIs {
+		return fmt.Errorf("%w: at most %d URIs are allowed", ErrInvalidEntry, MaxURIs)
+	}
+	for _, uri := range uris {
+		if uri.Match == "" {
+			continue
+		}
+		if err := urlmatch.Validate(uri.URL, uri.Match); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidEntry, err)
+		}
+	}
+
+	password.URIs = uris
+	password.URL, password.URLMatch = "", ""
+	if len(uris) > 0 {
+		password.URL, password.URLMatch = uris[0].URL, uris[0].Match
+	}
+	return nil
+}
+
+// hasURI reports whether uris contains url, ignoring case
+func hasURI(uris []URI, url string) bool {
+	for _, uri := range uris {
+		if strings.EqualFold(uri.URL, url) {
+			return true
+		}
+	}
+	return false
+}
+
+// urisFromStorage maps stored URIs to their service representation
+func urisFromStorage(uris []storage.URI) []URI {
+	if uris == nil {
+		return nil
+	}
+	out := make([]URI, len(uris))
+	for i, uri := range uris {
+		out[i] = URI{URL: uri.URL, Match: uri.Match}
+	}
+	return out
+}
+
+// firstURIMatch returns the match mode of the first stored URI, reported as URLMatch
+func firstURIMatch(uris []storage.URI) string {
+	if len(uris) == 0 {
+		return ""
+	}
+	return uris[0].Match
+}
+
+// urisToStorage maps service URIs to their storage representation
+func urisToStorage(uris []URI) []storage.URI {
+	if uris == nil {
+		return nil
+	}
+	out := make([]storage.URI, len(uris))
+	for i, uri := range uris {
+		out[i] = storage.URI{URL: uri.URL, Match: uri.Match}
+	}
+	return out
+}
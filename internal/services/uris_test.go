@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeURIs(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       Password
+		want     []URI
+		wantURL  string
+		wantMode string
+	}{
+		{
+			name:    "url only",
+			in:      Password{URL: " https://example.com "},
+			want:    []URI{{URL: "https://example.com"}},
+			wantURL: "https://example.com",
+		},
+		{
+			name:     "url with url_match",
+			in:       Password{URL: "https://example.com", URLMatch: "host"},
+			want:     []URI{{URL: "https://example.com", Match: "host"}},
+			wantURL:  "https://example.com",
+			wantMode: "host",
+		},
+		{
+			name:    "uris set the url",
+			in:      Password{URIs: []URI{{URL: " "}, {URL: "https://a.example.com"}, {URL: "https://b.example.com"}}},
+			want:    []URI{{URL: "https://a.example.com"}, {URL: "https://b.example.com"}},
+			wantURL: "https://a.example.com",
+		},
+		{
+			name:    "url repeating the first uri",
+			in:      Password{URL: "https://a.example.com", URIs: []URI{{URL: "https://a.example.com"}, {URL: "https://b.example.com"}}},
+			want:    []URI{{URL: "https://a.example.com"}, {URL: "https://b.example.com"}},
+			wantURL: "https://a.example.com",
+		},
+		{
+			name:     "url_match applies to the first uri",
+			in:       Password{URLMatch: "host_port", URIs: []URI{{URL: "https://a.example.com"}, {URL: "https://b.example.com"}}},
+			want:     []URI{{URL: "https://a.example.com", Match: "host_port"}, {URL: "https://b.example.com"}},
+			wantURL:  "https://a.example.com",
+			wantMode: "host_port",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			password := tt.in
+			if err := normalizeURIs(&password); err != nil {
+				t.Fatalf("normalizeURIs: %v", err)
+			}
+			if !reflect.DeepEqual(password.URIs, tt.want) {
+				t.Errorf("URIs = %+v, want %+v", password.URIs, tt.want)
+			}
+			if password.URL != tt.wantURL || password.URLMatch != tt.wantMode {
+				t.Errorf("URL, URLMatch = %q, %q, want %q, %q", password.URL, password.URLMatch, tt.wantURL, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestNormalizeURIsConflicts(t *testing.T) {
+	uris := []URI{{URL: "https://a.example.com", Match: "host"}}
+	tests := map[string]Password{
+		"url differs from the first uri": {URL: "https://b.example.com", URIs: uris},
+		"url_match differs":              {URLMatch: "regex", URIs: uris},
+		"url_match without a url":        {URLMatch: "host"},
+		"unknown url_match":              {URL: "https://example.com", URLMatch: "fuzzy"},
+	}
+	for name, password := range tests {
+		if err := normalizeURIs(&password); !errors.Is(err, ErrInvalidEntry) {
+			t.Errorf("%s: normalizeURIs = %v, want ErrInvalidEntry", name, err)
+		}
+	}
+}
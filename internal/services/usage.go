@@ -0,0 +1,45 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// PasswordFlags updates the favorite and pinned flags of an entry. Omitted flags are unchanged.
+type PasswordFlags struct {
+	Favorite *bool `json:"favorite,omitempty"`
+	Pinned   *bool `json:"pinned,omitempty"`
+}
+
+// SetPasswordFlags marks or unmarks an entry as a favorite or pinned
+func (s *PasswordService) SetPasswordFlags(id uuid.UUID, flags PasswordFlags) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.SetPasswordFlags(id, flags.Favorite, flags.Pinned); err != nil {
+		return fmt.Errorf("failed to set password flags: %w", err)
+	}
+	s.reindex(id)
+	return nil
+}
+
+// RecordUse counts a reveal or copy of an entry's secrets
+func (s *PasswordService) RecordUse(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		return ErrVaultLocked
+	}
+
+	if err := s.db.RecordPasswordUse(id); err != nil {
+		return fmt.Errorf("failed to record use: %w", err)
+	}
+	s.reindex(id)
+	return nil
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/encryption"
+)
+
+// Attachment describes a file attached to an entry. The blob itself lives in an
+// attachments.Store, encrypted with Key; Key is wrapped with the vault key at rest.
+type Attachment struct {
+	ID          uuid.UUID
+	PasswordID  uuid.UUID
+	Name        string
+	ContentType string
+	Size        int64
+	Key         []byte
+	CreatedAt   time.Time
+}
+
+// attachmentColumns is the column list shared by every query that scans an Attachment.
+const attachmentColumns = `id, password_id, name, content_type, size, encryption_key, created_at`
+
+// scanAttachment scans a row selected with attachmentColumns and decrypts its metadata and key.
+func (db *DB) scanAttachment(row rowScanner) (Attachment, error) {
+	var attachment Attachment
+	var name, contentType, wrappedKey string
+	if err := row.Scan(&attachment.ID, &attachment.PasswordID, &name, &contentType, &attachment.Size, &wrappedKey, &attachment.CreatedAt); err != nil {
+		return Attachment{}, err
+	}
+
+	var err error
+	if attachment.Name, err = db.encryptor.Decrypt(name); err != nil {
+		return Attachment{}, fmt.Errorf("failed to decrypt attachment name: %v", err)
+	}
+	if attachment.ContentType, err = db.encryptor.Decrypt(contentType); err != nil {
+		return Attachment{}, fmt.Errorf("failed to decrypt attachment content type: %v", err)
+	}
+	if attachment.Key, err = unwrapKey(db.encryptor, wrappedKey); err != nil {
+		return Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// wrapKey encrypts a per-attachment key with the vault encryptor.
+func wrapKey(enc *encryption.Encryptor, key []byte) (string, error) {
+	wrapped, err := enc.Encrypt(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap attachment key: %v", err)
+	}
+	return wrapped, nil
+}
+
+// unwrapKey decrypts a per-attachment key wrapped by wrapKey.
+func unwrapKey(enc *encryption.Encryptor, wrapped string) ([]byte, error) {
+	encoded, err := enc.Decrypt(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap attachment key: %v", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode attachment key: %v", err)
+	}
+	return key, nil
+}
+
+// AddAttachment records the metadata of a new attachment. The blob is stored separately.
+func (db *DB) AddAttachment(attachment Attachment) error {
+	name, err := db.encryptor.Encrypt(attachment.Name)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt attachment name: %v", err)
+	}
+	contentType, err := db.encryptor.Encrypt(attachment.ContentType)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt attachment content type: %v", err)
+	}
+	wrappedKey, err := wrapKey(db.encryptor, attachment.Key)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO vaultinator.attachments (id, password_id, name, content_type, size, encryption_key)
+	VALUES ($1, $2, $3, $4, $5, $6);`
+	if _, err := db.Exec(query, attachment.ID, attachment.PasswordID, name, contentType, attachment.Size, wrappedKey); err != nil {
+		return fmt.Errorf("failed to add attachment: %v", err)
+	}
+	log.Printf("Added attachment with ID: %s", attachment.ID)
+	return nil
+}
+
+// SetAttachmentSize records the plaintext size of an attachment once its upload completes.
+func (db *DB) SetAttachmentSize(id uuid.UUID, size int64) error {
+	if _, err := db.Exec(`UPDATE vaultinator.attachments SET size = $1 WHERE id = $2;`, size, id); err != nil {
+		return fmt.Errorf("failed to update attachment size: %v", err)
+	}
+	return nil
+}
+
+// GetAttachment retrieves the metadata of an attachment by its ID.
+func (db *DB) GetAttachment(id uuid.UUID) (Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM vaultinator.attachments WHERE id = $1;`
+	attachment, err := db.scanAttachment(db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Attachment{}, fmt.Errorf("no attachment found with ID %s: %w", id, ErrNotFound)
+	}
+	return attachment, err
+}
+
+// GetAttachments retrieves the metadata of every attachment on a password entry.
+func (db *DB) GetAttachments(passwordID uuid.UUID) ([]Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM vaultinator.attachments WHERE password_id = $1 ORDER BY created_at;`
+	rows, err := db.Query(query, passwordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []Attachment
+	for rows.Next() {
+		attachment, err := db.scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// DeleteAttachment deletes the metadata of an attachment. The caller removes the blob.
+func (db *DB) DeleteAttachment(id uuid.UUID) error {
+	result, err := db.Exec(`DELETE FROM vaultinator.attachments WHERE id = $1;`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no attachment found with ID %s: %w", id, ErrNotFound)
+	}
+	log.Printf("Deleted attachment with ID: %s", id)
+	return nil
+}
+
+// scanIDs collects a single UUID column from a query.
+func scanIDs(q queryer, query string, args ...interface{}) ([]uuid.UUID, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// rekeyAttachments re-encrypts attachment metadata and re-wraps attachment keys with a new encryptor.
+// The blobs themselves are untouched because their keys do not change.
+func (db *DB) rekeyAttachments(q queryer, newEncryptor *encryption.Encryptor) error {
+	rows, err := db.Query(`SELECT ` + attachmentColumns + ` FROM vaultinator.attachments;`)
+	if err != nil {
+		return fmt.Errorf("failed to get attachments: %v", err)
+	}
+	defer rows.Close()
+
+	var attachments []Attachment
+	for rows.Next() {
+		attachment, err := db.scanAttachment(rows)
+		if err != nil {
+			return err
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, attachment := range attachments {
+		name, err := newEncryptor.Encrypt(attachment.Name)
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt attachment name: %v", err)
+		}
+		contentType, err := newEncryptor.Encrypt(attachment.ContentType)
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt attachment content type: %v", err)
+		}
+		wrappedKey, err := wrapKey(newEncryptor, attachment.Key)
+		if err != nil {
+			return err
+		}
+		query := `UPDATE vaultinator.attachments SET name = $1, content_type = $2, encryption_key = $3 WHERE id = $4;`
+		if _, err := q.Exec(query, name, contentType, wrappedKey, attachment.ID); err != nil {
+			return fmt.Errorf("failed to update attachment: %v", err)
+		}
+	}
+	return nil
+}
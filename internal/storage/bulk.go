@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// ErrBatchFailed is returned by ApplyBulk when at least one item failed and the whole
+// batch was rolled back.
+var ErrBatchFailed = errors.New("batch failed, no changes were applied")
+
+// Bulk operations supported by ApplyBulk
+const (
+	BulkMove       = "move"
+	BulkAddTags    = "add_tags"
+	BulkRemoveTags = "remove_tags"
+	BulkTrash      = "trash"
+	BulkRestore    = "restore"
+	BulkPurge      = "purge"
+)
+
+// BulkOperation describes what ApplyBulk does to every entry of a batch.
+type BulkOperation struct {
+	Kind string
+	// FolderID is the destination of BulkMove, nil for the root
+	FolderID *uuid.UUID
+	// Tags are the tag names of BulkAddTags and BulkRemoveTags
+	Tags []string
+}
+
+// ApplyBulk applies an operation to every entry in a single transaction with all-or-nothing
+// semantics. It returns one error per ID, nil for items that succeeded. When any item fails the
+// transaction is rolled back and ErrBatchFailed is returned alongside the per-item errors.
+// On success it also returns the IDs of attachments deleted by BulkPurge so the caller can
+// remove their blobs.
+func (db *DB) ApplyBulk(ids []uuid.UUID, op BulkOperation) ([]error, []uuid.UUID, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if op.Kind == BulkMove && op.FolderID != nil {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM vaultinator.folders WHERE id = $1);`, *op.FolderID).Scan(&exists); err != nil {
+			return nil, nil, err
+		}
+		if !exists {
+			return nil, nil, fmt.Errorf("no folder found with ID %s: %w", *op.FolderID, ErrNotFound)
+		}
+	}
+
+	itemErrs := make([]error, len(ids))
+	var attachmentIDs []uuid.UUID
+	failed := false
+	for i, id := range ids {
+		// A savepoint per item keeps the transaction usable after a failed statement so
+		// every item gets an accurate result
+		if _, err := tx.Exec(`SAVEPOINT bulk_item;`); err != nil {
+			return nil, nil, fmt.Errorf("failed to create savepoint: %v", err)
+		}
+
+		var purged []uuid.UUID
+		switch op.Kind {
+		case BulkMove:
+			itemErrs[i] = movePassword(tx, id, op.FolderID)
+		case BulkAddTags:
+			if itemErrs[i] = requirePassword(tx, id); itemErrs[i] == nil {
+				itemErrs[i] = db.addPasswordTags(tx, id, op.Tags)
+			}
+		case BulkRemoveTags:
+			if itemErrs[i] = requirePassword(tx, id); itemErrs[i] == nil {
+				itemErrs[i] = db.removePasswordTags(tx, id, op.Tags)
+			}
+		case BulkTrash:
+			itemErrs[i] = trashPassword(tx, id)
+		case BulkRestore:
+			itemErrs[i] = restorePassword(tx, id)
+		case BulkPurge:
+			purged, itemErrs[i] = purgePassword(tx, id)
+		default:
+			return nil, nil, fmt.Errorf("unknown bulk operation %q", op.Kind)
+		}
+
+		if itemErrs[i] != nil {
+			failed = true
+			if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT bulk_item;`); err != nil {
+				return nil, nil, fmt.Errorf("failed to roll back savepoint: %v", err)
+			}
+			continue
+		}
+		if _, err := tx.Exec(`RELEASE SAVEPOINT bulk_item;`); err != nil {
+			return nil, nil, fmt.Errorf("failed to release savepoint: %v", err)
+		}
+		attachmentIDs = append(attachmentIDs, purged...)
+	}
+
+	if failed {
+		return itemErrs, nil, ErrBatchFailed
+	}
+	if op.Kind == BulkRemoveTags || op.Kind == BulkPurge {
+		if err := pruneTags(tx); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	log.Printf("Applied bulk %s to %d password entries", op.Kind, len(ids))
+	return itemErrs, attachmentIDs, nil
+}
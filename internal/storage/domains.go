@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/encryption"
+)
+
+// EquivalentDomains is a stored group of domains treated as one site. Domains is the
+// JSON-encoded list, which is encrypted at rest.
+type EquivalentDomains struct {
+	ID        uuid.UUID
+	Domains   string
+	CreatedAt time.Time
+}
+
+// scanEquivalentDomains scans an equivalent domains row and decrypts its domains.
+func (db *DB) scanEquivalentDomains(row rowScanner) (EquivalentDomains, error) {
+	var group EquivalentDomains
+	var encryptedDomains string
+	if err := row.Scan(&group.ID, &encryptedDomains, &group.CreatedAt); err != nil {
+		return EquivalentDomains{}, err
+	}
+	domains, err := db.encryptor.Decrypt(encryptedDomains)
+	if err != nil {
+		return EquivalentDomains{}, fmt.Errorf("failed to decrypt equivalent domains: %v", err)
+	}
+	group.Domains = domains
+	return group, nil
+}
+
+// AddEquivalentDomains adds a new group of equivalent domains with a generated ID.
+func (db *DB) AddEquivalentDomains(domains string) (EquivalentDomains, error) {
+	encryptedDomains, err := db.encryptor.Encrypt(domains)
+	if err != nil {
+		return EquivalentDomains{}, fmt.Errorf("failed to encrypt equivalent domains: %v", err)
+	}
+
+	group := EquivalentDomains{ID: uuid.New(), Domains: domains}
+	query := `
+	INSERT INTO vaultinator.equivalent_domains (id, domains)
+	VALUES ($1, $2)
+	RETURNING created_at;`
+	if err := db.QueryRow(query, group.ID, encryptedDomains).Scan(&group.CreatedAt); err != nil {
+		return EquivalentDomains{}, fmt.Errorf("failed to add equivalent domains: %v", err)
+	}
+	log.Printf("Added equivalent domains with ID: %s", group.ID)
+	return group, nil
+}
+
+// GetEquivalentDomains retrieves every group of equivalent domains ordered by creation time.
+func (db *DB) GetEquivalentDomains() ([]EquivalentDomains, error) {
+	query := `SELECT id, domains, created_at FROM vaultinator.equivalent_domains ORDER BY created_at;`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []EquivalentDomains
+	for rows.Next() {
+		group, err := db.scanEquivalentDomains(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// UpdateEquivalentDomains replaces the domains of a group.
+func (db *DB) UpdateEquivalentDomains(id uuid.UUID, domains string) error {
+	encryptedDomains, err := db.encryptor.Encrypt(domains)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt equivalent domains: %v", err)
+	}
+
+	result, err := db.Exec(`UPDATE vaultinator.equivalent_domains SET domains = $1 WHERE id = $2;`, encryptedDomains, id)
+	if err != nil {
+		return fmt.Errorf("failed to update equivalent domains: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no equivalent domains found with ID %s: %w", id, ErrNotFound)
+	}
+	log.Printf("Updated equivalent domains with ID: %s", id)
+	return nil
+}
+
+// DeleteEquivalentDomains deletes a group of equivalent domains.
+func (db *DB) DeleteEquivalentDomains(id uuid.UUID) error {
+	result, err := db.Exec(`DELETE FROM vaultinator.equivalent_domains WHERE id = $1;`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete equivalent domains: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no equivalent domains found with ID %s: %w", id, ErrNotFound)
+	}
+	log.Printf("Deleted equivalent domains with ID: %s", id)
+	return nil
+}
+
+// rekeyEquivalentDomains re-encrypts every group of equivalent domains with newEncryptor using q.
+func (db *DB) rekeyEquivalentDomains(q queryer, newEncryptor *encryption.Encryptor) error {
+	groups, err := db.GetEquivalentDomains()
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		encryptedDomains, err := newEncryptor.Encrypt(group.Domains)
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt equivalent domains: %v", err)
+		}
+		if _, err := q.Exec(`UPDATE vaultinator.equivalent_domains SET domains = $1 WHERE id = $2;`, encryptedDomains, group.ID); err != nil {
+			return fmt.Errorf("failed to update equivalent domains: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/nonaxanon/vault-inator/internal/encryption"
+)
+
+// Custom field types storage treats specially: hidden values are encrypted at rest and URL
+// fields from older imports are migrated to URIs.
+const (
+	hiddenFieldType = "hidden"
+	urlFieldType    = "url"
+)
+
+// CustomField is a user-defined field on an entry. Fields keep the order they were given in.
+type CustomField struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// encodeCustomFields serializes custom fields to JSON text for the custom_fields column,
+// encrypting the values of hidden fields with enc.
+func encodeCustomFields(enc *encryption.Encryptor, fields []CustomField) (string, error) {
+	stored := make([]CustomField, len(fields))
+	for i, field := range fields {
+		stored[i] = field
+		if field.Type == hiddenFieldType {
+			encryptedValue, err := enc.Encrypt(field.Value)
+			if err != nil {
+				return "", fmt.Errorf("failed to encrypt custom field: %v", err)
+			}
+			stored[i].Value = encryptedValue
+		}
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeCustomFields parses the custom_fields column and decrypts the values of hidden fields.
+func decodeCustomFields(enc *encryption.Encryptor, data []byte) ([]CustomField, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var fields []CustomField
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse custom fields: %v", err)
+	}
+	for i, field := range fields {
+		if field.Type == hiddenFieldType {
+			value, err := enc.Decrypt(field.Value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt custom field: %v", err)
+			}
+			fields[i].Value = value
+		}
+	}
+	return fields, nil
+}
+
+// encryptData encrypts a type-specific payload. An empty payload is stored as is.
+func encryptData(enc *encryption.Encryptor, data string) (string, error) {
+	if data == "" {
+		return "", nil
+	}
+	encryptedData, err := enc.Encrypt(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt entry data: %v", err)
+	}
+	return encryptedData, nil
+}
+
+// decryptData decrypts a type-specific payload stored by encryptData.
+func decryptData(enc *encryption.Encryptor, encryptedData string) (string, error) {
+	if encryptedData == "" {
+		return "", nil
+	}
+	data, err := enc.Decrypt(encryptedData)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt entry data: %v", err)
+	}
+	return data, nil
+}
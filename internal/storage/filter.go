@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// PasswordFilter restricts which non-trashed password entries FindPasswords returns.
+// Zero values do not filter.
+type PasswordFilter struct {
+	// FolderIDs limits results to entries filed directly in one of these folders
+	FolderIDs []uuid.UUID
+	// Unfiled limits results to entries that are not in any folder
+	Unfiled bool
+	// Tags limits results to entries carrying the given tags
+	Tags []string
+	// MatchAllTags requires every tag to be present instead of any of them
+	MatchAllTags bool
+	// Types limits results to entries of one of these types
+	Types []string
+}
+
+// FindPasswords retrieves the non-trashed password entries matching the filter.
+func (db *DB) FindPasswords(filter PasswordFilter) ([]PasswordEntry, error) {
+	where, args := db.filterConditions(filter)
+	query := `SELECT ` + passwordColumns + ` FROM vaultinator.passwords WHERE ` + where + `;`
+	return db.queryPasswords(query, args...)
+}
+
+// FindPasswordIDs returns the IDs of the non-trashed password entries matching the filter
+// without loading or decrypting the entries.
+func (db *DB) FindPasswordIDs(filter PasswordFilter) ([]uuid.UUID, error) {
+	where, args := db.filterConditions(filter)
+	return scanIDs(db, `SELECT id FROM vaultinator.passwords WHERE `+where+`;`, args...)
+}
+
+// filterConditions builds the WHERE clause and its arguments for a filter.
+func (db *DB) filterConditions(filter PasswordFilter) (string, []interface{}) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.Unfiled {
+		conditions = append(conditions, "folder_id IS NULL")
+	} else if len(filter.FolderIDs) > 0 {
+		conditions = append(conditions, "folder_id = ANY("+arg(pq.Array(uuidStrings(filter.FolderIDs)))+"::uuid[])")
+	}
+
+	if len(filter.Types) > 0 {
+		conditions = append(conditions, "entry_type = ANY("+arg(pq.Array(filter.Types))+")")
+	}
+
+	if len(filter.Tags) > 0 {
+		indexes := make([]string, 0, len(filter.Tags))
+		seen := make(map[string]bool)
+		for _, tag := range filter.Tags {
+			index := db.tagIndex(tag)
+			if !seen[index] {
+				seen[index] = true
+				indexes = append(indexes, index)
+			}
+		}
+
+		tagged := `
+		SELECT pt.password_id
+		FROM vaultinator.password_tags pt
+		JOIN vaultinator.tags t ON t.id = pt.tag_id
+		WHERE t.name_index = ANY(` + arg(pq.Array(indexes)) + `)`
+		if filter.MatchAllTags {
+			tagged += ` GROUP BY pt.password_id HAVING COUNT(DISTINCT t.id) = ` + arg(len(indexes))
+		}
+		conditions = append(conditions, "id IN ("+tagged+")")
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
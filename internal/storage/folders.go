@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrFolderCycle is returned when a folder would become its own ancestor.
+var ErrFolderCycle = errors.New("folder cannot be moved into itself or one of its descendants")
+
+// Folder represents a stored folder. A nil ParentID places the folder at the root.
+type Folder struct {
+	ID        uuid.UUID
+	ParentID  *uuid.UUID
+	Name      string
+	CreatedAt time.Time
+	// RotationDays is the rotation interval inherited by entries in the folder; 0 sets none
+	RotationDays int
+}
+
+// scanFolder scans a folder row and decrypts its name.
+func (db *DB) scanFolder(row rowScanner) (Folder, error) {
+	var folder Folder
+	var parentID uuid.NullUUID
+	var encryptedName string
+	if err := row.Scan(&folder.ID, &parentID, &encryptedName, &folder.CreatedAt, &folder.RotationDays); err != nil {
+		return Folder{}, err
+	}
+	if parentID.Valid {
+		folder.ParentID = &parentID.UUID
+	}
+
+	name, err := db.encryptor.Decrypt(encryptedName)
+	if err != nil {
+		return Folder{}, fmt.Errorf("failed to decrypt folder name: %v", err)
+	}
+	folder.Name = name
+
+	return folder, nil
+}
+
+// AddFolder adds a new folder to the database.
+// A new ID is generated unless the folder already carries one.
+func (db *DB) AddFolder(folder Folder) (Folder, error) {
+	if err := db.insertFolder(db, &folder); err != nil {
+		return Folder{}, err
+	}
+	log.Printf("Added folder with ID: %s", folder.ID)
+	return folder, nil
+}
+
+// insertFolder inserts a folder using q, generating its ID if needed and filling in CreatedAt.
+func (db *DB) insertFolder(q queryer, folder *Folder) error {
+	encryptedName, err := db.encryptor.Encrypt(folder.Name)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt folder name: %v", err)
+	}
+
+	if folder.ID == uuid.Nil {
+		folder.ID = uuid.New()
+	}
+	query := `
+	INSERT INTO vaultinator.folders (id, parent_id, name, rotation_days)
+	VALUES ($1, $2, $3, $4)
+	RETURNING created_at;`
+	return q.QueryRow(query, folder.ID, folder.ParentID, encryptedName, folder.RotationDays).Scan(&folder.CreatedAt)
+}
+
+// GetFolder retrieves a folder by its ID.
+func (db *DB) GetFolder(id uuid.UUID) (Folder, error) {
+	query := `SELECT id, parent_id, name, created_at, rotation_days FROM vaultinator.folders WHERE id = $1;`
+	folder, err := db.scanFolder(db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Folder{}, fmt.Errorf("no folder found with ID %s: %w", id, ErrNotFound)
+	}
+	return folder, err
+}
+
+// GetAllFolders retrieves every folder as a flat list ordered by creation time.
+func (db *DB) GetAllFolders() ([]Folder, error) {
+	query := `SELECT id, parent_id, name, created_at, rotation_days FROM vaultinator.folders ORDER BY created_at;`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var folders []Folder
+	for rows.Next() {
+		folder, err := db.scanFolder(rows)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+// RenameFolder replaces the name of a folder.
+func (db *DB) RenameFolder(id uuid.UUID, name string) error {
+	encryptedName, err := db.encryptor.Encrypt(name)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt folder name: %v", err)
+	}
+
+	result, err := db.Exec(`UPDATE vaultinator.folders SET name = $1 WHERE id = $2;`, encryptedName, id)
+	if err != nil {
+		return fmt.Errorf("failed to rename folder: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no folder found with ID %s: %w", id, ErrNotFound)
+	}
+	log.Printf("Renamed folder with ID: %s", id)
+	return nil
+}
+
+// MoveFolder changes the parent of a folder. A nil parent moves it to the root.
+func (db *DB) MoveFolder(id uuid.UUID, parentID *uuid.UUID) error {
+	if parentID != nil {
+		if err := folderExists(db, *parentID); err != nil {
+			return err
+		}
+		descendants, err := db.GetFolderDescendantIDs(id)
+		if err != nil {
+			return err
+		}
+		for _, descendant := range descendants {
+			if descendant == *parentID {
+				return ErrFolderCycle
+			}
+		}
+	}
+
+	result, err := db.Exec(`UPDATE vaultinator.folders SET parent_id = $1 WHERE id = $2;`, parentID, id)
+	if err != nil {
+		return fmt.Errorf("failed to move folder: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no folder found with ID %s: %w", id, ErrNotFound)
+	}
+	log.Printf("Moved folder with ID: %s", id)
+	return nil
+}
+
+// DeleteFolder deletes a folder. Its subfolders and entries are moved up to the deleted folder's parent.
+func (db *DB) DeleteFolder(id uuid.UUID) error {
+	folder, err := db.GetFolder(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE vaultinator.folders SET parent_id = $1 WHERE parent_id = $2;`, folder.ParentID, id); err != nil {
+		return fmt.Errorf("failed to move subfolders: %v", err)
+	}
+	if _, err := tx.Exec(`UPDATE vaultinator.passwords SET folder_id = $1 WHERE folder_id = $2;`, folder.ParentID, id); err != nil {
+		return fmt.Errorf("failed to move entries: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM vaultinator.folders WHERE id = $1;`, id); err != nil {
+		return fmt.Errorf("failed to delete folder: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	log.Printf("Deleted folder with ID: %s", id)
+	return nil
+}
+
+// folderExists returns a wrapped ErrNotFound when no folder has the given ID, using q.
+func folderExists(q queryer, id uuid.UUID) error {
+	var exists bool
+	if err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM vaultinator.folders WHERE id = $1);`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to look up folder: %v", err)
+	}
+	if !exists {
+		return fmt.Errorf("no folder found with ID %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// GetFolderDescendantIDs returns the ID of a folder followed by the IDs of all its descendants.
+func (db *DB) GetFolderDescendantIDs(id uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+	WITH RECURSIVE tree AS (
+		SELECT id FROM vaultinator.folders WHERE id = $1
+		UNION ALL
+		SELECT f.id FROM vaultinator.folders f JOIN tree t ON f.parent_id = t.id
+	)
+	SELECT id FROM tree;`
+	rows, err := db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var folderID uuid.UUID
+		if err := rows.Scan(&folderID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, folderID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no folder found with ID %s: %w", id, ErrNotFound)
+	}
+	return ids, nil
+}
+
+// MovePassword files a password entry into a folder. A nil folder moves it to the root.
+func (db *DB) MovePassword(id uuid.UUID, folderID *uuid.UUID) error {
+	if err := movePassword(db, id, folderID); err != nil {
+		return err
+	}
+	log.Printf("Moved password entry with ID: %s", id)
+	return nil
+}
+
+// movePassword files a password entry into a folder using q.
+func movePassword(q queryer, id uuid.UUID, folderID *uuid.UUID) error {
+	if folderID != nil {
+		if err := folderExists(q, *folderID); err != nil {
+			return err
+		}
+	}
+
+	result, err := q.Exec(`UPDATE vaultinator.passwords SET folder_id = $1 WHERE id = $2;`, folderID, id)
+	if err != nil {
+		return fmt.Errorf("failed to move password: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no password entry found with ID %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
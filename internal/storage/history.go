@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/encryption"
+)
+
+// MaxPasswordHistory is the number of earlier passwords kept per entry
+const MaxPasswordHistory = 20
+
+// PasswordHistory is what a change to an entry replaced: its earlier password, empty when
+// the password did not change, and the earlier values of hidden custom fields that were
+// changed or removed. ChangedAt is when they were replaced.
+type PasswordHistory struct {
+	ID         uuid.UUID
+	PasswordID uuid.UUID
+	Password   string
+	Fields     []CustomField
+	ChangedAt  time.Time
+}
+
+// GetPasswordHistory returns the earlier passwords of an entry, most recently replaced first.
+func (db *DB) GetPasswordHistory(passwordID uuid.UUID) ([]PasswordHistory, error) {
+	return db.queryHistory(`
+	SELECT id, password_id, password, fields, changed_at FROM vaultinator.password_history
+	WHERE password_id = $1
+	ORDER BY changed_at DESC, id;`, passwordID)
+}
+
+// GetAllPasswordHistory returns the earlier passwords of every entry, most recently replaced first.
+func (db *DB) GetAllPasswordHistory() ([]PasswordHistory, error) {
+	return db.queryHistory(`
+	SELECT id, password_id, password, fields, changed_at FROM vaultinator.password_history
+	ORDER BY password_id, changed_at DESC, id;`)
+}
+
+// queryHistory runs a query selecting history rows and decrypts their passwords and fields.
+func (db *DB) queryHistory(query string, args ...interface{}) ([]PasswordHistory, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query password history: %v", err)
+	}
+	defer rows.Close()
+
+	history := []PasswordHistory{}
+	for rows.Next() {
+		var item PasswordHistory
+		var encryptedPassword, fields string
+		if err := rows.Scan(&item.ID, &item.PasswordID, &encryptedPassword, &fields, &item.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan password history: %v", err)
+		}
+		if encryptedPassword != "" {
+			if item.Password, err = db.encryptor.Decrypt(encryptedPassword); err != nil {
+				return nil, fmt.Errorf("failed to decrypt password history: %v", err)
+			}
+		}
+		if item.Fields, err = decodeCustomFields(db.encryptor, []byte(fields)); err != nil {
+			return nil, err
+		}
+		history = append(history, item)
+	}
+	return history, rows.Err()
+}
+
+// encryptHistory encrypts the password and fields of a history item with enc. An empty
+// password and no fields are stored as empty strings.
+func encryptHistory(enc *encryption.Encryptor, item PasswordHistory) (password, fields string, err error) {
+	if item.Password != "" {
+		if password, err = enc.Encrypt(item.Password); err != nil {
+			return "", "", fmt.Errorf("failed to encrypt password history: %v", err)
+		}
+	}
+	if len(item.Fields) > 0 {
+		if fields, err = encodeCustomFields(enc, item.Fields); err != nil {
+			return "", "", err
+		}
+	}
+	return password, fields, nil
+}
+
+// addPasswordHistory stores an earlier password using q and drops the oldest ones beyond
+// MaxPasswordHistory. A zero ChangedAt means now.
+func (db *DB) addPasswordHistory(q queryer, item PasswordHistory) error {
+	encryptedPassword, fields, err := encryptHistory(db.encryptor, item)
+	if err != nil {
+		return err
+	}
+	if item.ID == uuid.Nil {
+		item.ID = uuid.New()
+	}
+	if item.ChangedAt.IsZero() {
+		item.ChangedAt = time.Now()
+	}
+
+	query := `
+	INSERT INTO vaultinator.password_history (id, password_id, password, fields, changed_at)
+	VALUES ($1, $2, $3, $4, $5);`
+	if _, err := q.Exec(query, item.ID, item.PasswordID, encryptedPassword, fields, item.ChangedAt); err != nil {
+		return fmt.Errorf("failed to add password history: %v", err)
+	}
+
+	query = `
+	DELETE FROM vaultinator.password_history
+	WHERE password_id = $1 AND id NOT IN (
+		SELECT id FROM vaultinator.password_history
+		WHERE password_id = $1
+		ORDER BY changed_at DESC, id
+		LIMIT $2
+	);`
+	if _, err := q.Exec(query, item.PasswordID, MaxPasswordHistory); err != nil {
+		return fmt.Errorf("failed to prune password history: %v", err)
+	}
+	return nil
+}
+
+// recordPasswordChange keeps what an update is about to replace in the entry's history:
+// the current password when it changes to a different one, and hidden custom fields whose
+// values change or that are removed.
+func (db *DB) recordPasswordChange(q queryer, id uuid.UUID, newPassword string, newFields []CustomField) error {
+	var encryptedPassword string
+	var customFields []byte
+	err := q.QueryRow(`SELECT password, custom_fields FROM vaultinator.passwords WHERE id = $1;`, id).Scan(&encryptedPassword, &customFields)
+	if errors.Is(err, sql.ErrNoRows) {
+		// A missing entry is reported by the update itself
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get password: %v", err)
+	}
+	current, err := db.encryptor.Decrypt(encryptedPassword)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt password: %v", err)
+	}
+	currentFields, err := decodeCustomFields(db.encryptor, customFields)
+	if err != nil {
+		return err
+	}
+
+	item := PasswordHistory{PasswordID: id, Fields: ReplacedHiddenFields(currentFields, newFields)}
+	if current != "" && current != newPassword {
+		item.Password = current
+	}
+	if item.Password == "" && len(item.Fields) == 0 {
+		return nil
+	}
+	return db.addPasswordHistory(q, item)
+}
+
+// ReplacedHiddenFields returns the hidden fields of current with a value that next changes
+// or drops, matched by name.
+func ReplacedHiddenFields(current, next []CustomField) []CustomField {
+	var replaced []CustomField
+	for _, field := range current {
+		if field.Type != hiddenFieldType || field.Value == "" {
+			continue
+		}
+		kept := false
+		for _, other := range next {
+			if other.Name == field.Name && other.Type == hiddenFieldType && other.Value == field.Value {
+				kept = true
+				break
+			}
+		}
+		if !kept {
+			replaced = append(replaced, field)
+		}
+	}
+	return replaced
+}
+
+// rekeyHistory re-encrypts earlier passwords and fields with a new encryptor.
+func (db *DB) rekeyHistory(q queryer, newEncryptor *encryption.Encryptor) error {
+	history, err := db.GetAllPasswordHistory()
+	if err != nil {
+		return err
+	}
+	for _, item := range history {
+		encryptedPassword, fields, err := encryptHistory(newEncryptor, item)
+		if err != nil {
+			return err
+		}
+		if _, err := q.Exec(`UPDATE vaultinator.password_history SET password = $1, fields = $2 WHERE id = $3;`, encryptedPassword, fields, item.ID); err != nil {
+			return fmt.Errorf("failed to update password history: %v", err)
+		}
+	}
+	return nil
+}
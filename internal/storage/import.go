@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+)
+
+// ImportPasswords adds new folders and entries and updates existing entries in a single
+// transaction, so an import either lands completely or not at all. Folders are created in
+// order, so parents must come before their children. History is added for created entries.
+func (db *DB) ImportPasswords(folders []Folder, creates, updates []PasswordEntry, history []PasswordHistory) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for i := range folders {
+		if err := db.insertFolder(tx, &folders[i]); err != nil {
+			return fmt.Errorf("failed to add folder: %v", err)
+		}
+	}
+	for i := range creates {
+		if err := db.insertPassword(tx, &creates[i]); err != nil {
+			return fmt.Errorf("failed to add password: %v", err)
+		}
+	}
+	for _, entry := range updates {
+		if err := db.updatePassword(tx, entry); err != nil {
+			return err
+		}
+	}
+	for _, item := range history {
+		if err := db.addPasswordHistory(tx, item); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	log.Printf("Imported %d folders, %d new and %d updated password entries", len(folders), len(creates), len(updates))
+	return nil
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/encryption"
+)
+
+// encryptTOTP encrypts a one-time password seed; entries without one store an empty string.
+func encryptTOTP(enc *encryption.Encryptor, totp string) (string, error) {
+	if totp == "" {
+		return "", nil
+	}
+	encryptedTOTP, err := enc.Encrypt(totp)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt totp: %v", err)
+	}
+	return encryptedTOTP, nil
+}
+
+// decryptTOTP decrypts a one-time password seed stored by encryptTOTP.
+func decryptTOTP(enc *encryption.Encryptor, encryptedTOTP string) (string, error) {
+	if encryptedTOTP == "" {
+		return "", nil
+	}
+	totp, err := enc.Decrypt(encryptedTOTP)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt totp: %v", err)
+	}
+	return totp, nil
+}
+
+// hotpCounter converts an HOTP counter for the hotp_counter column, NULL when it is nil.
+func hotpCounter(counter *uint64) sql.NullInt64 {
+	if counter == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*counter), Valid: true}
+}
+
+// NextHOTPCounter returns the counter value for the next HOTP code of an entry and advances
+// the stored counter in the same statement, so concurrent requests never get the same
+// code. An entry without a stored counter starts from initial, the counter of its seed.
+func (db *DB) NextHOTPCounter(id uuid.UUID, initial uint64) (uint64, error) {
+	var counter int64
+	err := db.QueryRow(`
+	UPDATE vaultinator.passwords SET hotp_counter = COALESCE(hotp_counter, $1) + 1
+	WHERE id = $2
+	RETURNING hotp_counter - 1;`, int64(initial), id).Scan(&counter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("no password entry found with ID %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to advance hotp counter: %v", err)
+	}
+
+	log.Printf("Advanced hotp counter of password entry with ID: %s", id)
+	return uint64(counter), nil
+}
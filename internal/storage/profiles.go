@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/encryption"
+)
+
+// GeneratorProfile is a stored generator profile. Data is the JSON-encoded profile, which
+// is encrypted at rest.
+type GeneratorProfile struct {
+	ID        uuid.UUID
+	Data      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// scanGeneratorProfile scans a generator profile row and decrypts its data.
+func (db *DB) scanGeneratorProfile(row rowScanner) (GeneratorProfile, error) {
+	var profile GeneratorProfile
+	var encryptedData string
+	if err := row.Scan(&profile.ID, &encryptedData, &profile.CreatedAt, &profile.UpdatedAt); err != nil {
+		return GeneratorProfile{}, err
+	}
+	data, err := db.encryptor.Decrypt(encryptedData)
+	if err != nil {
+		return GeneratorProfile{}, fmt.Errorf("failed to decrypt generator profile: %v", err)
+	}
+	profile.Data = data
+	return profile, nil
+}
+
+// AddGeneratorProfile adds a new generator profile with a generated ID.
+func (db *DB) AddGeneratorProfile(data string) (GeneratorProfile, error) {
+	encryptedData, err := db.encryptor.Encrypt(data)
+	if err != nil {
+		return GeneratorProfile{}, fmt.Errorf("failed to encrypt generator profile: %v", err)
+	}
+
+	profile := GeneratorProfile{ID: uuid.New(), Data: data}
+	query := `
+	INSERT INTO vaultinator.generator_profiles (id, data)
+	VALUES ($1, $2)
+	RETURNING created_at, updated_at;`
+	if err := db.QueryRow(query, profile.ID, encryptedData).Scan(&profile.CreatedAt, &profile.UpdatedAt); err != nil {
+		return GeneratorProfile{}, fmt.Errorf("failed to add generator profile: %v", err)
+	}
+	log.Printf("Added generator profile with ID: %s", profile.ID)
+	return profile, nil
+}
+
+// GetGeneratorProfile retrieves a generator profile by its ID.
+func (db *DB) GetGeneratorProfile(id uuid.UUID) (GeneratorProfile, error) {
+	query := `SELECT id, data, created_at, updated_at FROM vaultinator.generator_profiles WHERE id = $1;`
+	profile, err := db.scanGeneratorProfile(db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return GeneratorProfile{}, fmt.Errorf("no generator profile found with ID %s: %w", id, ErrNotFound)
+	}
+	return profile, err
+}
+
+// GetGeneratorProfiles retrieves every generator profile ordered by creation time.
+func (db *DB) GetGeneratorProfiles() ([]GeneratorProfile, error) {
+	query := `SELECT id, data, created_at, updated_at FROM vaultinator.generator_profiles ORDER BY created_at;`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []GeneratorProfile
+	for rows.Next() {
+		profile, err := db.scanGeneratorProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// UpdateGeneratorProfile replaces the data of a generator profile.
+func (db *DB) UpdateGeneratorProfile(id uuid.UUID, data string) error {
+	encryptedData, err := db.encryptor.Encrypt(data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt generator profile: %v", err)
+	}
+
+	result, err := db.Exec(`UPDATE vaultinator.generator_profiles SET data = $1, updated_at = NOW() WHERE id = $2;`, encryptedData, id)
+	if err != nil {
+		return fmt.Errorf("failed to update generator profile: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no generator profile found with ID %s: %w", id, ErrNotFound)
+	}
+	log.Printf("Updated generator profile with ID: %s", id)
+	return nil
+}
+
+// DeleteGeneratorProfile deletes a generator profile.
+func (db *DB) DeleteGeneratorProfile(id uuid.UUID) error {
+	result, err := db.Exec(`DELETE FROM vaultinator.generator_profiles WHERE id = $1;`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete generator profile: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no generator profile found with ID %s: %w", id, ErrNotFound)
+	}
+	log.Printf("Deleted generator profile with ID: %s", id)
+	return nil
+}
+
+// rekeyGeneratorProfiles re-encrypts every generator profile with newEncryptor using q.
+func (db *DB) rekeyGeneratorProfiles(q queryer, newEncryptor *encryption.Encryptor) error {
+	profiles, err := db.GetGeneratorProfiles()
+	if err != nil {
+		return err
+	}
+	for _, profile := range profiles {
+		encryptedData, err := newEncryptor.Encrypt(profile.Data)
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt generator profile: %v", err)
+		}
+		if _, err := q.Exec(`UPDATE vaultinator.generator_profiles SET data = $1 WHERE id = $2;`, encryptedData, profile.ID); err != nil {
+			return fmt.Errorf("failed to update generator profile: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RestoreVault writes a restored backup in a single transaction. With replace, every
+// existing entry, including trashed ones, and every folder is deleted first. Folders and
+// entries keep their IDs and timestamps and overwrite existing rows with the same ID.
+// Folders are written in order, so parents must come before their children. It returns
+// the IDs of the attachments deleted by replace so the caller can remove their blobs.
+func (db *DB) RestoreVault(replace bool, folders []Folder, entries []PasswordEntry, history []PasswordHistory) ([]uuid.UUID, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var removed []uuid.UUID
+	if replace {
+		if removed, err = scanIDs(tx, `SELECT id FROM vaultinator.attachments;`); err != nil {
+			return nil, fmt.Errorf("failed to get attachments: %v", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM vaultinator.passwords;`); err != nil {
+			return nil, fmt.Errorf("failed to delete passwords: %v", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM vaultinator.folders;`); err != nil {
+			return nil, fmt.Errorf("failed to delete folders: %v", err)
+		}
+	}
+
+	for _, folder := range folders {
+		if err := db.upsertFolder(tx, folder); err != nil {
+			return nil, err
+		}
+	}
+	for _, entry := range entries {
+		if err := db.upsertPassword(tx, entry); err != nil {
+			return nil, err
+		}
+	}
+	for _, item := range history {
+		if err := db.addPasswordHistory(tx, item); err != nil {
+			return nil, err
+		}
+	}
+	if err := pruneTags(tx); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	log.Printf("Restored %d folders and %d password entries", len(folders), len(entries))
+	return removed, nil
+}
+
+// upsertFolder inserts a folder with its ID and creation time using q, or renames and
+// moves the folder that already has the ID and sets its rotation interval.
+func (db *DB) upsertFolder(q queryer, folder Folder) error {
+	encryptedName, err := db.encryptor.Encrypt(folder.Name)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt folder name: %v", err)
+	}
+	if folder.CreatedAt.IsZero() {
+		folder.CreatedAt = time.Now()
+	}
+
+	query := `
+	INSERT INTO vaultinator.folders (id, parent_id, name, created_at, rotation_days)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (id) DO UPDATE SET parent_id = EXCLUDED.parent_id, name = EXCLUDED.name, rotation_days = EXCLUDED.rotation_days;`
+	if _, err := q.Exec(query, folder.ID, folder.ParentID, encryptedName, folder.CreatedAt, folder.RotationDays); err != nil {
+		return fmt.Errorf("failed to restore folder: %v", err)
+	}
+	return nil
+}
+
+// upsertPassword writes a password entry with its ID, timestamps, flags and usage using q,
+// overwriting the entry that already has the ID, and replaces its tags. Unlike
+// updatePassword it does not record the replaced password; the caller decides.
+func (db *DB) upsertPassword(q queryer, entry PasswordEntry) error {
+	encryptedPassword, err := db.encryptor.Encrypt(entry.Password)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt password: %v", err)
+	}
+	customFields, err := encodeCustomFields(db.encryptor, entry.Fields)
+	if err != nil {
+		return err
+	}
+	encryptedData, err := encryptData(db.encryptor, entry.Data)
+	if err != nil {
+		return err
+	}
+	metadata, err := encryptMetadata(db.encryptor, entry)
+	if err != nil {
+		return err
+	}
+	encryptedTOTP, err := encryptTOTP(db.encryptor, entry.TOTP)
+	if err != nil {
+		return err
+	}
+	encryptedURIs, err := encryptURIs(db.encryptor, entry.URIs)
+	if err != nil {
+		return err
+	}
+	if entry.Type == "" {
+		entry.Type = DefaultEntryType
+	}
+	now := time.Now()
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = now
+	}
+	if entry.UpdatedAt.IsZero() {
+		entry.UpdatedAt = now
+	}
+
+	query := `
+	INSERT INTO vaultinator.passwords (id, entry_type, title, username, password, url, notes, folder_id, custom_fields, data, metadata_encrypted,
+		favorite, pinned, use_count, last_used_at, created_at, updated_at, deleted_at, totp, rotation_days, uris, hotp_counter)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, TRUE, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+	ON CONFLICT (id) DO UPDATE SET entry_type = EXCLUDED.entry_type, title = EXCLUDED.title, username = EXCLUDED.username,
+		password = EXCLUDED.password, url = EXCLUDED.url, notes = EXCLUDED.notes, folder_id = EXCLUDED.folder_id,
+		custom_fields = EXCLUDED.custom_fields, data = EXCLUDED.data, metadata_encrypted = TRUE, favorite = EXCLUDED.favorite,
+		pinned = EXCLUDED.pinned, use_count = EXCLUDED.use_count, last_used_at = EXCLUDED.last_used_at,
+		created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at, deleted_at = EXCLUDED.deleted_at, totp = EXCLUDED.totp,
+		rotation_days = EXCLUDED.rotation_days, uris = EXCLUDED.uris, hotp_counter = EXCLUDED.hotp_counter;`
+	if _, err := q.Exec(query, entry.ID, entry.Type, metadata.title, metadata.username, encryptedPassword, metadata.url, metadata.notes,
+		entry.FolderID, customFields, encryptedData, entry.Favorite, entry.Pinned, entry.UseCount, entry.LastUsedAt,
+		entry.CreatedAt, entry.UpdatedAt, entry.DeletedAt, encryptedTOTP, entry.RotationDays, encryptedURIs, hotpCounter(entry.HOTPCounter)); err != nil {
+		return fmt.Errorf("failed to restore password: %v", err)
+	}
+
+	return db.setPasswordTags(q, entry.ID, entry.Tags)
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SetFolderRotation sets the rotation interval inherited by entries in a folder; 0 removes it.
+func (db *DB) SetFolderRotation(id uuid.UUID, days int) error {
+	result, err := db.Exec(`UPDATE vaultinator.folders SET rotation_days = $1 WHERE id = $2;`, days, id)
+	if err != nil {
+		return fmt.Errorf("failed to set folder rotation: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no folder found with ID %s: %w", id, ErrNotFound)
+	}
+	log.Printf("Set rotation of folder with ID %s to %d days", id, days)
+	return nil
+}
+
+// GetPasswordChangeTimes returns when the password of each entry with history was last
+// replaced, without decrypting the history. Entries missing from the map never changed
+// their password; history that only replaced hidden fields stores no password and is
+// left out.
+func (db *DB) GetPasswordChangeTimes() (map[uuid.UUID]time.Time, error) {
+	rows, err := db.Query(`
+	SELECT password_id, MAX(changed_at) FROM vaultinator.password_history
+	WHERE password <> ''
+	GROUP BY password_id;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query password changes: %v", err)
+	}
+	defer rows.Close()
+
+	changes := map[uuid.UUID]time.Time{}
+	for rows.Next() {
+		var id uuid.UUID
+		var changedAt time.Time
+		if err := rows.Scan(&id, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan password changes: %v", err)
+		}
+		changes[id] = changedAt
+	}
+	return changes, rows.Err()
+}
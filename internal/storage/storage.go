@@ -2,8 +2,10 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
@@ -11,14 +13,161 @@ import (
 	"github.com/nonaxanon/vault-inator/internal/encryption"
 )
 
+// DefaultEntryType is the entry type stored when none is given.
+const DefaultEntryType = "login"
+
+// ErrNotFound is returned when a requested row does not exist.
+var ErrNotFound = errors.New("not found")
+
 // PasswordEntry represents a stored password entry.
 type PasswordEntry struct {
 	ID       uuid.UUID
+	Type     string
 	Title    string
 	Username string
 	Password string
 	URL      string
 	Notes    string
+	FolderID *uuid.UUID
+	Tags     []string
+	Fields   []CustomField
+	// Data holds the type-specific payload as JSON; it is encrypted at rest
+	Data string
+	// URIs is the ordered list of the entry's URLs, encrypted at rest. URL repeats the
+	// first one so the url column keeps the primary URL.
+	URIs []URI
+	// TOTP is the one-time password seed, an otpauth:// URI or base32 secret; it is
+	// encrypted at rest like the password
+	TOTP string
+	// HOTPCounter is the next HOTP counter value. It is kept apart from TOTP so writing the
+	// seed back cannot move it; nil takes the counter from the seed.
+	HOTPCounter *uint64
+	// RotationDays is how often the password must be changed; 0 inherits from the folders
+	RotationDays int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    *time.Time
+	// Favorite and Pinned are user flags; UseCount and LastUsedAt track reveals and copies
+	Favorite   bool
+	Pinned     bool
+	UseCount   int64
+	LastUsedAt *time.Time
+}
+
+// passwordColumns is the column list shared by every query that scans a PasswordEntry.
+const passwordColumns = `id, entry_type, title, username, password, url, notes, folder_id, custom_fields, data, created_at, updated_at, deleted_at, metadata_encrypted, favorite, pinned, use_count, last_used_at, totp, rotation_days, uris, hotp_counter`
+
+// migrations are applied in order by InitDB after the base tables exist.
+// Every statement must be idempotent.
+var migrations = []string{
+	// Trashed entries keep their row until purged
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;`,
+	`CREATE INDEX IF NOT EXISTS passwords_deleted_at_idx ON vaultinator.passwords (deleted_at);`,
+
+	// Hierarchical folders; names are encrypted like the other fields
+	`CREATE TABLE IF NOT EXISTS vaultinator.folders (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		parent_id UUID REFERENCES vaultinator.folders(id),
+		name TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE INDEX IF NOT EXISTS folders_parent_id_idx ON vaultinator.folders (parent_id);`,
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS folder_id UUID REFERENCES vaultinator.folders(id) ON DELETE SET NULL;`,
+	`CREATE INDEX IF NOT EXISTS passwords_folder_id_idx ON vaultinator.passwords (folder_id);`,
+
+	// Tags are encrypted; name_index is a blind index used for lookups and filtering
+	`CREATE TABLE IF NOT EXISTS vaultinator.tags (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		name TEXT NOT NULL,
+		name_index TEXT NOT NULL UNIQUE
+	);`,
+	`CREATE TABLE IF NOT EXISTS vaultinator.password_tags (
+		password_id UUID NOT NULL REFERENCES vaultinator.passwords(id) ON DELETE CASCADE,
+		tag_id UUID NOT NULL REFERENCES vaultinator.tags(id) ON DELETE CASCADE,
+		PRIMARY KEY (password_id, tag_id)
+	);`,
+	`CREATE INDEX IF NOT EXISTS password_tags_tag_id_idx ON vaultinator.password_tags (tag_id);`,
+
+	// Ordered custom fields; values of hidden fields are encrypted inside the JSON
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '[]';`,
+
+	// Entry kinds; data holds the encrypted type-specific payload
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS entry_type TEXT NOT NULL DEFAULT 'login';`,
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS data TEXT NOT NULL DEFAULT '';`,
+	`CREATE INDEX IF NOT EXISTS passwords_entry_type_idx ON vaultinator.passwords (entry_type);`,
+
+	// Attachment metadata; name, content type and the per-attachment key are encrypted.
+	// Blobs are only kept here when the database attachment store is used.
+	`CREATE TABLE IF NOT EXISTS vaultinator.attachments (
+		id UUID PRIMARY KEY,
+		password_id UUID NOT NULL REFERENCES vaultinator.passwords(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size BIGINT NOT NULL DEFAULT 0,
+		encryption_key TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE INDEX IF NOT EXISTS attachments_password_id_idx ON vaultinator.attachments (password_id);`,
+	`CREATE TABLE IF NOT EXISTS vaultinator.attachment_blobs (
+		attachment_id UUID PRIMARY KEY REFERENCES vaultinator.attachments(id) ON DELETE CASCADE,
+		data BYTEA NOT NULL
+	);`,
+
+	// Timestamps used for sorting and cursor pagination
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();`,
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();`,
+	// Title, username, URL and notes are encrypted at rest; rows written before this are flagged
+	// FALSE and encrypted by migrateMetadata
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS metadata_encrypted BOOLEAN NOT NULL DEFAULT FALSE;`,
+	// Favorites, pinning and usage counts
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS favorite BOOLEAN NOT NULL DEFAULT FALSE;`,
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;`,
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS use_count BIGINT NOT NULL DEFAULT 0;`,
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ;`,
+	// Earlier passwords of an entry, encrypted like the current one
+	`CREATE TABLE IF NOT EXISTS vaultinator.password_history (
+		id UUID PRIMARY KEY,
+		password_id UUID NOT NULL REFERENCES vaultinator.passwords(id) ON DELETE CASCADE,
+		password TEXT NOT NULL,
+		changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	`CREATE INDEX IF NOT EXISTS password_history_password_id_idx ON vaultinator.password_history (password_id, changed_at);`,
+	// Hidden custom fields replaced along with or instead of the password, encoded like
+	// custom_fields; empty when a change replaced none
+	`ALTER TABLE vaultinator.password_history ADD COLUMN IF NOT EXISTS fields TEXT NOT NULL DEFAULT '';`,
+	// Generator profiles; the name, settings and URL patterns are encrypted together
+	`CREATE TABLE IF NOT EXISTS vaultinator.generator_profiles (
+		id UUID PRIMARY KEY,
+		data TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	// One-time password seeds, encrypted like the password; empty when an entry has none
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS totp TEXT NOT NULL DEFAULT '';`,
+	// HOTP counters advanced by each code; NULL until the first code, which starts from the
+	// counter in the seed
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS hotp_counter BIGINT;`,
+	// Rotation intervals in days; 0 on an entry inherits from its folders, 0 on a folder sets none
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS rotation_days INTEGER NOT NULL DEFAULT 0;`,
+	`ALTER TABLE vaultinator.folders ADD COLUMN IF NOT EXISTS rotation_days INTEGER NOT NULL DEFAULT 0;`,
+	// How the URL is matched against pages; empty uses the default mode
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS url_match TEXT NOT NULL DEFAULT '';`,
+	// Equivalent domain groups; the domains are encrypted like URLs
+	`CREATE TABLE IF NOT EXISTS vaultinator.equivalent_domains (
+		id UUID PRIMARY KEY,
+		domains TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);`,
+	// Ordered URIs with their match modes, encrypted as one list; url_match is superseded
+	// and rows written before this are filled in by migrateURIs
+	`ALTER TABLE vaultinator.passwords ADD COLUMN IF NOT EXISTS uris TEXT NOT NULL DEFAULT '';`,
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx so helpers can run inside or outside a transaction.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 // DB holds the database connection and encryption.
@@ -45,6 +194,13 @@ func NewDB(connStr string, masterPassword string) (*DB, error) {
 	return &DB{db, encryptor}, nil
 }
 
+// PasswordIndex returns a keyed hash of a password that is equal for equal passwords, so
+// reused passwords can be found without comparing or revealing them. It changes when the
+// master password does.
+func (db *DB) PasswordIndex(password string) string {
+	return db.encryptor.BlindIndex("password reuse\x00" + password)
+}
+
 // InitDB initializes the database by creating the vaultinator schema and the passwords table if they don't exist.
 func (db *DB) InitDB() error {
 	// Create the vaultinator schema if it doesn't exist
@@ -79,39 +235,229 @@ func (db *DB) InitDB() error {
 		notes TEXT
 	);`
 	_, err = db.Exec(createTableQuery)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Apply schema migrations
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil {
+			return fmt.Errorf("failed to apply migration: %v", err)
+		}
+	}
+
+	// Encrypt the metadata of rows written before it was encrypted at rest, then move
+	// single URLs into URI lists; both need the encryptor
+	if err := db.migrateMetadata(); err != nil {
+		return err
+	}
+	return db.migrateURIs()
 }
 
 // AddPassword adds a new password entry to the database.
+// A new ID is generated unless the entry already carries one.
 func (db *DB) AddPassword(entry PasswordEntry) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := db.insertPassword(tx, &entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	log.Printf("Added password entry with ID: %s", entry.ID)
+	return nil
+}
+
+// insertPassword inserts a password entry and its tags using q.
+func (db *DB) insertPassword(q queryer, entry *PasswordEntry) error {
 	// Encrypt the password before storing
 	encryptedPassword, err := db.encryptor.Encrypt(entry.Password)
 	if err != nil {
 		return fmt.Errorf("failed to encrypt password: %v", err)
 	}
+	customFields, err := encodeCustomFields(db.encryptor, entry.Fields)
+	if err != nil {
+		return err
+	}
+	encryptedData, err := encryptData(db.encryptor, entry.Data)
+	if err != nil {
+		return err
+	}
+	metadata, err := encryptMetadata(db.encryptor, *entry)
+	if err != nil {
+		return err
+	}
+	encryptedTOTP, err := encryptTOTP(db.encryptor, entry.TOTP)
+	if err != nil {
+		return err
+	}
+	encryptedURIs, err := encryptURIs(db.encryptor, entry.URIs)
+	if err != nil {
+		return err
+	}
 
 	query := `
-	INSERT INTO vaultinator.passwords (id, title, username, password, url, notes)
-	VALUES ($1, $2, $3, $4, $5, $6)
-	RETURNING id;`
-	var id uuid.UUID
-	err = db.QueryRow(query, uuid.New(), entry.Title, entry.Username, encryptedPassword, entry.URL, entry.Notes).Scan(&id)
+	INSERT INTO vaultinator.passwords (id, entry_type, title, username, password, url, notes, folder_id, custom_fields, data, metadata_encrypted, favorite, pinned, totp, rotation_days, uris, hotp_counter)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, TRUE, $11, $12, $13, $14, $15, $16);`
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	if entry.Type == "" {
+		entry.Type = DefaultEntryType
+	}
+	if _, err := q.Exec(query, entry.ID, entry.Type, metadata.title, metadata.username, encryptedPassword, metadata.url, metadata.notes, entry.FolderID, customFields, encryptedData, entry.Favorite, entry.Pinned, encryptedTOTP, entry.RotationDays, encryptedURIs, hotpCounter(entry.HOTPCounter)); err != nil {
+		return err
+	}
+
+	return db.setPasswordTags(q, entry.ID, entry.Tags)
+}
+
+// entryMetadata holds the encrypted title, username, URL and notes of an entry.
+type entryMetadata struct {
+	title, username, url, notes string
+}
+
+// encryptMetadata encrypts the descriptive fields of an entry with enc.
+func encryptMetadata(enc *encryption.Encryptor, entry PasswordEntry) (entryMetadata, error) {
+	var metadata entryMetadata
+	var err error
+	if metadata.title, err = enc.Encrypt(entry.Title); err != nil {
+		return entryMetadata{}, fmt.Errorf("failed to encrypt title: %v", err)
+	}
+	if metadata.username, err = enc.Encrypt(entry.Username); err != nil {
+		return entryMetadata{}, fmt.Errorf("failed to encrypt username: %v", err)
+	}
+	if metadata.url, err = enc.Encrypt(entry.URL); err != nil {
+		return entryMetadata{}, fmt.Errorf("failed to encrypt url: %v", err)
+	}
+	if metadata.notes, err = enc.Encrypt(entry.Notes); err != nil {
+		return entryMetadata{}, fmt.Errorf("failed to encrypt notes: %v", err)
+	}
+	return metadata, nil
+}
+
+// decryptMetadata decrypts the descriptive fields of an entry in place.
+func decryptMetadata(enc *encryption.Encryptor, entry *PasswordEntry) error {
+	var err error
+	if entry.Title, err = enc.Decrypt(entry.Title); err != nil {
+		return fmt.Errorf("failed to decrypt title: %v", err)
+	}
+	if entry.Username, err = enc.Decrypt(entry.Username); err != nil {
+		return fmt.Errorf("failed to decrypt username: %v", err)
+	}
+	if entry.URL, err = enc.Decrypt(entry.URL); err != nil {
+		return fmt.Errorf("failed to decrypt url: %v", err)
+	}
+	if entry.Notes, err = enc.Decrypt(entry.Notes); err != nil {
+		return fmt.Errorf("failed to decrypt notes: %v", err)
+	}
+	return nil
+}
+
+// migrateMetadata encrypts the title, username, URL and notes of entries written before
+// metadata was encrypted at rest.
+func (db *DB) migrateMetadata() error {
+	rows, err := db.Query(`SELECT id, title, username, url, notes FROM vaultinator.passwords WHERE metadata_encrypted = FALSE;`)
 	if err != nil {
+		return fmt.Errorf("failed to get passwords: %v", err)
+	}
+	defer rows.Close()
+
+	var plain []PasswordEntry
+	for rows.Next() {
+		var entry PasswordEntry
+		var url, notes sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.Title, &entry.Username, &url, &notes); err != nil {
+			return err
+		}
+		entry.URL = url.String
+		entry.Notes = notes.String
+		plain = append(plain, entry)
+	}
+	if err := rows.Err(); err != nil {
 		return err
 	}
-	log.Printf("Added password entry with ID: %s", id)
+	rows.Close()
+	if len(plain) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, entry := range plain {
+		metadata, err := encryptMetadata(db.encryptor, entry)
+		if err != nil {
+			return err
+		}
+		query := `
+		UPDATE vaultinator.passwords
+		SET title = $1, username = $2, url = $3, notes = $4, metadata_encrypted = TRUE
+		WHERE id = $5 AND metadata_encrypted = FALSE;`
+		if _, err := tx.Exec(query, metadata.title, metadata.username, metadata.url, metadata.notes, entry.ID); err != nil {
+			return fmt.Errorf("failed to encrypt entry metadata: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	log.Printf("Encrypted metadata of %d password entries", len(plain))
 	return nil
 }
 
-// GetPassword retrieves a password entry by its ID.
-func (db *DB) GetPassword(id uuid.UUID) (PasswordEntry, error) {
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPassword scans a row selected with passwordColumns and decrypts the password.
+func (db *DB) scanPassword(row rowScanner) (PasswordEntry, error) {
 	var entry PasswordEntry
 	var encryptedPassword string
-	query := `SELECT id, title, username, password, url, notes FROM vaultinator.passwords WHERE id = $1;`
-	err := db.QueryRow(query, id).Scan(&entry.ID, &entry.Title, &entry.Username, &encryptedPassword, &entry.URL, &entry.Notes)
-	if err != nil {
+	var url, notes sql.NullString
+	var folderID uuid.NullUUID
+	var customFields []byte
+	var encryptedData string
+	var deletedAt sql.NullTime
+	var metadataEncrypted bool
+	var lastUsedAt sql.NullTime
+	var encryptedTOTP string
+	var encryptedURIs string
+	var hotpCounter sql.NullInt64
+	if err := row.Scan(&entry.ID, &entry.Type, &entry.Title, &entry.Username, &encryptedPassword, &url, &notes, &folderID, &customFields, &encryptedData, &entry.CreatedAt, &entry.UpdatedAt, &deletedAt, &metadataEncrypted,
+		&entry.Favorite, &entry.Pinned, &entry.UseCount, &lastUsedAt, &encryptedTOTP, &entry.RotationDays, &encryptedURIs, &hotpCounter); err != nil {
 		return PasswordEntry{}, err
 	}
+	if hotpCounter.Valid {
+		counter := uint64(hotpCounter.Int64)
+		entry.HOTPCounter = &counter
+	}
+	if lastUsedAt.Valid {
+		entry.LastUsedAt = &lastUsedAt.Time
+	}
+	entry.URL = url.String
+	entry.Notes = notes.String
+	if metadataEncrypted {
+		if err := decryptMetadata(db.encryptor, &entry); err != nil {
+			return PasswordEntry{}, err
+		}
+	}
+	if folderID.Valid {
+		entry.FolderID = &folderID.UUID
+	}
+	if deletedAt.Valid {
+		entry.DeletedAt = &deletedAt.Time
+	}
 
 	// Decrypt the password
 	decryptedPassword, err := db.encryptor.Decrypt(encryptedPassword)
@@ -120,13 +466,31 @@ func (db *DB) GetPassword(id uuid.UUID) (PasswordEntry, error) {
 	}
 	entry.Password = decryptedPassword
 
+	fields, err := decodeCustomFields(db.encryptor, customFields)
+	if err != nil {
+		return PasswordEntry{}, err
+	}
+	entry.Fields = fields
+
+	data, err := decryptData(db.encryptor, encryptedData)
+	if err != nil {
+		return PasswordEntry{}, err
+	}
+	entry.Data = data
+
+	if entry.TOTP, err = decryptTOTP(db.encryptor, encryptedTOTP); err != nil {
+		return PasswordEntry{}, err
+	}
+	if entry.URIs, err = decryptURIs(db.encryptor, encryptedURIs); err != nil {
+		return PasswordEntry{}, err
+	}
+
 	return entry, nil
 }
 
-// GetAllPasswords retrieves all password entries from the database.
-func (db *DB) GetAllPasswords() ([]PasswordEntry, error) {
-	query := `SELECT id, title, username, password, url, notes FROM vaultinator.passwords;`
-	rows, err := db.Query(query)
+// queryPasswords runs a query selecting passwordColumns and returns the decrypted entries.
+func (db *DB) queryPasswords(query string, args ...interface{}) ([]PasswordEntry, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -134,31 +498,60 @@ func (db *DB) GetAllPasswords() ([]PasswordEntry, error) {
 
 	var entries []PasswordEntry
 	for rows.Next() {
-		var entry PasswordEntry
-		var encryptedPassword string
-		if err := rows.Scan(&entry.ID, &entry.Title, &entry.Username, &encryptedPassword, &entry.URL, &entry.Notes); err != nil {
-			return nil, err
-		}
-
-		// Decrypt the password
-		decryptedPassword, err := db.encryptor.Decrypt(encryptedPassword)
+		entry, err := db.scanPassword(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt password: %v", err)
+			return nil, err
 		}
-		entry.Password = decryptedPassword
-
 		entries = append(entries, entry)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	rows.Close()
+
+	if err := db.loadTags(entries); err != nil {
+		return nil, err
+	}
 	return entries, nil
 }
 
-// DeletePassword deletes a password entry by its ID.
+// GetPassword retrieves a password entry by its ID, including trashed entries.
+func (db *DB) GetPassword(id uuid.UUID) (PasswordEntry, error) {
+	query := `SELECT ` + passwordColumns + ` FROM vaultinator.passwords WHERE id = $1;`
+	entry, err := db.scanPassword(db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return PasswordEntry{}, fmt.Errorf("no password entry found with ID %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return PasswordEntry{}, err
+	}
+
+	entries := []PasswordEntry{entry}
+	if err := db.loadTags(entries); err != nil {
+		return PasswordEntry{}, err
+	}
+	return entries[0], nil
+}
+
+// GetAllPasswords retrieves all password entries that are not in the trash.
+func (db *DB) GetAllPasswords() ([]PasswordEntry, error) {
+	query := `SELECT ` + passwordColumns + ` FROM vaultinator.passwords WHERE deleted_at IS NULL;`
+	return db.queryPasswords(query)
+}
+
+// DeletePassword moves a password entry to the trash by stamping its deleted_at column.
 func (db *DB) DeletePassword(id uuid.UUID) error {
-	query := `DELETE FROM vaultinator.passwords WHERE id = $1;`
-	result, err := db.Exec(query, id)
+	if err := trashPassword(db, id); err != nil {
+		return err
+	}
+	log.Printf("Moved password entry with ID %s to trash", id)
+	return nil
+}
+
+// trashPassword moves a non-trashed password entry to the trash using q.
+func trashPassword(q queryer, id uuid.UUID) error {
+	query := `UPDATE vaultinator.passwords SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL;`
+	result, err := q.Exec(query, id)
 	if err != nil {
 		return err
 	}
@@ -167,9 +560,8 @@ func (db *DB) DeletePassword(id uuid.UUID) error {
 		return err
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("no password entry found with ID: %s", id)
+		return fmt.Errorf("no password entry found with ID %s: %w", id, ErrNotFound)
 	}
-	log.Printf("Deleted password entry with ID: %s", id)
 	return nil
 }
 
@@ -180,7 +572,8 @@ func (db *DB) UpdateMasterPassword(currentPassword, newPassword string) error {
 		return fmt.Errorf("failed to create new encryptor: %v", err)
 	}
 
-	entries, err := db.GetAllPasswords()
+	// Trashed entries must be re-encrypted as well so they can still be restored
+	entries, err := db.queryPasswords(`SELECT ` + passwordColumns + ` FROM vaultinator.passwords;`)
 	if err != nil {
 		return fmt.Errorf("failed to get passwords: %v", err)
 	}
@@ -199,13 +592,82 @@ func (db *DB) UpdateMasterPassword(currentPassword, newPassword string) error {
 			return fmt.Errorf("failed to re-encrypt password: %v", err)
 		}
 
+		// Re-encrypt hidden custom fields
+		customFields, err := encodeCustomFields(newEncryptor, entry.Fields)
+		if err != nil {
+			return err
+		}
+
+		// Re-encrypt the type-specific payload
+		encryptedData, err := encryptData(newEncryptor, entry.Data)
+		if err != nil {
+			return err
+		}
+
+		// Re-encrypt the title, username, URL and notes, encrypting legacy plaintext rows too
+		metadata, err := encryptMetadata(newEncryptor, entry)
+		if err != nil {
+			return err
+		}
+
+		// Re-encrypt the one-time password seed and the URIs
+		encryptedTOTP, err := encryptTOTP(newEncryptor, entry.TOTP)
+		if err != nil {
+			return err
+		}
+		encryptedURIs, err := encryptURIs(newEncryptor, entry.URIs)
+		if err != nil {
+			return err
+		}
+
 		// Update the password in the database
-		query := `UPDATE vaultinator.passwords SET password = $1 WHERE id = $2;`
-		if _, err := tx.Exec(query, encryptedPassword, entry.ID); err != nil {
+		query := `
+		UPDATE vaultinator.passwords
+		SET password = $1, custom_fields = $2, data = $3, title = $4, username = $5, url = $6, notes = $7, metadata_encrypted = TRUE, totp = $8, uris = $9
+		WHERE id = $10;`
+		if _, err := tx.Exec(query, encryptedPassword, customFields, encryptedData, metadata.title, metadata.username, metadata.url, metadata.notes, encryptedTOTP, encryptedURIs, entry.ID); err != nil {
 			return fmt.Errorf("failed to update password: %v", err)
 		}
 	}
 
+	// Re-encrypt folder names with the new master password
+	folders, err := db.GetAllFolders()
+	if err != nil {
+		return fmt.Errorf("failed to get folders: %v", err)
+	}
+	for _, folder := range folders {
+		encryptedName, err := newEncryptor.Encrypt(folder.Name)
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt folder name: %v", err)
+		}
+		if _, err := tx.Exec(`UPDATE vaultinator.folders SET name = $1 WHERE id = $2;`, encryptedName, folder.ID); err != nil {
+			return fmt.Errorf("failed to update folder: %v", err)
+		}
+	}
+
+	// Re-encrypt tag names and recompute their blind indexes with the new key
+	if err := db.rekeyTags(tx, newEncryptor); err != nil {
+		return err
+	}
+
+	// Re-wrap attachment keys with the new master password
+	if err := db.rekeyAttachments(tx, newEncryptor); err != nil {
+		return err
+	}
+
+	// Re-encrypt earlier passwords with the new master password
+	if err := db.rekeyHistory(tx, newEncryptor); err != nil {
+		return err
+	}
+
+	// Re-encrypt generator profiles and equivalent domains with the new master password
+	if err := db.rekeyGeneratorProfiles(tx, newEncryptor); err != nil {
+		return err
+	}
+	if err := db.rekeyEquivalentDomains(tx, newEncryptor); err != nil {
+		return err
+	}
+
 	// Update the master password in the configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -228,20 +690,68 @@ func (db *DB) UpdateMasterPassword(currentPassword, newPassword string) error {
 	return nil
 }
 
-// UpdatePassword updates an existing password entry in the database.
+// UpdatePassword updates an existing password entry in the database, replacing its tags.
 func (db *DB) UpdatePassword(entry PasswordEntry) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := db.updatePassword(tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Updated password entry with ID: %s", entry.ID)
+	return nil
+}
+
+// updatePassword updates a password entry and replaces its tags using q. A replaced
+// password and replaced hidden fields are kept in the entry's history.
+func (db *DB) updatePassword(q queryer, entry PasswordEntry) error {
+	if err := db.recordPasswordChange(q, entry.ID, entry.Password, entry.Fields); err != nil {
+		return err
+	}
+
 	// Encrypt the password before storing
 	encryptedPassword, err := db.encryptor.Encrypt(entry.Password)
 	if err != nil {
 		return fmt.Errorf("failed to encrypt password: %v", err)
 	}
+	customFields, err := encodeCustomFields(db.encryptor, entry.Fields)
+	if err != nil {
+		return err
+	}
+	encryptedData, err := encryptData(db.encryptor, entry.Data)
+	if err != nil {
+		return err
+	}
+	metadata, err := encryptMetadata(db.encryptor, entry)
+	if err != nil {
+		return err
+	}
+	encryptedTOTP, err := encryptTOTP(db.encryptor, entry.TOTP)
+	if err != nil {
+		return err
+	}
+	encryptedURIs, err := encryptURIs(db.encryptor, entry.URIs)
+	if err != nil {
+		return err
+	}
+	if entry.Type == "" {
+		entry.Type = DefaultEntryType
+	}
 
 	query := `
 	UPDATE vaultinator.passwords 
-	SET title = $1, username = $2, password = $3, url = $4, notes = $5
-	WHERE id = $6;`
+	SET entry_type = $1, title = $2, username = $3, password = $4, url = $5, notes = $6, folder_id = $7, custom_fields = $8, data = $9, metadata_encrypted = TRUE, totp = $10, rotation_days = $11, uris = $12, hotp_counter = $13, updated_at = NOW()
+	WHERE id = $14;`
 
-	result, err := db.Exec(query, entry.Title, entry.Username, encryptedPassword, entry.URL, entry.Notes, entry.ID)
+	result, err := q.Exec(query, entry.Type, metadata.title, metadata.username, encryptedPassword, metadata.url, metadata.notes, entry.FolderID, customFields, encryptedData, encryptedTOTP, entry.RotationDays, encryptedURIs, hotpCounter(entry.HOTPCounter), entry.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update password: %v", err)
 	}
@@ -252,9 +762,8 @@ func (db *DB) UpdatePassword(entry PasswordEntry) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("no password entry found with ID: %s", entry.ID)
+		return fmt.Errorf("no password entry found with ID %s: %w", entry.ID, ErrNotFound)
 	}
 
-	log.Printf("Updated password entry with ID: %s", entry.ID)
-	return nil
+	return db.setPasswordTags(q, entry.ID, entry.Tags)
 }
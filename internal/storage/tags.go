@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/nonaxanon/vault-inator/internal/encryption"
+)
+
+// TagCount is a tag name with the number of non-trashed entries carrying it.
+type TagCount struct {
+	Name  string
+	Count int
+}
+
+// NormalizeTag trims a tag name. Blind indexes are computed over the lower-cased form
+// so tags that differ only in case are treated as the same tag.
+func NormalizeTag(name string) string {
+	return strings.TrimSpace(name)
+}
+
+// tagIndex returns the blind index of a tag name.
+func (db *DB) tagIndex(name string) string {
+	return db.encryptor.BlindIndex(strings.ToLower(NormalizeTag(name)))
+}
+
+// uuidStrings converts IDs to strings for use with pq.Array.
+func uuidStrings(ids []uuid.UUID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
+
+// ensureTag returns the ID of the tag with the given name, creating it if needed.
+// An existing tag keeps its original spelling.
+func (db *DB) ensureTag(q queryer, name string) (uuid.UUID, error) {
+	encryptedName, err := db.encryptor.Encrypt(NormalizeTag(name))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to encrypt tag name: %v", err)
+	}
+	query := `
+	INSERT INTO vaultinator.tags (id, name, name_index)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (name_index) DO UPDATE SET name_index = EXCLUDED.name_index
+	RETURNING id;`
+	var id uuid.UUID
+	if err := q.QueryRow(query, uuid.New(), encryptedName, db.tagIndex(name)).Scan(&id); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to add tag: %v", err)
+	}
+	return id, nil
+}
+
+// setPasswordTags replaces the tags of a password entry and prunes tags no longer in use.
+func (db *DB) setPasswordTags(q queryer, id uuid.UUID, names []string) error {
+	if _, err := q.Exec(`DELETE FROM vaultinator.password_tags WHERE password_id = $1;`, id); err != nil {
+		return fmt.Errorf("failed to clear tags: %v", err)
+	}
+	if err := db.addPasswordTags(q, id, names); err != nil {
+		return err
+	}
+	return pruneTags(q)
+}
+
+// SetPasswordTags replaces the tags of a password entry.
+func (db *DB) SetPasswordTags(id uuid.UUID, names []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := requirePassword(tx, id); err != nil {
+		return err
+	}
+	if err := db.setPasswordTags(tx, id, names); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	log.Printf("Updated tags of password entry with ID: %s", id)
+	return nil
+}
+
+// addPasswordTags links tags to a password entry, ignoring tags it already carries.
+func (db *DB) addPasswordTags(q queryer, id uuid.UUID, names []string) error {
+	for _, name := range names {
+		if NormalizeTag(name) == "" {
+			continue
+		}
+		tagID, err := db.ensureTag(q, name)
+		if err != nil {
+			return err
+		}
+		query := `INSERT INTO vaultinator.password_tags (password_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;`
+		if _, err := q.Exec(query, id, tagID); err != nil {
+			return fmt.Errorf("failed to tag password: %v", err)
+		}
+	}
+	return nil
+}
+
+// removePasswordTags unlinks tags from a password entry, ignoring tags it does not carry.
+// The caller prunes tags that are no longer in use.
+func (db *DB) removePasswordTags(q queryer, id uuid.UUID, names []string) error {
+	indexes := make([]string, 0, len(names))
+	for _, name := range names {
+		if NormalizeTag(name) != "" {
+			indexes = append(indexes, db.tagIndex(name))
+		}
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+	query := `
+	DELETE FROM vaultinator.password_tags pt
+	USING vaultinator.tags t
+	WHERE pt.tag_id = t.id AND pt.password_id = $1 AND t.name_index = ANY($2);`
+	if _, err := q.Exec(query, id, pq.Array(indexes)); err != nil {
+		return fmt.Errorf("failed to untag password: %v", err)
+	}
+	return nil
+}
+
+// requirePassword returns ErrNotFound unless a password entry exists, trashed or not.
+func requirePassword(q queryer, id uuid.UUID) error {
+	var exists bool
+	if err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM vaultinator.passwords WHERE id = $1);`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("no password entry found with ID %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// pruneTags deletes tags that are no longer linked to any entry.
+func pruneTags(q queryer) error {
+	query := `
+	DELETE FROM vaultinator.tags t
+	WHERE NOT EXISTS (SELECT 1 FROM vaultinator.password_tags pt WHERE pt.tag_id = t.id);`
+	if _, err := q.Exec(query); err != nil {
+		return fmt.Errorf("failed to prune tags: %v", err)
+	}
+	return nil
+}
+
+// loadTags fills in the Tags of each entry.
+func (db *DB) loadTags(entries []PasswordEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(entries))
+	positions := make(map[uuid.UUID]int, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+		positions[entry.ID] = i
+	}
+
+	query := `
+	SELECT pt.password_id, t.name
+	FROM vaultinator.password_tags pt
+	JOIN vaultinator.tags t ON t.id = pt.tag_id
+	WHERE pt.password_id = ANY($1::uuid[]);`
+	rows, err := db.Query(query, pq.Array(uuidStrings(ids)))
+	if err != nil {
+		return fmt.Errorf("failed to load tags: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var encryptedName string
+		if err := rows.Scan(&id, &encryptedName); err != nil {
+			return err
+		}
+		name, err := db.encryptor.Decrypt(encryptedName)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt tag name: %v", err)
+		}
+		i := positions[id]
+		entries[i].Tags = append(entries[i].Tags, name)
+	}
+	return rows.Err()
+}
+
+// GetTagCounts returns every tag with the number of non-trashed entries carrying it.
+func (db *DB) GetTagCounts() ([]TagCount, error) {
+	query := `
+	SELECT t.name, COUNT(p.id)
+	FROM vaultinator.tags t
+	LEFT JOIN vaultinator.password_tags pt ON pt.tag_id = t.id
+	LEFT JOIN vaultinator.passwords p ON p.id = pt.password_id AND p.deleted_at IS NULL
+	GROUP BY t.id, t.name;`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []TagCount
+	for rows.Next() {
+		var encryptedName string
+		var count int
+		if err := rows.Scan(&encryptedName, &count); err != nil {
+			return nil, err
+		}
+		name, err := db.encryptor.Decrypt(encryptedName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt tag name: %v", err)
+		}
+		counts = append(counts, TagCount{Name: name, Count: count})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// rekeyTags re-encrypts tag names and recomputes their blind indexes with a new encryptor.
+func (db *DB) rekeyTags(q queryer, newEncryptor *encryption.Encryptor) error {
+	rows, err := db.Query(`SELECT id, name FROM vaultinator.tags;`)
+	if err != nil {
+		return fmt.Errorf("failed to get tags: %v", err)
+	}
+	defer rows.Close()
+
+	names := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var id uuid.UUID
+		var encryptedName string
+		if err := rows.Scan(&id, &encryptedName); err != nil {
+			return err
+		}
+		name, err := db.encryptor.Decrypt(encryptedName)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt tag name: %v", err)
+		}
+		names[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for id, name := range names {
+		encryptedName, err := newEncryptor.Encrypt(name)
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt tag name: %v", err)
+		}
+		index := newEncryptor.BlindIndex(strings.ToLower(name))
+		query := `UPDATE vaultinator.tags SET name = $1, name_index = $2 WHERE id = $3;`
+		if _, err := q.Exec(query, encryptedName, index, id); err != nil {
+			return fmt.Errorf("failed to update tag: %v", err)
+		}
+	}
+	return nil
+}
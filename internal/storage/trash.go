@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GetTrashedPasswords retrieves all password entries currently in the trash, most recently deleted first.
+func (db *DB) GetTrashedPasswords() ([]PasswordEntry, error) {
+	query := `SELECT ` + passwordColumns + ` FROM vaultinator.passwords WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC;`
+	return db.queryPasswords(query)
+}
+
+// RestorePassword moves a trashed password entry back into the vault.
+func (db *DB) RestorePassword(id uuid.UUID) error {
+	if err := restorePassword(db, id); err != nil {
+		return err
+	}
+	log.Printf("Restored password entry with ID: %s", id)
+	return nil
+}
+
+// restorePassword moves a trashed password entry back into the vault using q.
+func restorePassword(q queryer, id uuid.UUID) error {
+	query := `UPDATE vaultinator.passwords SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL;`
+	result, err := q.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no trashed password entry found with ID %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// PurgePassword permanently deletes a trashed password entry and its attachment metadata.
+// Entries that are not in the trash are left untouched. It returns the IDs of the deleted
+// attachments so the caller can remove their blobs.
+func (db *DB) PurgePassword(id uuid.UUID) ([]uuid.UUID, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	attachmentIDs, err := purgePassword(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := pruneTags(tx); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	log.Printf("Purged password entry with ID: %s", id)
+	return attachmentIDs, nil
+}
+
+// purgePassword permanently deletes a trashed password entry using q and returns the IDs
+// of its deleted attachments. The caller prunes unused tags.
+func purgePassword(q queryer, id uuid.UUID) ([]uuid.UUID, error) {
+	attachmentIDs, err := scanIDs(q, `SELECT id FROM vaultinator.attachments WHERE password_id = $1;`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `DELETE FROM vaultinator.passwords WHERE id = $1 AND deleted_at IS NOT NULL;`
+	result, err := q.Exec(query, id)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("no trashed password entry found with ID %s: %w", id, ErrNotFound)
+	}
+	return attachmentIDs, nil
+}
+
+// PurgeTrash permanently deletes every trashed entry that was deleted before the given time.
+// It returns the number of entries removed and the IDs of their deleted attachments.
+func (db *DB) PurgeTrash(before time.Time) (int64, []uuid.UUID, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	attachmentQuery := `
+	SELECT a.id FROM vaultinator.attachments a
+	JOIN vaultinator.passwords p ON p.id = a.password_id
+	WHERE p.deleted_at IS NOT NULL AND p.deleted_at < $1;`
+	attachmentIDs, err := scanIDs(tx, attachmentQuery, before)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	query := `DELETE FROM vaultinator.passwords WHERE deleted_at IS NOT NULL AND deleted_at < $1;`
+	result, err := tx.Exec(query, before)
+	if err != nil {
+		return 0, nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := pruneTags(tx); err != nil {
+		return 0, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	if rowsAffected > 0 {
+		log.Printf("Purged %d password entries from trash", rowsAffected)
+	}
+	return rowsAffected, attachmentIDs, nil
+}
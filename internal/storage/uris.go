@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/nonaxanon/vault-inator/internal/encryption"
+)
+
+// URI is one of the URLs of an entry and the mode it is matched with; an empty mode uses
+// the default.
+type URI struct {
+	URL   string `json:"url"`
+	Match string `json:"match,omitempty"`
+}
+
+// secureNoteType is the entry type whose imported URL fields are left in place.
+const secureNoteType = "secure_note"
+
+// importedURLField matches the names of the URL custom fields importers created for
+// websites after the first, before entries carried a list of URIs.
+var importedURLField = regexp.MustCompile(`^URL \d+$`)
+
+// encryptURIs serializes the URIs of an entry to JSON and encrypts them for the uris
+// column. An empty list is encrypted too, so that only rows written before URI lists keep
+// an empty column.
+func encryptURIs(enc *encryption.Encryptor, uris []URI) (string, error) {
+	if uris == nil {
+		uris = []URI{}
+	}
+	data, err := json.Marshal(uris)
+	if err != nil {
+		return "", err
+	}
+	encryptedURIs, err := enc.Encrypt(string(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt uris: %v", err)
+	}
+	return encryptedURIs, nil
+}
+
+// decryptURIs decrypts and parses the uris column.
+func decryptURIs(enc *encryption.Encryptor, encryptedURIs string) ([]URI, error) {
+	if encryptedURIs == "" {
+		return nil, nil
+	}
+	data, err := enc.Decrypt(encryptedURIs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt uris: %v", err)
+	}
+	var uris []URI
+	if err := json.Unmarshal([]byte(data), &uris); err != nil {
+		return nil, fmt.Errorf("failed to parse uris: %v", err)
+	}
+	return uris, nil
+}
+
+// migrateURIs fills the uris column of entries written before entries carried a list of
+// URIs. The list starts with the url column and its match mode and continues with the
+// "URL n" custom fields that imports created for further websites of entries other than
+// secure notes, which are removed.
+func (db *DB) migrateURIs() error {
+	type legacyRow struct {
+		id           uuid.UUID
+		uris         []URI
+		customFields string
+	}
+
+	rows, err := db.Query(`SELECT id, entry_type, url, metadata_encrypted, url_match, custom_fields FROM vaultinator.passwords WHERE uris = '';`)
+	if err != nil {
+		return fmt.Errorf("failed to get passwords: %v", err)
+	}
+	defer rows.Close()
+
+	var legacy []legacyRow
+	for rows.Next() {
+		var row legacyRow
+		var entryType string
+		var url sql.NullString
+		var metadataEncrypted bool
+		var urlMatch string
+		var customFields []byte
+		if err := rows.Scan(&row.id, &entryType, &url, &metadataEncrypted, &urlMatch, &customFields); err != nil {
+			return err
+		}
+
+		primary := url.String
+		if metadataEncrypted && url.Valid {
+			if primary, err = db.encryptor.Decrypt(url.String); err != nil {
+				return fmt.Errorf("failed to decrypt url: %v", err)
+			}
+		}
+		if primary != "" {
+			row.uris = append(row.uris, URI{URL: primary, Match: urlMatch})
+		}
+
+		fields, err := decodeCustomFields(db.encryptor, customFields)
+		if err != nil {
+			return err
+		}
+		var kept []CustomField
+		for _, field := range fields {
+			if entryType != secureNoteType && field.Type == urlFieldType && importedURLField.MatchString(field.Name) && field.Value != "" {
+				row.uris = append(row.uris, URI{URL: field.Value})
+				continue
+			}
+			kept = append(kept, field)
+		}
+		if kept == nil {
+			kept = []CustomField{}
+		}
+		if row.customFields, err = encodeCustomFields(db.encryptor, kept); err != nil {
+			return err
+		}
+		legacy = append(legacy, row)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, row := range legacy {
+		encryptedURIs, err := encryptURIs(db.encryptor, row.uris)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE vaultinator.passwords SET uris = $1, custom_fields = $2 WHERE id = $3;`, encryptedURIs, row.customFields, row.id); err != nil {
+			return fmt.Errorf("failed to migrate uris: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	log.Printf("Migrated URLs of %d password entries to URI lists", len(legacy))
+	return nil
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// SetPasswordFlags updates the favorite and pinned flags of a password entry.
+// A nil flag is left unchanged.
+func (db *DB) SetPasswordFlags(id uuid.UUID, favorite, pinned *bool) error {
+	query := `
+	UPDATE vaultinator.passwords
+	SET favorite = COALESCE($1, favorite), pinned = COALESCE($2, pinned)
+	WHERE id = $3;`
+	result, err := db.Exec(query, favorite, pinned, id)
+	if err != nil {
+		return fmt.Errorf("failed to update password flags: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no password entry found with ID %s: %w", id, ErrNotFound)
+	}
+	log.Printf("Updated flags of password entry with ID: %s", id)
+	return nil
+}
+
+// RecordPasswordUse increments the use count of a non-trashed password entry and
+// sets its last use time. It does not change updated_at.
+func (db *DB) RecordPasswordUse(id uuid.UUID) error {
+	query := `
+	UPDATE vaultinator.passwords
+	SET use_count = use_count + 1, last_used_at = NOW()
+	WHERE id = $1 AND deleted_at IS NULL;`
+	result, err := db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to record password use: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no password entry found with ID %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
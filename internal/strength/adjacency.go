@@ -0,0 +1,98 @@
+package strength
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Keyboard layouts; each token is a key with its unshifted and shifted characters.
+// Rows of the slanted layouts are offset by one character per row, like a real keyboard.
+const (
+	qwertyLayout = "" +
+		"`~ 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) -_ =+\n" +
+		"    qQ wW eE rR tT yY uU iI oO pP [{ ]} \\|\n" +
+		"     aA sS dD fF gG hH jJ kK lL ;: '\"\n" +
+		"      zZ xX cC vV bB nN mM ,< .> /?"
+	dvorakLayout = "" +
+		"`~ 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) [{ ]}\n" +
+		"    '\" ,< .> pP yY fF gG cC rR lL /? =+ \\|\n" +
+		"     aA oO eE uU iI dD hH tT nN sS -_\n" +
+		"      ;: qQ jJ kK xX bB mM wW vV zZ"
+	keypadLayout = "" +
+		"  / * -\n" +
+		"7 8 9 +\n" +
+		"4 5 6\n" +
+		"1 2 3\n" +
+		"  0 ."
+	macKeypadLayout = "" +
+		"  = / *\n" +
+		"7 8 9 -\n" +
+		"4 5 6 +\n" +
+		"1 2 3\n" +
+		"  0 ."
+)
+
+// adjacencyGraph maps each character to the keys next to it, clockwise from the left;
+// an empty string marks a direction without a key
+type adjacencyGraph struct {
+	name      string
+	neighbors map[rune][]string
+	// startingPositions and averageDegree size the space of spatial patterns
+	startingPositions float64
+	averageDegree     float64
+	// keyboard is set for full keyboards, which have shifted characters
+	keyboard bool
+}
+
+var adjacencyGraphs = []*adjacencyGraph{
+	buildGraph("qwerty", qwertyLayout, true),
+	buildGraph("dvorak", dvorakLayout, true),
+	buildGraph("keypad", keypadLayout, false),
+	buildGraph("mac_keypad", macKeypadLayout, false),
+}
+
+// buildGraph builds the adjacency graph of a layout. Keys on slanted keyboards have six
+// neighbours; keys on aligned keypads have eight.
+func buildGraph(name, layout string, slanted bool) *adjacencyGraph {
+	type coord struct{ x, y int }
+	lines := strings.Split(layout, "\n")
+	tokenSize := utf8.RuneCountInString(strings.Fields(lines[0])[0])
+	xUnit := tokenSize + 1
+
+	positions := map[coord]string{}
+	for y, line := range lines {
+		slant := 0
+		if slanted {
+			slant = y
+		}
+		for _, token := range strings.Fields(line) {
+			x := (strings.Index(line, token) - slant) / xUnit
+			positions[coord{x, y}] = token
+		}
+	}
+
+	adjacent := func(x, y int) []coord {
+		if slanted {
+			return []coord{{x - 1, y}, {x, y - 1}, {x + 1, y - 1}, {x + 1, y}, {x, y + 1}, {x - 1, y + 1}}
+		}
+		return []coord{{x - 1, y}, {x - 1, y - 1}, {x, y - 1}, {x + 1, y - 1}, {x + 1, y}, {x + 1, y + 1}, {x, y + 1}, {x - 1, y + 1}}
+	}
+
+	graph := &adjacencyGraph{name: name, neighbors: map[rune][]string{}, keyboard: slanted}
+	degrees := 0
+	for pos, token := range positions {
+		for _, char := range token {
+			var neighbors []string
+			for _, c := range adjacent(pos.x, pos.y) {
+				neighbors = append(neighbors, positions[c])
+				if positions[c] != "" {
+					degrees++
+				}
+			}
+			graph.neighbors[char] = neighbors
+		}
+	}
+	graph.startingPositions = float64(len(graph.neighbors))
+	graph.averageDegree = float64(degrees) / float64(len(graph.neighbors))
+	return graph
+}
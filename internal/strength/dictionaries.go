@@ -0,0 +1,84 @@
+package strength
+
+import (
+	"bufio"
+	"embed"
+	"strings"
+	"sync"
+)
+
+// Dictionary names
+const (
+	dictPasswords   = "passwords"
+	dictEnglish     = "english"
+	dictMaleNames   = "male_names"
+	dictFemaleNames = "female_names"
+	dictSurnames    = "surnames"
+	dictUserInputs  = "user_inputs"
+)
+
+// lists are frequency lists ordered from most to least common, one word per line
+//
+//go:embed lists/*.txt
+var lists embed.FS
+
+// rankedDictionary maps each word of a list to its rank, starting at 1
+type rankedDictionary map[string]int
+
+var (
+	dictionariesOnce sync.Once
+	dictionaries     map[string]rankedDictionary
+)
+
+// frequencyLists returns the built-in dictionaries, loading them on first use
+func frequencyLists() map[string]rankedDictionary {
+	dictionariesOnce.Do(func() {
+		dictionaries = map[string]rankedDictionary{}
+		for _, name := range []string{dictPasswords, dictEnglish, dictMaleNames, dictFemaleNames, dictSurnames} {
+			f, err := lists.Open("lists/" + name + ".txt")
+			if err != nil {
+				panic("strength: missing frequency list " + name)
+			}
+			dict := rankedDictionary{}
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				if word := strings.TrimSpace(scanner.Text()); word != "" {
+					if _, ok := dict[word]; !ok {
+						dict[word] = len(dict) + 1
+					}
+				}
+			}
+			f.Close()
+			dictionaries[name] = dict
+		}
+	})
+	return dictionaries
+}
+
+// buildUserInputs ranks user inputs in the order given
+func buildUserInputs(inputs []string) rankedDictionary {
+	dict := rankedDictionary{}
+	for _, input := range inputs {
+		input = strings.ToLower(input)
+		if _, ok := dict[input]; input != "" && !ok {
+			dict[input] = len(dict) + 1
+		}
+	}
+	return dict
+}
+
+// l33tTable lists the characters commonly substituted for each letter
+var l33tTable = map[rune][]rune{
+	'a': {'4', '@'},
+	'b': {'8'},
+	'c': {'(', '{', '[', '<'},
+	'e': {'3'},
+	'g': {'6', '9'},
+	'i': {'1', '!', '|'},
+	'l': {'1', '|', '7'},
+	'o': {'0'},
+	's': {'$', '5'},
+	't': {'+', '7'},
+	'x': {'%'},
+	'z': {'2'},
+}
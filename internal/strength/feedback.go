@@ -0,0 +1,104 @@
+package strength
+
+const extraWordSuggestion = "Add another word or two. Uncommon words are better."
+
+// feedback explains the weakest part of a password scored 2 or lower
+func feedback(score int, sequence []*match) Feedback {
+	if len(sequence) == 0 {
+		return Feedback{Suggestions: []string{
+			"Use a few words, avoid common phrases",
+			"No need for symbols, digits, or uppercase letters",
+		}}
+	}
+	if score > 2 {
+		return Feedback{Suggestions: []string{}}
+	}
+
+	longest := sequence[0]
+	for _, match := range sequence[1:] {
+		if len([]rune(match.token)) > len([]rune(longest.token)) {
+			longest = match
+		}
+	}
+	fb := matchFeedback(longest, len(sequence) == 1)
+	fb.Suggestions = append([]string{extraWordSuggestion}, fb.Suggestions...)
+	return fb
+}
+
+func matchFeedback(match *match, soleMatch bool) Feedback {
+	switch match.pattern {
+	case patternDictionary:
+		return dictionaryFeedback(match, soleMatch)
+	case patternSpatial:
+		warning := "Short keyboard patterns are easy to guess"
+		if match.turns == 1 {
+			warning = "Straight rows of keys are easy to guess"
+		}
+		return Feedback{Warning: warning, Suggestions: []string{"Use a longer keyboard pattern with more turns"}}
+	case patternRepeat:
+		warning := `Repeats like "abcabcabc" are only slightly harder to guess than "abc"`
+		if len([]rune(match.baseToken)) == 1 {
+			warning = `Repeats like "aaa" are easy to guess`
+		}
+		return Feedback{Warning: warning, Suggestions: []string{"Avoid repeated words and characters"}}
+	case patternSequence:
+		return Feedback{Warning: "Sequences like abc or 6543 are easy to guess", Suggestions: []string{"Avoid sequences"}}
+	case patternRegex:
+		return Feedback{Warning: "Recent years are easy to guess", Suggestions: []string{"Avoid recent years", "Avoid years that are associated with you"}}
+	case patternDate:
+		return Feedback{Warning: "Dates are often easy to guess", Suggestions: []string{"Avoid dates and years that are associated with you"}}
+	}
+	return Feedback{Suggestions: []string{}}
+}
+
+func dictionaryFeedback(match *match, soleMatch bool) Feedback {
+	var warning string
+	switch match.dictionaryName {
+	case dictPasswords:
+		switch {
+		case soleMatch && !match.l33t && !match.reversed && match.rank <= 10:
+			warning = "This is a top-10 common password"
+		case soleMatch && !match.l33t && !match.reversed && match.rank <= 100:
+			warning = "This is a top-100 common password"
+		case soleMatch && !match.l33t && !match.reversed:
+			warning = "This is a very common password"
+		case match.guesses <= 1e4:
+			warning = "This is similar to a commonly used password"
+		}
+	case dictEnglish:
+		if soleMatch {
+			warning = "A word by itself is easy to guess"
+		}
+	case dictSurnames, dictMaleNames, dictFemaleNames:
+		warning = "Common names and surnames are easy to guess"
+		if soleMatch {
+			warning = "Names and surnames by themselves are easy to guess"
+		}
+	case dictUserInputs:
+		warning = "Passwords containing the entry's title or username are easy to guess"
+	}
+
+	suggestions := []string{}
+	token := []rune(match.token)
+	upper := 0
+	lower := false
+	for _, r := range token {
+		if r >= 'A' && r <= 'Z' {
+			upper++
+		}
+		lower = lower || (r >= 'a' && r <= 'z')
+	}
+	switch {
+	case len(token) > 1 && upper == 1 && token[0] >= 'A' && token[0] <= 'Z':
+		suggestions = append(suggestions, "Capitalization doesn't help very much")
+	case upper > 0 && !lower:
+		suggestions = append(suggestions, "All-uppercase is almost as easy to guess as all-lowercase")
+	}
+	if match.reversed && len(token) >= 4 {
+		suggestions = append(suggestions, "Reversed words aren't much harder to guess")
+	}
+	if match.l33t {
+		suggestions = append(suggestions, "Predictable substitutions like '@' instead of 'a' don't help very much")
+	}
+	return Feedback{Warning: warning, Suggestions: suggestions}
+}
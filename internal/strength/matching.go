@@ -0,0 +1,689 @@
+package strength
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Match patterns
+const (
+	patternDictionary = "dictionary"
+	patternSpatial    = "spatial"
+	patternRepeat     = "repeat"
+	patternSequence   = "sequence"
+	patternRegex      = "regex"
+	patternDate       = "date"
+	patternBruteforce = "bruteforce"
+)
+
+const (
+	// maxSequenceDelta is the largest step between characters of a sequence
+	maxSequenceDelta = 5
+	dateMinYear      = 1000
+	dateMaxYear      = 2050
+)
+
+// match is a guessable part of a password, from rune i to rune j inclusive
+type match struct {
+	pattern string
+	i, j    int
+	token   string
+
+	// Dictionary matches
+	dictionaryName string
+	matchedWord    string
+	rank           int
+	reversed       bool
+	l33t           bool
+	// sub maps each substituted character to the letter it replaces
+	sub map[rune]rune
+
+	// Spatial matches
+	graph        *adjacencyGraph
+	turns        int
+	shiftedCount int
+
+	// Repeat matches
+	baseToken   string
+	baseGuesses float64
+	repeatCount int
+
+	// Sequence matches
+	sequenceName  string
+	sequenceSpace int
+	ascending     bool
+
+	// Regex matches
+	regexName string
+
+	// Date matches
+	separator        string
+	year, month, day int
+
+	// guesses is the estimate, computed once by estimateGuesses
+	guesses float64
+}
+
+// matcher finds matches using the built-in dictionaries and the user inputs
+type matcher struct {
+	dictionaries map[string]rankedDictionary
+}
+
+func newMatcher(userInputs []string) *matcher {
+	dictionaries := map[string]rankedDictionary{}
+	for name, dict := range frequencyLists() {
+		dictionaries[name] = dict
+	}
+	dictionaries[dictUserInputs] = buildUserInputs(userInputs)
+	return &matcher{dictionaries: dictionaries}
+}
+
+// omnimatch returns every match found in password, ordered by position
+func (m *matcher) omnimatch(password []rune) []*match {
+	var matches []*match
+	matches = append(matches, m.dictionaryMatch(password)...)
+	matches = append(matches, m.reverseDictionaryMatch(password)...)
+	matches = append(matches, m.l33tMatch(password)...)
+	matches = append(matches, spatialMatch(password)...)
+	matches = append(matches, m.repeatMatch(password)...)
+	matches = append(matches, sequenceMatch(password)...)
+	matches = append(matches, yearMatch(password)...)
+	matches = append(matches, dateMatch(password)...)
+	sortMatches(matches)
+	return matches
+}
+
+func sortMatches(matches []*match) {
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].i != matches[b].i {
+			return matches[a].i < matches[b].i
+		}
+		return matches[a].j < matches[b].j
+	})
+}
+
+func toLower(password []rune) []rune {
+	lower := make([]rune, len(password))
+	for i, r := range password {
+		lower[i] = unicode.ToLower(r)
+	}
+	return lower
+}
+
+// dictionaryMatch finds every substring of password that is a dictionary word
+func (m *matcher) dictionaryMatch(password []rune) []*match {
+	var matches []*match
+	lower := toLower(password)
+	for name, dict := range m.dictionaries {
+		for i := range password {
+			for j := i; j < len(password); j++ {
+				word := string(lower[i : j+1])
+				if rank, ok := dict[word]; ok {
+					matches = append(matches, &match{
+						pattern:        patternDictionary,
+						i:              i,
+						j:              j,
+						token:          string(password[i : j+1]),
+						dictionaryName: name,
+						matchedWord:    word,
+						rank:           rank,
+					})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// reverseDictionaryMatch finds dictionary words written backwards
+func (m *matcher) reverseDictionaryMatch(password []rune) []*match {
+	n := len(password)
+	reversed := make([]rune, n)
+	for i, r := range password {
+		reversed[n-1-i] = r
+	}
+	matches := m.dictionaryMatch(reversed)
+	for _, match := range matches {
+		token := []rune(match.token)
+		for a, b := 0, len(token)-1; a < b; a, b = a+1, b-1 {
+			token[a], token[b] = token[b], token[a]
+		}
+		match.token = string(token)
+		match.reversed = true
+		match.i, match.j = n-1-match.j, n-1-match.i
+	}
+	return matches
+}
+
+// l33tMatch finds dictionary words written with substitutions such as "p@ssw0rd"
+func (m *matcher) l33tMatch(password []rune) []*match {
+	var matches []*match
+	for _, sub := range l33tSubs(password) {
+		subbed := make([]rune, len(password))
+		for i, r := range password {
+			if letter, ok := sub[r]; ok {
+				subbed[i] = letter
+			} else {
+				subbed[i] = r
+			}
+		}
+		for _, match := range m.dictionaryMatch(subbed) {
+			token := password[match.i : match.j+1]
+			// Only keep matches that actually use a substitution
+			if string(toLower(token)) == match.matchedWord || len(token) <= 1 {
+				continue
+			}
+			used := map[rune]rune{}
+			for _, r := range token {
+				if letter, ok := sub[r]; ok {
+					used[r] = letter
+				}
+			}
+			match.token = string(token)
+			match.l33t = true
+			match.sub = used
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// l33tSubs returns every way of reading the substitution characters in password as
+// letters, each character standing for one letter
+func l33tSubs(password []rune) []map[rune]rune {
+	candidates := map[rune][]rune{}
+	var chars []rune
+	for letter, subs := range l33tTable {
+		for _, sub := range subs {
+			if !containsRune(password, sub) {
+				continue
+			}
+			if _, ok := candidates[sub]; !ok {
+				chars = append(chars, sub)
+			}
+			candidates[sub] = append(candidates[sub], letter)
+		}
+	}
+	if len(chars) == 0 {
+		return nil
+	}
+	sort.Slice(chars, func(a, b int) bool { return chars[a] < chars[b] })
+
+	subs := []map[rune]rune{{}}
+	for _, char := range chars {
+		letters := candidates[char]
+		sort.Slice(letters, func(a, b int) bool { return letters[a] < letters[b] })
+		var next []map[rune]rune
+		for _, sub := range subs {
+			for _, letter := range letters {
+				extended := make(map[rune]rune, len(sub)+1)
+				for k, v := range sub {
+					extended[k] = v
+				}
+				extended[char] = letter
+				next = append(next, extended)
+			}
+		}
+		subs = next
+	}
+	return subs
+}
+
+func containsRune(password []rune, r rune) bool {
+	for _, c := range password {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
+
+// isShifted reports whether a keyboard character needs the shift key
+func isShifted(r rune) bool {
+	return strings.ContainsRune(`~!@#$%^&*()_+QWERTYUIOP{}|ASDFGHJKL:"ZXCVBNM<>?`, r)
+}
+
+// spatialMatch finds runs of at least three adjacent keys, such as "qwerty" or "zxcvbn"
+func spatialMatch(password []rune) []*match {
+	var matches []*match
+	for _, graph := range adjacencyGraphs {
+		matches = append(matches, spatialMatchGraph(password, graph)...)
+	}
+	return matches
+}
+
+func spatialMatchGraph(password []rune, graph *adjacencyGraph) []*match {
+	var matches []*match
+	i := 0
+	for i < len(password)-1 {
+		j := i + 1
+		lastDirection := -1
+		turns := 0
+		shiftedCount := 0
+		if graph.keyboard && isShifted(password[i]) {
+			shiftedCount = 1
+		}
+		for {
+			found := false
+			if j < len(password) {
+				for direction, adjacent := range graph.neighbors[password[j-1]] {
+					index := strings.IndexRune(adjacent, password[j])
+					if adjacent == "" || index < 0 {
+						continue
+					}
+					found = true
+					// The second character of a key is its shifted character
+					if index > 0 {
+						shiftedCount++
+					}
+					if lastDirection != direction {
+						turns++
+						lastDirection = direction
+					}
+					break
+				}
+			}
+			if found {
+				j++
+				continue
+			}
+			if j-i > 2 {
+				matches = append(matches, &match{
+					pattern:      patternSpatial,
+					i:            i,
+					j:            j - 1,
+					token:        string(password[i:j]),
+					graph:        graph,
+					turns:        turns,
+					shiftedCount: shiftedCount,
+				})
+			}
+			i = j
+			break
+		}
+	}
+	return matches
+}
+
+// repeatMatch finds repeated characters and strings such as "aaa" and "abcabc". Each
+// repeat is the longest one starting at the leftmost position that has one, and its
+// base is the shortest string that repeats to form it.
+func (m *matcher) repeatMatch(password []rune) []*match {
+	var matches []*match
+	n := len(password)
+	for start := 0; start < n; {
+		i, length, ok := nextRepeat(password, start)
+		if !ok {
+			break
+		}
+		token := password[i : i+length]
+		base := token[:repeatPeriod(token)]
+
+		analysis := m.mostGuessableSequence(base, m.omnimatch(base), false)
+		matches = append(matches, &match{
+			pattern:     patternRepeat,
+			i:           i,
+			j:           i + length - 1,
+			token:       string(token),
+			baseToken:   string(base),
+			baseGuesses: analysis.guesses,
+			repeatCount: length / len(base),
+		})
+		start = i + length
+	}
+	return matches
+}
+
+// nextRepeat finds the leftmost position from start where a string is immediately
+// repeated. Of the longest and the shortest repeated strings there, it returns the run
+// that covers more of the password.
+func nextRepeat(password []rune, start int) (int, int, bool) {
+	n := len(password)
+	run := func(i, size int) int {
+		count := 1
+		for i+(count+1)*size <= n && equalRunes(password[i:i+size], password[i+count*size:i+(count+1)*size]) {
+			count++
+		}
+		return size * count
+	}
+	for i := start; i < n; i++ {
+		shortest, longest := 0, 0
+		for size := 1; i+2*size <= n; size++ {
+			if equalRunes(password[i:i+size], password[i+size:i+2*size]) {
+				if shortest == 0 {
+					shortest = size
+				}
+				longest = size
+			}
+		}
+		if shortest == 0 {
+			continue
+		}
+		if greedy, lazy := run(i, longest), run(i, shortest); greedy > lazy {
+			return i, greedy, true
+		}
+		return i, run(i, shortest), true
+	}
+	return 0, 0, false
+}
+
+// repeatPeriod returns the length of the shortest string that repeats to form token
+func repeatPeriod(token []rune) int {
+	for size := 1; size <= len(token)/2; size++ {
+		if len(token)%size != 0 {
+			continue
+		}
+		periodic := true
+		for k := size; k < len(token); k++ {
+			if token[k] != token[k-size] {
+				periodic = false
+				break
+			}
+		}
+		if periodic {
+			return size
+		}
+	}
+	return len(token)
+}
+
+func equalRunes(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sequenceMatch finds runs of characters with a constant step, such as "abcd", "97531"
+// or "wxyz"
+func sequenceMatch(password []rune) []*match {
+	if len(password) <= 1 {
+		return nil
+	}
+	var matches []*match
+	update := func(i, j, delta int) {
+		if j-i <= 1 && abs(delta) != 1 {
+			return
+		}
+		if delta == 0 || abs(delta) > maxSequenceDelta {
+			return
+		}
+		token := password[i : j+1]
+		name, space := "unicode", 26
+		switch {
+		case allRunes(token, func(r rune) bool { return r >= 'a' && r <= 'z' }):
+			name = "lower"
+		case allRunes(token, func(r rune) bool { return r >= 'A' && r <= 'Z' }):
+			name = "upper"
+		case allRunes(token, isDigit):
+			name, space = "digits", 10
+		}
+		matches = append(matches, &match{
+			pattern:       patternSequence,
+			i:             i,
+			j:             j,
+			token:         string(token),
+			sequenceName:  name,
+			sequenceSpace: space,
+			ascending:     delta > 0,
+		})
+	}
+
+	i := 0
+	lastDelta := int(password[1] - password[0])
+	for k := 1; k < len(password); k++ {
+		delta := int(password[k] - password[k-1])
+		if delta == lastDelta {
+			continue
+		}
+		j := k - 1
+		update(i, j, lastDelta)
+		i = j
+		lastDelta = delta
+	}
+	update(i, len(password)-1, lastDelta)
+	return matches
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func allRunes(token []rune, f func(rune) bool) bool {
+	for _, r := range token {
+		if !f(r) {
+			return false
+		}
+	}
+	return len(token) > 0
+}
+
+// yearMatch finds recent years, 1900 to 2049
+func yearMatch(password []rune) []*match {
+	var matches []*match
+	for i := 0; i+4 <= len(password); i++ {
+		token := password[i : i+4]
+		if !allRunes(token, isDigit) {
+			continue
+		}
+		if (token[0] == '1' && token[1] == '9') || (token[0] == '2' && token[1] == '0' && token[2] <= '4') {
+			matches = append(matches, &match{
+				pattern:   patternRegex,
+				i:         i,
+				j:         i + 3,
+				token:     string(token),
+				regexName: "recent_year",
+			})
+			i += 3
+		}
+	}
+	return matches
+}
+
+// dateSplits are the ways of splitting a date without separators, by length, into three
+// numbers
+var dateSplits = map[int][][2]int{
+	4: {{1, 2}, {2, 3}},
+	5: {{1, 3}, {2, 3}},
+	6: {{1, 2}, {2, 4}, {4, 5}},
+	7: {{1, 3}, {2, 3}, {4, 5}, {4, 6}},
+	8: {{2, 4}, {4, 6}},
+}
+
+// dateMatch finds dates such as "13.3.1997", "1/1/91" and "19970313", keeping only
+// those that are not part of a longer date
+func dateMatch(password []rune) []*match {
+	var matches []*match
+	n := len(password)
+
+	// Dates without separators, 4 to 8 digits
+	for i := 0; i+4 <= n; i++ {
+		for j := i + 3; j <= i+7 && j < n; j++ {
+			token := password[i : j+1]
+			if !allRunes(token, isDigit) {
+				continue
+			}
+			var best *date
+			for _, split := range dateSplits[len(token)] {
+				k, l := split[0], split[1]
+				d, ok := mapIntsToDMY([3]int{atoi(token[:k]), atoi(token[k:l]), atoi(token[l:])})
+				if !ok {
+					continue
+				}
+				// Prefer the reading closest to the current year
+				if best == nil || abs(d.year-referenceYear()) < abs(best.year-referenceYear()) {
+					best = &d
+				}
+			}
+			if best == nil {
+				continue
+			}
+			matches = append(matches, &match{
+				pattern: patternDate,
+				i:       i,
+				j:       j,
+				token:   string(token),
+				year:    best.year,
+				month:   best.month,
+				day:     best.day,
+			})
+		}
+	}
+
+	// Dates with separators, 6 to 10 characters
+	for i := 0; i+6 <= n; i++ {
+		for j := i + 5; j <= i+9 && j < n; j++ {
+			token := password[i : j+1]
+			ints, separator, ok := splitSeparatedDate(token)
+			if !ok {
+				continue
+			}
+			d, ok := mapIntsToDMY(ints)
+			if !ok {
+				continue
+			}
+			matches = append(matches, &match{
+				pattern:   patternDate,
+				i:         i,
+				j:         j,
+				token:     string(token),
+				separator: string(separator),
+				year:      d.year,
+				month:     d.month,
+				day:       d.day,
+			})
+		}
+	}
+
+	var filtered []*match
+	for _, m := range matches {
+		submatch := false
+		for _, other := range matches {
+			if other != m && other.i <= m.i && other.j >= m.j {
+				submatch = true
+				break
+			}
+		}
+		if !submatch {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// splitSeparatedDate parses 1 to 4 digits, a separator, 1 or 2 digits, the same
+// separator and 1 to 4 digits
+func splitSeparatedDate(token []rune) ([3]int, rune, bool) {
+	digits := func(start, min, max int) (int, int, bool) {
+		end := start
+		for end < len(token) && isDigit(token[end]) {
+			end++
+		}
+		if end-start < min || end-start > max {
+			return 0, 0, false
+		}
+		return atoi(token[start:end]), end, true
+	}
+
+	first, pos, ok := digits(0, 1, 4)
+	if !ok || pos >= len(token) || !strings.ContainsRune(" \t/\\_.-", token[pos]) {
+		return [3]int{}, 0, false
+	}
+	separator := token[pos]
+	second, pos, ok := digits(pos+1, 1, 2)
+	if !ok || pos >= len(token) || token[pos] != separator {
+		return [3]int{}, 0, false
+	}
+	third, pos, ok := digits(pos+1, 1, 4)
+	if !ok || pos != len(token) {
+		return [3]int{}, 0, false
+	}
+	return [3]int{first, second, third}, separator, true
+}
+
+func atoi(digits []rune) int {
+	n, _ := strconv.Atoi(string(digits))
+	return n
+}
+
+type date struct {
+	year, month, day int
+}
+
+// mapIntsToDMY reads three numbers as a day, month and year in any common order
+func mapIntsToDMY(ints [3]int) (date, bool) {
+	// The middle number is never the year
+	if ints[1] > 31 || ints[1] <= 0 {
+		return date{}, false
+	}
+	over12, over31, under1 := 0, 0, 0
+	for _, n := range ints {
+		if (n > 99 && n < dateMinYear) || n > dateMaxYear {
+			return date{}, false
+		}
+		if n > 31 {
+			over31++
+		}
+		if n > 12 {
+			over12++
+		}
+		if n <= 0 {
+			under1++
+		}
+	}
+	if over31 >= 2 || over12 == 3 || under1 >= 2 {
+		return date{}, false
+	}
+
+	// A four digit year comes first or last
+	splits := [][3]int{{ints[2], ints[0], ints[1]}, {ints[0], ints[1], ints[2]}}
+	for _, split := range splits {
+		if split[0] >= dateMinYear && split[0] <= dateMaxYear {
+			day, month, ok := mapIntsToDM(split[1], split[2])
+			if !ok {
+				return date{}, false
+			}
+			return date{year: split[0], month: month, day: day}, true
+		}
+	}
+	// Otherwise try a two digit year
+	for _, split := range splits {
+		if day, month, ok := mapIntsToDM(split[1], split[2]); ok {
+			return date{year: twoToFourDigitYear(split[0]), month: month, day: day}, true
+		}
+	}
+	return date{}, false
+}
+
+func mapIntsToDM(a, b int) (int, int, bool) {
+	for _, dm := range [][2]int{{a, b}, {b, a}} {
+		if dm[0] >= 1 && dm[0] <= 31 && dm[1] >= 1 && dm[1] <= 12 {
+			return dm[0], dm[1], true
+		}
+	}
+	return 0, 0, false
+}
+
+func twoToFourDigitYear(year int) int {
+	switch {
+	case year > 99:
+		return year
+	case year > 50:
+		return year + 1900
+	}
+	return year + 2000
+}
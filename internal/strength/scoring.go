@@ -0,0 +1,322 @@
+package strength
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	bruteforceCardinality = 10
+	// minGuessesBeforeGrowingSequence penalises covering a password with many matches,
+	// so an attacker's guess at the number of parts is accounted for
+	minGuessesBeforeGrowingSequence = 10000
+	minSubmatchGuessesSingleChar    = 10
+	minSubmatchGuessesMultiChar     = 50
+	minYearSpace                    = 20
+)
+
+// referenceYear is the year dates and years are assumed to be close to
+func referenceYear() int {
+	return time.Now().Year()
+}
+
+// sequenceResult is the least guessable way found to cover a password with matches
+type sequenceResult struct {
+	guesses float64
+	matches []*match
+}
+
+// mostGuessableSequence finds the sequence of non-overlapping matches, filled in with
+// brute force, that covers password with the fewest guesses. A sequence of l matches
+// costs l! times the product of their guesses, plus a penalty that grows with l.
+func (m *matcher) mostGuessableSequence(password []rune, matches []*match, excludeAdditive bool) sequenceResult {
+	n := len(password)
+	if n == 0 {
+		return sequenceResult{guesses: 1}
+	}
+
+	byEnd := make([][]*match, n)
+	for _, match := range matches {
+		byEnd[match.j] = append(byEnd[match.j], match)
+	}
+	for _, ms := range byEnd {
+		sort.SliceStable(ms, func(a, b int) bool { return ms[a].i < ms[b].i })
+	}
+
+	// For each end position k and sequence length l: the best last match, the product
+	// of guesses and the overall cost
+	best := make([]map[int]*match, n)
+	product := make([]map[int]float64, n)
+	cost := make([]map[int]float64, n)
+	for k := range best {
+		best[k], product[k], cost[k] = map[int]*match{}, map[int]float64{}, map[int]float64{}
+	}
+
+	update := func(match *match, l int) {
+		k := match.j
+		pi := estimateGuesses(match, n)
+		if l > 1 {
+			pi *= product[match.i-1][l-1]
+		}
+		g := factorial(l) * pi
+		if !excludeAdditive {
+			g += math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+		}
+		// Skip a sequence beaten by a shorter or equal one ending at the same position
+		for competingL, competingG := range cost[k] {
+			if competingL <= l && competingG <= g {
+				return
+			}
+		}
+		best[k][l], product[k][l], cost[k][l] = match, pi, g
+	}
+
+	bruteforce := func(i, j int) *match {
+		return &match{pattern: patternBruteforce, i: i, j: j, token: string(password[i : j+1])}
+	}
+	bruteforceUpdate := func(k int) {
+		update(bruteforce(0, k), 1)
+		for i := 1; i <= k; i++ {
+			// Only extend sequences that do not already end in brute force
+			for _, l := range sortedKeys(best[i-1]) {
+				if best[i-1][l].pattern == patternBruteforce {
+					continue
+				}
+				update(bruteforce(i, k), l+1)
+			}
+		}
+	}
+
+	for k := 0; k < n; k++ {
+		for _, match := range byEnd[k] {
+			if match.i > 0 {
+				for _, l := range sortedKeys(best[match.i-1]) {
+					update(match, l+1)
+				}
+			} else {
+				update(match, 1)
+			}
+		}
+		bruteforceUpdate(k)
+	}
+
+	// Unwind from the cheapest sequence covering the whole password
+	bestL, bestG := 0, math.Inf(1)
+	for _, l := range sortedKeys(best[n-1]) {
+		if bestL == 0 || cost[n-1][l] < bestG {
+			bestL, bestG = l, cost[n-1][l]
+		}
+	}
+	var sequence []*match
+	for k, l := n-1, bestL; k >= 0; l-- {
+		last := best[k][l]
+		sequence = append([]*match{last}, sequence...)
+		k = last.i - 1
+	}
+	return sequenceResult{guesses: bestG, matches: sequence}
+}
+
+func sortedKeys(m map[int]*match) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+	return f
+}
+
+// nCk returns the binomial coefficient as a float64
+func nCk(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+	if k == 0 {
+		return 1
+	}
+	r := 1.0
+	for d := 1; d <= k; d++ {
+		r *= float64(n)
+		r /= float64(d)
+		n--
+	}
+	return r
+}
+
+// estimateGuesses estimates the guesses needed for a match in a password of length n.
+// A match covering only part of the password needs at least a few guesses, so single
+// character matches do not make a sequence look cheap.
+func estimateGuesses(match *match, n int) float64 {
+	if match.guesses != 0 {
+		return match.guesses
+	}
+	length := len([]rune(match.token))
+	minGuesses := 1.0
+	if length < n {
+		minGuesses = minSubmatchGuessesMultiChar
+		if length == 1 {
+			minGuesses = minSubmatchGuessesSingleChar
+		}
+	}
+
+	var guesses float64
+	switch match.pattern {
+	case patternBruteforce:
+		guesses = bruteforceGuesses(match)
+	case patternDictionary:
+		guesses = dictionaryGuesses(match)
+	case patternSpatial:
+		guesses = spatialGuesses(match)
+	case patternRepeat:
+		guesses = match.baseGuesses * float64(match.repeatCount)
+	case patternSequence:
+		guesses = sequenceGuesses(match)
+	case patternRegex:
+		// Recent years are the only regex matches
+		guesses = yearGuesses(atoi([]rune(match.token)))
+	case patternDate:
+		guesses = yearGuesses(match.year) * 365
+		if match.separator != "" {
+			guesses *= 4
+		}
+	}
+	match.guesses = math.Max(guesses, minGuesses)
+	return match.guesses
+}
+
+func bruteforceGuesses(match *match) float64 {
+	length := len([]rune(match.token))
+	guesses := math.Pow(bruteforceCardinality, float64(length))
+	if math.IsInf(guesses, 1) {
+		guesses = math.MaxFloat64
+	}
+	// Brute force is never cheaper than a match of the same length
+	minGuesses := float64(minSubmatchGuessesMultiChar + 1)
+	if length == 1 {
+		minGuesses = minSubmatchGuessesSingleChar + 1
+	}
+	return math.Max(guesses, minGuesses)
+}
+
+func yearGuesses(year int) float64 {
+	return math.Max(float64(abs(year-referenceYear())), minYearSpace)
+}
+
+func dictionaryGuesses(match *match) float64 {
+	guesses := float64(match.rank) * uppercaseVariations(match.token) * l33tVariations(match)
+	if match.reversed {
+		guesses *= 2
+	}
+	return guesses
+}
+
+// uppercaseVariations counts the ways a word could be capitalised like token;
+// capitalising only the first or last letter, or every letter, is common and cheap
+func uppercaseVariations(token string) float64 {
+	upper, lower := 0, 0
+	for _, r := range token {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper++
+		case r >= 'a' && r <= 'z':
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	runes := []rune(token)
+	first := runes[0] >= 'A' && runes[0] <= 'Z'
+	last := runes[len(runes)-1] >= 'A' && runes[len(runes)-1] <= 'Z'
+	if (upper == 1 && (first || last) && len(runes) > 1) || lower == 0 {
+		return 2
+	}
+
+	variations := 0.0
+	for i := 1; i <= min(upper, lower); i++ {
+		variations += nCk(upper+lower, i)
+	}
+	return variations
+}
+
+// l33tVariations counts the ways the substitutions in a match could have been applied
+func l33tVariations(match *match) float64 {
+	if !match.l33t {
+		return 1
+	}
+	variations := 1.0
+	lower := toLower([]rune(match.token))
+	for subbed, unsubbed := range match.sub {
+		s, u := 0, 0
+		for _, r := range lower {
+			switch r {
+			case subbed:
+				s++
+			case unsubbed:
+				u++
+			}
+		}
+		if s == 0 || u == 0 {
+			// Every occurrence substituted, or none: the attacker tries both
+			variations *= 2
+			continue
+		}
+		possibilities := 0.0
+		for i := 1; i <= min(s, u); i++ {
+			possibilities += nCk(s+u, i)
+		}
+		variations *= possibilities
+	}
+	return variations
+}
+
+func spatialGuesses(match *match) float64 {
+	s, d := match.graph.startingPositions, match.graph.averageDegree
+	length := len([]rune(match.token))
+	guesses := 0.0
+	for i := 2; i <= length; i++ {
+		for j := 1; j <= min(match.turns, i-1); j++ {
+			guesses += nCk(i-1, j-1) * s * math.Pow(d, float64(j))
+		}
+	}
+	// Shifted keys multiply the guesses like uppercase letters do
+	if match.shiftedCount > 0 {
+		shifted, unshifted := match.shiftedCount, length-match.shiftedCount
+		if unshifted == 0 {
+			guesses *= 2
+		} else {
+			variations := 0.0
+			for i := 1; i <= min(shifted, unshifted); i++ {
+				variations += nCk(shifted+unshifted, i)
+			}
+			guesses *= variations
+		}
+	}
+	return guesses
+}
+
+func sequenceGuesses(match *match) float64 {
+	runes := []rune(match.token)
+	var base float64
+	switch first := runes[0]; {
+	case first == 'a' || first == 'A' || first == 'z' || first == 'Z' || first == '0' || first == '1' || first == '9':
+		// Obvious starting points
+		base = 4
+	case isDigit(first):
+		base = 10
+	default:
+		base = 26
+	}
+	if !match.ascending {
+		base *= 2
+	}
+	return base * float64(len(runes))
+}
@@ -0,0 +1,103 @@
+// Package strength estimates how hard a password is to guess. It follows the zxcvbn
+// approach: the password is matched against common passwords, words and names, keyboard
+// patterns, repeats, sequences, years and dates, and the least guessable way to cover
+// the password with those matches and brute force determines its score.
+package strength
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// maxLength is the number of characters analysed; longer passwords are scored on their
+// first maxLength characters, which only underestimates them
+const maxLength = 100
+
+// Score thresholds in guesses; delta keeps a password right at a threshold in the lower
+// score
+const (
+	scoreDelta    = 5
+	tooGuessable  = 1e3
+	veryGuessable = 1e6
+	safelyGuess   = 1e8
+	unguessable   = 1e10
+)
+
+// Result is the estimated strength of a password
+type Result struct {
+	// Score is 0 (too guessable) to 4 (very unguessable)
+	Score int `json:"score"`
+	// Guesses is the estimated number of guesses needed to find the password
+	Guesses      float64  `json:"guesses"`
+	GuessesLog10 float64  `json:"guesses_log10"`
+	Feedback     Feedback `json:"feedback"`
+}
+
+// Feedback explains a weak score and suggests how to improve the password
+type Feedback struct {
+	Warning     string   `json:"warning"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// Estimate estimates the strength of password. User inputs such as the entry's title and
+// username are treated as the most likely words an attacker would try.
+func Estimate(password string, userInputs ...string) Result {
+	runes := []rune(password)
+	if len(runes) > maxLength {
+		runes = runes[:maxLength]
+	}
+
+	m := newMatcher(userInputs)
+	seq := m.mostGuessableSequence(runes, m.omnimatch(runes), false)
+	guesses := math.Min(seq.guesses, math.MaxFloat64)
+
+	score := guessesToScore(guesses)
+	return Result{
+		Score:        score,
+		Guesses:      guesses,
+		GuessesLog10: math.Round(math.Log10(guesses)*100) / 100,
+		Feedback:     feedback(score, seq.matches),
+	}
+}
+
+// SplitUserInputs returns values and the words they contain, such as the parts of a
+// title or of an email address, as user inputs for Estimate
+func SplitUserInputs(values ...string) []string {
+	var inputs []string
+	seen := map[string]bool{}
+	add := func(input string) {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if input == "" || seen[input] {
+			return
+		}
+		seen[input] = true
+		inputs = append(inputs, input)
+	}
+	for _, value := range values {
+		add(value)
+		for _, word := range strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			// Single characters would match almost anything
+			if len([]rune(word)) > 1 {
+				add(word)
+			}
+		}
+	}
+	return inputs
+}
+
+func guessesToScore(guesses float64) int {
+	switch {
+	case guesses < tooGuessable+scoreDelta:
+		return 0
+	case guesses < veryGuessable+scoreDelta:
+		return 1
+	case guesses < safelyGuess+scoreDelta:
+		return 2
+	case guesses < unguessable+scoreDelta:
+		return 3
+	}
+	return 4
+}
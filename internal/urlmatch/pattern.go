@@ -0,0 +1,94 @@
+package urlmatch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pattern is a host pattern such as "example.com", "*.corp.example.com:8443" or
+// "https://intranet.example.com/app". A "*." prefix matches any subdomain, and a path
+// matches whole segments, so /app matches /app/login but not /apple.
+type Pattern struct {
+	scheme string
+	// host is the exact host, or the parent domain when wildcard is set
+	host     string
+	wildcard bool
+	port     string
+	path     string
+}
+
+// Score ranks patterns matching the same URL; a longer host, an exact host, a longer path
+// and an explicit port or scheme are more specific
+type Score [5]int
+
+// Beats reports whether a is more specific than b
+func (a Score) Beats(b Score) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
+
+// ParsePattern parses a host pattern
+func ParsePattern(raw string) (*Pattern, error) {
+	invalid := fmt.Errorf("%w: invalid URL pattern %q", ErrInvalidURL, raw)
+	var pattern Pattern
+	rest := strings.ToLower(strings.TrimSpace(raw))
+	if scheme, after, ok := strings.Cut(rest, "://"); ok {
+		pattern.scheme, rest = scheme, after
+	}
+	hostPort, path, _ := strings.Cut(rest, "/")
+	if path = strings.TrimSuffix(path, "/"); path != "" {
+		pattern.path = "/" + path
+	}
+	if host, port, ok := strings.Cut(hostPort, ":"); ok {
+		if port == "" {
+			return nil, invalid
+		}
+		hostPort, pattern.port = host, port
+	}
+	if host, ok := strings.CutPrefix(hostPort, "*."); ok {
+		pattern.wildcard, hostPort = true, host
+	}
+	if hostPort == "" || strings.ContainsAny(hostPort, "*?# ") {
+		return nil, invalid
+	}
+	pattern.host = strings.TrimSuffix(hostPort, ".")
+	return &pattern, nil
+}
+
+// Match reports whether the page matches the pattern and how specifically. A pattern
+// without a port matches any port; one with a port compares it with the page's port, which
+// defaults to the scheme's.
+func (p *Pattern) Match(page *URL) (Score, bool) {
+	if p.wildcard {
+		if !strings.HasSuffix(page.host, "."+p.host) {
+			return Score{}, false
+		}
+	} else if page.host != p.host {
+		return Score{}, false
+	}
+	if p.scheme != "" && p.scheme != page.scheme {
+		return Score{}, false
+	}
+	if p.port != "" && p.port != page.port {
+		return Score{}, false
+	}
+	if p.path != "" && page.path != p.path && !strings.HasPrefix(page.path, p.path+"/") {
+		return Score{}, false
+	}
+
+	score := Score{len(p.host), 1, len(p.path), 0, 0}
+	if p.wildcard {
+		score[1] = 0
+	}
+	if p.port != "" {
+		score[3] = 1
+	}
+	if p.scheme != "" {
+		score[4] = 1
+	}
+	return score, true
+}
@@ -0,0 +1,70 @@
+package urlmatch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern, page string
+		match         bool
+	}{
+		{"example.com", "https://example.com/login", true},
+		{"example.com", "https://www.example.com/", false},
+		{"*.example.com", "https://www.example.com/", true},
+		{"*.example.com", "https://example.com/", false},
+		{"Intranet.Example.com", "intranet.example.com", true},
+		{"https://example.com", "http://example.com", false},
+		{"example.com:8443", "https://example.com:8443/", true},
+		{"example.com:443", "https://example.com/", true},
+		{"example.com:8443", "https://example.com/", false},
+		{"example.com/app", "https://example.com/app/login", true},
+		{"example.com/app", "https://example.com/apple", false},
+	}
+	for _, tt := range tests {
+		pattern, err := ParsePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("ParsePattern(%q): %v", tt.pattern, err)
+		}
+		page, err := ParseURL(tt.page)
+		if err != nil {
+			t.Fatalf("ParseURL(%q): %v", tt.page, err)
+		}
+		if _, ok := pattern.Match(page); ok != tt.match {
+			t.Errorf("%q matching %q = %v, want %v", tt.pattern, tt.page, ok, tt.match)
+		}
+	}
+}
+
+func TestPatternScore(t *testing.T) {
+	page, err := ParseURL("https://intranet.example.com/app/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each pattern is more specific than the one before
+	patterns := []string{"*.example.com", "intranet.example.com", "intranet.example.com/app", "https://intranet.example.com/app"}
+	var previous Score
+	for i, raw := range patterns {
+		pattern, err := ParsePattern(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		score, ok := pattern.Match(page)
+		if !ok {
+			t.Fatalf("%q does not match", raw)
+		}
+		if i > 0 && !score.Beats(previous) {
+			t.Errorf("%q does not beat %q", raw, patterns[i-1])
+		}
+		previous = score
+	}
+}
+
+func TestParsePatternInvalid(t *testing.T) {
+	for _, raw := range []string{"", "example.com:", "*.", "exa*mple.com", "example .com"} {
+		if _, err := ParsePattern(raw); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("ParsePattern(%q) = %v, want ErrInvalidURL", raw, err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Package urlmatch decides which saved URLs apply to the page being filled in. A saved URL
+// is compared with the page URL under a match mode: the same registrable domain, judged by
+// the public suffix list embedded in golang.org/x/net/publicsuffix, the same host, the same
+// host and port, a prefix of the page URL, or a regular expression over it. Domains can be
+// declared equivalent, so a login saved for one site also matches its sister sites.
+// Host patterns, used to pick a generator profile, match a host or its subdomains with an
+// optional scheme, port and path prefix.
+package urlmatch
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+var (
+	// ErrInvalidMode is returned for an unknown match mode
+	ErrInvalidMode = errors.New("invalid URL match mode")
+	// ErrInvalidURL is returned for a URL or pattern that cannot be matched
+	ErrInvalidURL = errors.New("invalid URL")
+)
+
+// Match modes
+const (
+	// ModeBaseDomain matches any host under the same registrable domain, so a login saved
+	// for https://example.co.uk matches https://login.example.co.uk/x
+	ModeBaseDomain = "base_domain"
+	// ModeHost matches the same host on any port
+	ModeHost = "host"
+	// ModeHostPort matches the same host and port, taking the scheme's default port when
+	// none is given
+	ModeHostPort = "host_port"
+	// ModeStartsWith matches page URLs starting with the saved URL
+	ModeStartsWith = "starts_with"
+	// ModeRegex matches page URLs matching the saved URL as a regular expression
+	ModeRegex = "regex"
+)
+
+// DefaultMode is used for saved URLs without a mode
+const DefaultMode = ModeBaseDomain
+
+// Modes lists every match mode
+var Modes = []string{ModeBaseDomain, ModeHost, ModeHostPort, ModeStartsWith, ModeRegex}
+
+// IsMode reports whether mode is a known match mode
+func IsMode(mode string) bool {
+	for _, m := range Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Specificity ranks modes from the loosest to the strictest, so that callers can list the
+// most specific matches first
+func Specificity(mode string) int {
+	switch mode {
+	case ModeHost:
+		return 1
+	case ModeHostPort:
+		return 2
+	case ModeStartsWith, ModeRegex:
+		return 3
+	}
+	return 0
+}
+
+// Validate checks that a saved URL can be matched under mode; an empty mode is DefaultMode
+func Validate(saved, mode string) error {
+	if mode == "" {
+		mode = DefaultMode
+	}
+	if !IsMode(mode) {
+		return fmt.Errorf("%w: %q", ErrInvalidMode, mode)
+	}
+	saved = strings.TrimSpace(saved)
+	switch mode {
+	case ModeRegex:
+		if _, err := compile(saved); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+		}
+	case ModeStartsWith:
+		if saved == "" {
+			return fmt.Errorf("%w: a prefix is required", ErrInvalidURL)
+		}
+	default:
+		if _, err := ParseURL(saved); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// URL is a parsed URL with the parts the match modes compare
+type URL struct {
+	raw        string
+	scheme     string
+	host       string
+	port       string
+	path       string
+	baseDomain string
+}
+
+// ParseURL parses a page or saved URL, assuming https when it has no scheme
+func ParseURL(raw string) (*URL, error) {
+	raw = strings.TrimSpace(raw)
+	withScheme := raw
+	if !strings.Contains(withScheme, "://") {
+		withScheme = "https://" + withScheme
+	}
+	u, err := url.Parse(withScheme)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: %q has no host", ErrInvalidURL, raw)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	return &URL{
+		raw:        withScheme,
+		scheme:     strings.ToLower(u.Scheme),
+		host:       host,
+		port:       port,
+		path:       strings.ToLower(u.Path),
+		baseDomain: BaseDomain(host),
+	}, nil
+}
+
+// Host returns the lower-cased host name
+func (u *URL) Host() string {
+	return u.host
+}
+
+// BaseDomain returns the registrable domain of the URL's host
+func (u *URL) BaseDomain() string {
+	return u.baseDomain
+}
+
+// BaseDomain returns the registrable domain of host, the public suffix plus one label, such
+// as example.co.uk for login.example.co.uk. IP addresses, single-label hosts and public
+// suffixes themselves are returned unchanged.
+func BaseDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// Matcher matches saved URLs against page URLs. It caches compiled regular expressions and
+// is not safe for concurrent use.
+type Matcher struct {
+	// groups maps a base domain to the index of its equivalent domain group
+	groups  map[string]int
+	regexps map[string]*regexp.Regexp
+}
+
+// NewMatcher creates a matcher treating the domains of each group as one site under
+// ModeBaseDomain
+func NewMatcher(equivalentDomains [][]string) *Matcher {
+	m := &Matcher{groups: map[string]int{}, regexps: map[string]*regexp.Regexp{}}
+	for i, group := range equivalentDomains {
+		for _, domain := range group {
+			m.groups[BaseDomain(strings.TrimSpace(domain))] = i
+		}
+	}
+	return m
+}
+
+// Match reports whether the saved URL matches the page under mode; an empty mode is
+// DefaultMode. Saved URLs that cannot be parsed never match.
+func (m *Matcher) Match(page *URL, saved, mode string) bool {
+	saved = strings.TrimSpace(saved)
+	if saved == "" {
+		return false
+	}
+	switch mode {
+	case ModeStartsWith:
+		prefix := strings.ToLower(saved)
+		if !strings.Contains(prefix, "://") {
+			prefix = "https://" + prefix
+		}
+		return strings.HasPrefix(strings.ToLower(page.raw), prefix)
+	case ModeRegex:
+		re, ok := m.regexps[saved]
+		if !ok {
+			re, _ = compile(saved)
+			m.regexps[saved] = re
+		}
+		return re != nil && re.MatchString(page.raw)
+	}
+
+	savedURL, err := ParseURL(saved)
+	if err != nil {
+		return false
+	}
+	switch mode {
+	case ModeHost:
+		return savedURL.host == page.host
+	case ModeHostPort:
+		return savedURL.host == page.host && savedURL.port == page.port
+	default:
+		return m.sameSite(savedURL.baseDomain, page.baseDomain)
+	}
+}
+
+// sameSite reports whether two base domains are equal or in the same equivalent group
+func (m *Matcher) sameSite(a, b string) bool {
+	if a == b {
+		return true
+	}
+	groupA, okA := m.groups[a]
+	groupB, okB := m.groups[b]
+	return okA && okB && groupA == groupB
+}
+
+// compile compiles a saved regular expression, matching case-insensitively like hosts do
+func compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, errors.New("the pattern is empty")
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
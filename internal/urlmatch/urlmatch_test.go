@@ -0,0 +1,103 @@
+package urlmatch
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestMatcherMatch(t *testing.T) {
+	tests := []struct {
+		saved, mode, page string
+		match             bool
+	}{
+		// base_domain, the default
+		{"https://example.co.uk", ModeBaseDomain, "https://login.example.co.uk/x", true},
+		{"example.co.uk", "", "https://login.example.co.uk/x", true},
+		{"https://example.co.uk", ModeBaseDomain, "https://other.co.uk/", false},
+		{"https://a.github.io", ModeBaseDomain, "https://b.github.io/", false},
+		{"https://a.github.io", ModeBaseDomain, "https://www.a.github.io/", true},
+		{"http://example.com:8080", ModeBaseDomain, "https://example.com/", true},
+		{"192.168.1.1", ModeBaseDomain, "http://192.168.1.1/admin", true},
+		{"192.168.1.1", ModeBaseDomain, "http://192.168.1.2/admin", false},
+		// host
+		{"https://login.example.com", ModeHost, "https://login.example.com:8443/", true},
+		{"https://Login.Example.com", ModeHost, "http://login.example.com/", true},
+		{"https://example.com", ModeHost, "https://login.example.com/", false},
+		// host_port, with the scheme's default port when none is given
+		{"https://example.com", ModeHostPort, "https://example.com:443/", true},
+		{"http://example.com", ModeHostPort, "http://example.com:80/", true},
+		{"example.com", ModeHostPort, "https://example.com/", true},
+		{"http://example.com", ModeHostPort, "https://example.com/", false},
+		{"https://example.com:8443", ModeHostPort, "https://example.com/", false},
+		// starts_with
+		{"https://example.com/app", ModeStartsWith, "https://example.com/app/login", true},
+		{"example.com/app", ModeStartsWith, "https://EXAMPLE.com/app", true},
+		{"https://example.com/app", ModeStartsWith, "http://example.com/app", false},
+		{"https://example.com/app", ModeStartsWith, "https://example.com/", false},
+		// regex
+		{`^https://[a-z]+\.example\.com/`, ModeRegex, "https://Shop.example.com/cart", true},
+		{`^https://[a-z]+\.example\.com/`, ModeRegex, "https://example.com/", false},
+		{`(unclosed`, ModeRegex, "https://example.com/", false},
+		// saved URLs that cannot be used never match
+		{"", ModeBaseDomain, "https://example.com/", false},
+		{"https://", ModeHost, "https://example.com/", false},
+	}
+	m := NewMatcher(nil)
+	for _, tt := range tests {
+		page, err := ParseURL(tt.page)
+		if err != nil {
+			t.Fatalf("ParseURL(%q): %v", tt.page, err)
+		}
+		if got := m.Match(page, tt.saved, tt.mode); got != tt.match {
+			t.Errorf("Match(%q, %q, %q) = %v, want %v", tt.page, tt.saved, tt.mode, got, tt.match)
+		}
+	}
+}
+
+func TestMatcherEquivalentDomains(t *testing.T) {
+	m := NewMatcher([][]string{
+		{"google.com", "youtube.com", "google.co.uk"},
+		{"example.com", " www.example.org "},
+	})
+	tests := []struct {
+		saved, mode, page string
+		match             bool
+	}{
+		{"https://google.com", ModeBaseDomain, "https://www.youtube.com/", true},
+		{"https://accounts.google.co.uk", ModeBaseDomain, "https://youtube.com/", true},
+		{"https://example.com", ModeBaseDomain, "https://login.example.org/", true},
+		{"https://example.com", ModeBaseDomain, "https://google.com/", false},
+		{"https://google.com", ModeBaseDomain, "https://google.de/", false},
+		// Only base_domain follows the groups
+		{"https://google.com", ModeHost, "https://youtube.com/", false},
+		{"https://google.com", ModeHostPort, "https://youtube.com/", false},
+	}
+	for _, tt := range tests {
+		page, err := ParseURL(tt.page)
+		if err != nil {
+			t.Fatalf("ParseURL(%q): %v", tt.page, err)
+		}
+		if got := m.Match(page, tt.saved, tt.mode); got != tt.match {
+			t.Errorf("Match(%q, %q, %q) = %v, want %v", tt.page, tt.saved, tt.mode, got, tt.match)
+		}
+	}
+}
+
+func TestSpecificity(t *testing.T) {
+	modes := []string{ModeRegex, ModeBaseDomain, ModeHostPort, "", ModeStartsWith, ModeHost}
+	sort.SliceStable(modes, func(i, j int) bool {
+		return Specificity(modes[i]) > Specificity(modes[j])
+	})
+	want := []string{ModeRegex, ModeStartsWith, ModeHostPort, ModeHost, ModeBaseDomain, ""}
+	for i := range want {
+		if modes[i] != want[i] {
+			t.Fatalf("modes by specificity = %q, want %q", modes, want)
+		}
+	}
+	if Specificity("") != Specificity(DefaultMode) {
+		t.Errorf("Specificity(\"\") = %d, want Specificity(%q) = %d", Specificity(""), DefaultMode, Specificity(DefaultMode))
+	}
+	if Specificity(ModeStartsWith) != Specificity(ModeRegex) {
+		t.Errorf("starts_with and regex should rank alike")
+	}
+}
@@ -14,29 +14,67 @@ function App() {
     username: '',
     password: '',
     url: '',
-    notes: ''
+    url_match: '',
+    more_urls: '',
+    notes: '',
+    totp: '',
+    rotation_days: 0
   });
   const [showPasswordForm, setShowPasswordForm] = useState(false);
   const [isInitialized, setIsInitialized] = useState(false);
+  const [isLocked, setIsLocked] = useState(false);
+  const [unlockPassword, setUnlockPassword] = useState('');
   const [showChangePasswordForm, setShowChangePasswordForm] = useState(false);
+  const [showImportForm, setShowImportForm] = useState(false);
+  const [importFile, setImportFile] = useState(null);
+  const [importFormat, setImportFormat] = useState('csv');
+  const [importPreview, setImportPreview] = useState(null);
+  const [importPassphrase, setImportPassphrase] = useState('');
+  const [showExportForm, setShowExportForm] = useState(false);
+  const [exportFormat, setExportFormat] = useState('bitwarden');
+  const [exportPassphrase, setExportPassphrase] = useState('');
+  const [exportRecipients, setExportRecipients] = useState('');
+  const [generatorMode, setGeneratorMode] = useState('characters');
+  const [generated, setGenerated] = useState(null);
+  const [passwordStrength, setPasswordStrength] = useState(null);
+  const [showRestoreForm, setShowRestoreForm] = useState(false);
+  const [restoreFile, setRestoreFile] = useState(null);
+  const [restoreMode, setRestoreMode] = useState('merge');
+  const [restorePassphrase, setRestorePassphrase] = useState('');
+  const [restoreIdentity, setRestoreIdentity] = useState('');
+  const [restorePreview, setRestorePreview] = useState(null);
+  const [healthReport, setHealthReport] = useState(null);
   const [currentPassword, setCurrentPassword] = useState('');
   const [newMasterPassword, setNewMasterPassword] = useState('');
   const [confirmNewMasterPassword, setConfirmNewMasterPassword] = useState('');
   const [visiblePasswords, setVisiblePasswords] = useState({});
+  const [revealedEntries, setRevealedEntries] = useState({});
+  const [otpCodes, setOtpCodes] = useState({});
+  const [nextCursor, setNextCursor] = useState('');
   const [searchTerm, setSearchTerm] = useState('');
   const [sortBy, setSortBy] = useState('title');
   const [sortOrder, setSortOrder] = useState('asc');
+  const [dueOnly, setDueOnly] = useState(false);
 
   useEffect(() => {
     checkAuthStatus();
   }, []);
 
+  useEffect(() => {
+    if (isInitialized) {
+      fetchPasswords();
+    }
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [searchTerm, sortBy, sortOrder, dueOnly]);
+
   const checkAuthStatus = async () => {
     try {
       const response = await fetch(`${API_BASE_URL}/api/auth/status`);
       const data = await response.json();
       setIsInitialized(data.initialized);
-      if (data.initialized) {
+      if (data.initialized && !data.unlocked) {
+        setIsLocked(true);
+      } else if (data.initialized) {
         fetchPasswords();
       } else {
         setShowInitForm(true);
@@ -46,16 +84,46 @@ function App() {
     }
   };
 
-  const fetchPasswords = async () => {
+  const fetchPasswords = async (cursor = '') => {
     try {
-      const response = await fetch(`${API_BASE_URL}/api/passwords`);
+      const params = new URLSearchParams({ q: searchTerm, sort: sortBy, order: sortOrder });
+      if (dueOnly) {
+        params.set('due', 'true');
+      }
+      if (cursor) {
+        params.set('cursor', cursor);
+      }
+      const response = await fetch(`${API_BASE_URL}/api/passwords?${params}`);
+      if (response.status === 423) {
+        setIsLocked(true);
+        return;
+      }
       const data = await response.json();
-      setPasswords(data || []);
+      const items = data.items || [];
+      setPasswords(prev => (cursor ? [...prev, ...items] : items));
+      setNextCursor(data.next_cursor || '');
+      if (!cursor) {
+        setRevealedEntries({});
+      }
     } catch (error) {
       setError('Failed to fetch passwords');
     }
   };
 
+  // Secrets are only fetched when the user reveals or copies them
+  const revealEntry = async (id) => {
+    if (revealedEntries[id]) {
+      return revealedEntries[id];
+    }
+    const response = await fetch(`${API_BASE_URL}/api/passwords/${id}`);
+    if (!response.ok) {
+      throw new Error('Failed to reveal password');
+    }
+    const entry = await response.json();
+    setRevealedEntries(prev => ({ ...prev, [id]: entry }));
+    return entry;
+  };
+
   const handleInitialize = async (e) => {
     e.preventDefault();
     try {
@@ -76,26 +144,106 @@ function App() {
     }
   };
 
+  const handleUnlock = async (e) => {
+    e.preventDefault();
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/auth/verify`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ password: unlockPassword })
+      });
+      if (response.ok) {
+        setIsLocked(false);
+        setUnlockPassword('');
+        setError('');
+        fetchPasswords();
+      } else {
+        setError('Invalid master password');
+      }
+    } catch (error) {
+      setError('Failed to unlock vault');
+    }
+  };
+
+  const handleLock = async () => {
+    try {
+      await fetch(`${API_BASE_URL}/api/auth/lock`, { method: 'POST' });
+      setIsLocked(true);
+      setPasswords([]);
+      setRevealedEntries({});
+      setVisiblePasswords({});
+    } catch (error) {
+      setError('Failed to lock vault');
+    }
+  };
+
   const handleAddPassword = async (e) => {
     e.preventDefault();
+    // The URL and its match mode come first, followed by one URI per line of more URLs
+    const { more_urls, url_match, ...entry } = newPassword;
+    const uris = [{ url: entry.url.trim(), match: url_match }]
+      .concat(more_urls.split('\n').map((url) => ({ url: url.trim() })))
+      .filter((uri) => uri.url !== '');
     try {
       const response = await fetch(`${API_BASE_URL}/api/passwords`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify(newPassword)
+        body: JSON.stringify({ ...entry, uris })
       });
       if (response.ok) {
         setShowPasswordForm(false);
-        setNewPassword({ title: '', username: '', password: '', url: '', notes: '' });
+        setNewPassword({ title: '', username: '', password: '', url: '', url_match: '', more_urls: '', notes: '', totp: '', rotation_days: 0 });
+        setPasswordStrength(null);
         fetchPasswords();
       } else {
-        setError('Failed to add password');
+        // A password below the minimum strength policy is rejected with the reason
+        setError(`Failed to add password: ${await response.text()}`);
       }
     } catch (error) {
       setError('Failed to add password');
     }
   };
 
+  const handleGeneratePassword = async () => {
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/generate`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        // The URL selects a generator profile with the site's rules when one matches
+        body: JSON.stringify({ mode: generatorMode, url: newPassword.url })
+      });
+      if (!response.ok) {
+        setError(`Failed to generate password: ${await response.text()}`);
+        return;
+      }
+      const result = await response.json();
+      setNewPassword({ ...newPassword, password: result.password });
+      setGenerated(result);
+      checkPasswordStrength(result.password);
+    } catch (error) {
+      setError('Failed to generate password');
+    }
+  };
+
+  const checkPasswordStrength = async (password) => {
+    if (!password) {
+      setPasswordStrength(null);
+      return;
+    }
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/strength`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ password, title: newPassword.title, username: newPassword.username })
+      });
+      if (response.ok) {
+        setPasswordStrength(await response.json());
+      }
+    } catch (error) {
+      setPasswordStrength(null);
+    }
+  };
+
   const handleDeletePassword = async (id) => {
     try {
       const response = await fetch(`${API_BASE_URL}/api/passwords/${id}`, {
@@ -111,6 +259,156 @@ function App() {
     }
   };
 
+  // Imports run as a dry run first so the user can review what will be created, merged or skipped
+  const handleImport = async (e, dryRun) => {
+    e.preventDefault();
+    if (!importFile) {
+      return;
+    }
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/import?format=${importFormat}&dry_run=${dryRun}`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': { csv: 'text/csv', bitwarden: 'application/json', lastpass: 'text/csv' }[importFormat] || 'application/octet-stream',
+          'X-Import-Passphrase': importPassphrase
+        },
+        body: importFile
+      });
+      if (!response.ok) {
+        setError(`Failed to import passwords: ${await response.text()}`);
+        return;
+      }
+      const report = await response.json();
+      if (dryRun) {
+        setImportPreview(report);
+      } else {
+        closeImportForm();
+        fetchPasswords();
+      }
+    } catch (error) {
+      setError('Failed to import passwords');
+    }
+  };
+
+  const closeImportForm = () => {
+    setShowImportForm(false);
+    setImportFile(null);
+    setImportFormat('csv');
+    setImportPassphrase('');
+    setImportPreview(null);
+  };
+
+  // Exports are fetched so the passphrase can travel in a header, then saved as a download
+  const handleExport = async (e) => {
+    e.preventDefault();
+    if (exportFormat === 'bitwarden' && !window.confirm('The export contains all your passwords unencrypted. Continue?')) {
+      return;
+    }
+    try {
+      // age backups are encrypted to public keys given one per line
+      const recipients = exportFormat === 'age'
+        ? exportRecipients.split('\n').map(line => line.trim()).filter(line => line && !line.startsWith('#'))
+        : [];
+      const query = [`format=${exportFormat}`, ...recipients.map(r => `recipient=${encodeURIComponent(r)}`)].join('&');
+      const response = await fetch(`${API_BASE_URL}/api/export?${query}`, {
+        headers: { 'X-Export-Passphrase': exportPassphrase }
+      });
+      if (!response.ok) {
+        setError(`Failed to export passwords: ${await response.text()}`);
+        return;
+      }
+      const url = URL.createObjectURL(await response.blob());
+      const link = document.createElement('a');
+      link.href = url;
+      link.download = {
+        bitwarden: 'vault-inator-bitwarden.json',
+        kdbx: 'vault-inator.kdbx',
+        vault: 'vault-inator-backup.vault',
+        age: 'vault-inator-backup.vault.age',
+      }[exportFormat];
+      link.click();
+      URL.revokeObjectURL(url);
+      closeExportForm();
+    } catch (error) {
+      setError('Failed to export passwords');
+    }
+  };
+
+  const closeExportForm = () => {
+    setShowExportForm(false);
+    setExportFormat('bitwarden');
+    setExportPassphrase('');
+    setExportRecipients('');
+  };
+
+  // Restores run as a dry run first so the user can review what will change
+  const handleRestore = async (e, dryRun) => {
+    e.preventDefault();
+    if (!restoreFile) {
+      return;
+    }
+    if (!dryRun && restoreMode === 'replace' && !window.confirm('Replace deletes every entry and folder in the vault before restoring. Continue?')) {
+      return;
+    }
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/restore?mode=${restoreMode}&dry_run=${dryRun}`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/octet-stream',
+          'X-Restore-Passphrase': restorePassphrase,
+          'X-Restore-Identity': restoreIdentity
+        },
+        body: restoreFile
+      });
+      if (!response.ok) {
+        setError(`Failed to restore backup: ${await response.text()}`);
+        return;
+      }
+      const report = await response.json();
+      if (dryRun) {
+        setRestorePreview(report);
+      } else {
+        closeRestoreForm();
+        fetchPasswords();
+      }
+    } catch (error) {
+      setError('Failed to restore backup');
+    }
+  };
+
+  const handleHealthReport = async () => {
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/reports/health`);
+      if (!response.ok) {
+        setError(`Failed to build health report: ${await response.text()}`);
+        return;
+      }
+      setHealthReport(await response.json());
+    } catch (error) {
+      setError('Failed to build health report');
+    }
+  };
+
+  const closeRestoreForm = () => {
+    setShowRestoreForm(false);
+    setRestoreFile(null);
+    setRestoreMode('merge');
+    setRestorePassphrase('');
+    setRestoreIdentity('');
+    setRestorePreview(null);
+  };
+
+  // An age identity file holds one key per line; comments are dropped so the keys fit in a header
+  const handleRestoreIdentityFile = async (file) => {
+    setRestorePreview(null);
+    if (!file) {
+      setRestoreIdentity('');
+      return;
+    }
+    const keys = (await file.text()).split('\n').map(line => line.trim()).filter(line => line && !line.startsWith('#'));
+    setRestoreIdentity(keys.join(','));
+  };
+
   const handleChangeMasterPassword = async (e) => {
     e.preventDefault();
     if (newMasterPassword !== confirmNewMasterPassword) {
@@ -139,7 +437,16 @@ function App() {
     }
   };
 
-  const togglePasswordVisibility = (id) => {
+  const togglePasswordVisibility = async (id) => {
+    if (!visiblePasswords[id]) {
+      try {
+        await revealEntry(id);
+        recordUse(id);
+      } catch (error) {
+        setError('Failed to reveal password');
+        return;
+      }
+    }
     setVisiblePasswords(prev => ({
       ...prev,
       [id]: !prev[id]
@@ -150,19 +457,53 @@ function App() {
     navigator.clipboard.writeText(text);
   };
 
-  const filteredPasswords = passwords
-    .filter(pwd => 
-      pwd.title.toLowerCase().includes(searchTerm.toLowerCase()) ||
-      pwd.username.toLowerCase().includes(searchTerm.toLowerCase()) ||
-      pwd.url.toLowerCase().includes(searchTerm.toLowerCase())
-    )
-    .sort((a, b) => {
-      const aValue = a[sortBy].toLowerCase();
-      const bValue = b[sortBy].toLowerCase();
-      return sortOrder === 'asc' 
-        ? aValue.localeCompare(bValue)
-        : bValue.localeCompare(aValue);
-    });
+  const copySecret = async (id, pick) => {
+    try {
+      const entry = await revealEntry(id);
+      copyToClipboard(pick(entry));
+      recordUse(id);
+    } catch (error) {
+      setError('Failed to copy password');
+    }
+  };
+
+  // HOTP codes advance the counter on every request, so codes are only fetched on demand
+  const showOtpCode = async (id) => {
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/passwords/${id}/totp`);
+      if (!response.ok) {
+        throw new Error(await response.text());
+      }
+      const code = await response.json();
+      setOtpCodes(prev => ({ ...prev, [id]: code }));
+      copyToClipboard(code.code);
+      recordUse(id);
+    } catch (error) {
+      setError('Failed to generate one-time password');
+    }
+  };
+
+  // Usage counts back the "most used" ordering; failures are not worth interrupting the user
+  const recordUse = (id) => {
+    fetch(`${API_BASE_URL}/api/passwords/${id}/used`, { method: 'POST' }).catch(() => {});
+  };
+
+  const toggleFlag = async (pwd, flag) => {
+    try {
+      const response = await fetch(`${API_BASE_URL}/api/passwords/${pwd.id}/flags`, {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ [flag]: !pwd[flag] })
+      });
+      if (response.ok) {
+        setPasswords(prev => prev.map(p => (p.id === pwd.id ? { ...p, [flag]: !pwd[flag] } : p)));
+      } else {
+        setError('Failed to update password');
+      }
+    } catch (error) {
+      setError('Failed to update password');
+    }
+  };
 
   if (showInitForm) {
     return (
@@ -186,11 +527,64 @@ function App() {
     );
   }
 
+  if (isLocked) {
+    return (
+      <div className="init-container">
+        <div className="init-card">
+          <h2>Unlock Vault</h2>
+          {error && <div className="error-message">{error}</div>}
+          <form onSubmit={handleUnlock}>
+            <div className="form-group">
+              <label>Master Password:</label>
+              <input
+                type="password"
+                value={unlockPassword}
+                onChange={(e) => setUnlockPassword(e.target.value)}
+                required
+              />
+            </div>
+            <button type="submit" className="btn-primary">Unlock</button>
+          </form>
+        </div>
+      </div>
+    );
+  }
+
   return (
     <div className="app-container">
       <header className="app-header">
         <h1>Vault-inator</h1>
         <div className="header-actions">
+          <button 
+            className="btn-secondary"
+            onClick={handleLock}
+          >
+            Lock
+          </button>
+          <button 
+            className="btn-secondary"
+            onClick={() => setShowImportForm(true)}
+          >
+            Import
+          </button>
+          <button 
+            className="btn-secondary"
+            onClick={() => setShowExportForm(true)}
+          >
+            Export
+          </button>
+          <button 
+            className="btn-secondary"
+            onClick={() => setShowRestoreForm(true)}
+          >
+            Restore
+          </button>
+          <button 
+            className="btn-secondary"
+            onClick={handleHealthReport}
+          >
+            Health
+          </button>
           <button 
             className="btn-secondary"
             onClick={() => setShowChangePasswordForm(true)}
@@ -225,6 +619,10 @@ function App() {
             <option value="title">Title</option>
             <option value="username">Username</option>
             <option value="url">URL</option>
+            <option value="updated">Last updated</option>
+            <option value="relevance">Relevance</option>
+            <option value="favorites">Favorites first</option>
+            <option value="most_used">Most used</option>
           </select>
           <button 
             className="btn-icon"
@@ -232,15 +630,37 @@ function App() {
           >
             {sortOrder === 'asc' ? '↑' : '↓'}
           </button>
+          <label className="due-filter">
+            <input
+              type="checkbox"
+              checked={dueOnly}
+              onChange={(e) => setDueOnly(e.target.checked)}
+            />
+            Due for rotation
+          </label>
         </div>
       </div>
 
       <div className="passwords-grid">
-        {filteredPasswords.map((pwd) => (
+        {passwords.map((pwd) => (
           <div key={pwd.id} className="password-card">
             <div className="card-header">
               <h3>{pwd.title}</h3>
               <div className="card-actions">
+                <button
+                  className="btn-icon"
+                  onClick={() => toggleFlag(pwd, 'pinned')}
+                  data-tooltip={pwd.pinned ? "Unpin" : "Pin"}
+                >
+                  {pwd.pinned ? '📌' : '📍'}
+                </button>
+                <button
+                  className="btn-icon"
+                  onClick={() => toggleFlag(pwd, 'favorite')}
+                  data-tooltip={pwd.favorite ? "Remove from Favorites" : "Add to Favorites"}
+                >
+                  {pwd.favorite ? '★' : '☆'}
+                </button>
                 <button 
                   className="btn-icon"
                   onClick={() => togglePasswordVisibility(pwd.id)}
@@ -274,16 +694,45 @@ function App() {
               <div className="info-row">
                 <span className="label">Password:</span>
                 <div className="value-with-copy">
-                  <span>{visiblePasswords[pwd.id] ? pwd.password : '••••••••'}</span>
+                  <span>{visiblePasswords[pwd.id] && revealedEntries[pwd.id] ? revealedEntries[pwd.id].password : '••••••••'}</span>
                   <button 
                     className="btn-icon"
-                    onClick={() => copyToClipboard(pwd.password)}
+                    onClick={() => copySecret(pwd.id, entry => entry.password)}
                     data-tooltip="Copy Password"
                   >
                     📋
                   </button>
                 </div>
               </div>
+              {pwd.rotation && (
+                <div className="info-row">
+                  <span className="label">Rotation:</span>
+                  <span className={`rotation-${pwd.rotation.state}`}>
+                    {pwd.rotation.state === 'overdue'
+                      ? `Overdue by ${-pwd.rotation.days_left} days`
+                      : `Due in ${pwd.rotation.days_left} days`}
+                    {` (every ${pwd.rotation.interval_days} days${pwd.rotation.folder_id ? ', from folder' : ''})`}
+                  </span>
+                </div>
+              )}
+              {pwd.has_totp && (
+                <div className="info-row">
+                  <span className="label">2FA Code:</span>
+                  <div className="value-with-copy">
+                    <span>
+                      {otpCodes[pwd.id] ? otpCodes[pwd.id].code : '••••••'}
+                      {otpCodes[pwd.id] && otpCodes[pwd.id].remaining ? ` (${otpCodes[pwd.id].remaining}s)` : ''}
+                    </span>
+                    <button
+                      className="btn-icon"
+                      onClick={() => showOtpCode(pwd.id)}
+                      data-tooltip="Copy Code"
+                    >
+                      🔢
+                    </button>
+                  </div>
+                </div>
+              )}
               {pwd.url && (
                 <div className="info-row">
                   <span className="label">URL:</span>
@@ -301,10 +750,44 @@ function App() {
                   </div>
                 </div>
               )}
-              {pwd.notes && (
+              {(pwd.uris || []).slice(1).map((uri, index) => (
+                <div key={index} className="info-row">
+                  <span className="label">URL {index + 2}:</span>
+                  <div className="value-with-copy">
+                    <a href={uri.url} target="_blank" rel="noopener noreferrer">
+                      {uri.url}
+                    </a>
+                    <button
+                      className="btn-icon"
+                      onClick={() => copyToClipboard(uri.url)}
+                      data-tooltip="Copy URL"
+                    >
+                      📋
+                    </button>
+                  </div>
+                </div>
+              ))}
+              {((revealedEntries[pwd.id] && revealedEntries[pwd.id].fields) || []).map((field, index) => (
+                <div key={index} className="info-row">
+                  <span className="label">{field.name}:</span>
+                  <div className="value-with-copy">
+                    <span>
+                      {field.type === 'hidden' && !visiblePasswords[pwd.id] ? '••••••••' : field.value}
+                    </span>
+                    <button
+                      className="btn-icon"
+                      onClick={() => copyToClipboard(field.value)}
+                      data-tooltip={`Copy ${field.name}`}
+                    >
+                      📋
+                    </button>
+                  </div>
+                </div>
+              ))}
+              {revealedEntries[pwd.id] && revealedEntries[pwd.id].notes && (
                 <div className="info-row">
                   <span className="label">Notes:</span>
-                  <span className="notes">{pwd.notes}</span>
+                  <span className="notes">{revealedEntries[pwd.id].notes}</span>
                 </div>
               )}
             </div>
@@ -312,6 +795,14 @@ function App() {
         ))}
       </div>
 
+      {nextCursor && (
+        <div className="load-more">
+          <button className="btn-secondary" onClick={() => fetchPasswords(nextCursor)}>
+            Load more
+          </button>
+        </div>
+      )}
+
       {showPasswordForm && (
         <div className="modal">
           <div className="modal-content">
@@ -343,9 +834,44 @@ function App() {
                   type="password"
                   id="password"
                   value={newPassword.password}
-                  onChange={(e) => setNewPassword({ ...newPassword, password: e.target.value })}
+                  onChange={(e) => {
+                    setNewPassword({ ...newPassword, password: e.target.value });
+                    setGenerated(null);
+                    setPasswordStrength(null);
+                  }}
+                  onBlur={(e) => checkPasswordStrength(e.target.value)}
                   required
                 />
+                <div className="form-actions">
+                  <select value={generatorMode} onChange={(e) => setGeneratorMode(e.target.value)}>
+                    <option value="characters">Random characters</option>
+                    <option value="passphrase">Passphrase</option>
+                  </select>
+                  <button type="button" className="btn-secondary" onClick={handleGeneratePassword}>
+                    Generate
+                  </button>
+                </div>
+                {generated !== null && (
+                  <small>
+                    About {generated.entropy_bits} bits of entropy
+                    {generated.profile_name ? ` using the ${generated.profile_name} profile` : ''}
+                  </small>
+                )}
+                {passwordStrength !== null && (
+                  <small>
+                    Strength {passwordStrength.score} of 4
+                    {passwordStrength.min_score > 0 && passwordStrength.score < passwordStrength.min_score
+                      ? ` (at least ${passwordStrength.min_score} is required)`
+                      : ''}
+                    {passwordStrength.feedback.warning ? `: ${passwordStrength.feedback.warning}` : ''}
+                    {passwordStrength.feedback.suggestions.length > 0 && (
+                      <> {passwordStrength.feedback.suggestions.join(' ')}</>
+                    )}
+                    {passwordStrength.breach_count > 0 && (
+                      <> Seen {passwordStrength.breach_count} times in data breaches.</>
+                    )}
+                  </small>
+                )}
               </div>
               <div className="form-group">
                 <label htmlFor="url">URL:</label>
@@ -357,6 +883,50 @@ function App() {
                   placeholder="https://example.com"
                 />
               </div>
+              <div className="form-group">
+                <label htmlFor="url_match">Match URL by:</label>
+                <select
+                  id="url_match"
+                  value={newPassword.url_match}
+                  onChange={(e) => setNewPassword({ ...newPassword, url_match: e.target.value })}
+                >
+                  <option value="">Base domain</option>
+                  <option value="host">Host</option>
+                  <option value="host_port">Host and port</option>
+                  <option value="starts_with">Starts with</option>
+                  <option value="regex">Regular expression</option>
+                </select>
+              </div>
+              <div className="form-group">
+                <label htmlFor="more_urls">More URLs:</label>
+                <textarea
+                  id="more_urls"
+                  value={newPassword.more_urls}
+                  onChange={(e) => setNewPassword({ ...newPassword, more_urls: e.target.value })}
+                  placeholder="One per line, such as staging or admin consoles"
+                />
+              </div>
+              <div className="form-group">
+                <label htmlFor="totp">2FA Secret:</label>
+                <input
+                  type="password"
+                  id="totp"
+                  value={newPassword.totp}
+                  onChange={(e) => setNewPassword({ ...newPassword, totp: e.target.value })}
+                  placeholder="otpauth://totp/... or base32 secret"
+                />
+              </div>
+              <div className="form-group">
+                <label htmlFor="rotation_days">Rotate every (days):</label>
+                <input
+                  type="number"
+                  id="rotation_days"
+                  min="0"
+                  value={newPassword.rotation_days}
+                  onChange={(e) => setNewPassword({ ...newPassword, rotation_days: parseInt(e.target.value, 10) || 0 })}
+                  placeholder="0 inherits from the folder"
+                />
+              </div>
               <div className="form-group">
                 <label htmlFor="notes">Notes:</label>
                 <textarea
@@ -418,8 +988,274 @@ function App() {
           </div>
         </div>
       )}
+
+      {showImportForm && (
+        <div className="modal">
+          <div className="modal-content">
+            <h2>Import Passwords</h2>
+            <form onSubmit={(e) => handleImport(e, !importPreview)}>
+              <div className="form-group">
+                <label>Format:</label>
+                <select
+                  value={importFormat}
+                  onChange={(e) => {
+                    setImportFormat(e.target.value);
+                    setImportPreview(null);
+                  }}
+                >
+                  <option value="csv">Browser CSV (Chrome, Edge, Firefox or Safari)</option>
+                  <option value="bitwarden">Bitwarden JSON (unencrypted)</option>
+                  <option value="kdbx">KeePass database (KDBX 4)</option>
+                  <option value="1pux">1Password export (1PUX)</option>
+                  <option value="lastpass">LastPass CSV</option>
+                </select>
+              </div>
+              {importFormat === 'kdbx' && (
+                <div className="form-group">
+                  <label>Database password:</label>
+                  <input
+                    type="password"
+                    value={importPassphrase}
+                    onChange={(e) => {
+                      setImportPassphrase(e.target.value);
+                      setImportPreview(null);
+                    }}
+                    required
+                  />
+                </div>
+              )}
+              <div className="form-group">
+                <label>Export file:</label>
+                <input
+                  type="file"
+                  accept={{
+                    csv: '.csv,text/csv',
+                    bitwarden: '.json,application/json',
+                    kdbx: '.kdbx',
+                    '1pux': '.1pux',
+                    lastpass: '.csv,text/csv',
+                  }[importFormat]}
+                  onChange={(e) => {
+                    setImportFile(e.target.files[0] || null);
+                    setImportPreview(null);
+                  }}
+                  required
+                />
+              </div>
+              {importPreview && (
+                <div className="import-preview">
+                  <p>
+                    {importPreview.created} to create, {importPreview.merged} to merge, {importPreview.skipped} to skip
+                  </p>
+                  <ul>
+                    {importPreview.items.filter(item => item.action !== 'create').map(item => (
+                      <li key={item.row}>
+                        Row {item.row} ({item.title || item.url}): {item.action}{item.reason ? ` – ${item.reason}` : ''}
+                      </li>
+                    ))}
+                  </ul>
+                </div>
+              )}
+              <div className="form-actions">
+                <button type="button" className="btn-secondary" onClick={closeImportForm}>
+                  Cancel
+                </button>
+                <button type="submit" className="btn-primary">
+                  {importPreview ? 'Import' : 'Preview'}
+                </button>
+              </div>
+            </form>
+          </div>
+        </div>
+      )}
+
+      {showExportForm && (
+        <div className="modal">
+          <div className="modal-content">
+            <h2>Export Passwords</h2>
+            <form onSubmit={handleExport}>
+              <div className="form-group">
+                <label>Format:</label>
+                <select value={exportFormat} onChange={(e) => setExportFormat(e.target.value)}>
+                  <option value="bitwarden">Bitwarden JSON (unencrypted)</option>
+                  <option value="kdbx">KeePass database (KDBX 4)</option>
+                  <option value="vault">Vault-inator backup (encrypted)</option>
+                  <option value="age">Vault-inator backup (age recipients)</option>
+                </select>
+              </div>
+              {exportFormat === 'age' && (
+                <div className="form-group">
+                  <label>Recipients (age1... public keys, one per line):</label>
+                  <textarea
+                    value={exportRecipients}
+                    onChange={(e) => setExportRecipients(e.target.value)}
+                    required
+                  />
+                </div>
+              )}
+              {(exportFormat === 'kdbx' || exportFormat === 'vault') && (
+                <div className="form-group">
+                  <label>{exportFormat === 'kdbx' ? 'Database password:' : 'Backup passphrase:'}</label>
+                  <input
+                    type="password"
+                    value={exportPassphrase}
+                    onChange={(e) => setExportPassphrase(e.target.value)}
+                    required
+                  />
+                </div>
+              )}
+              <div className="form-actions">
+                <button type="button" className="btn-secondary" onClick={closeExportForm}>
+                  Cancel
+                </button>
+                <button type="submit" className="btn-primary">
+                  Export
+                </button>
+              </div>
+            </form>
+          </div>
+        </div>
+      )}
+
+      {showRestoreForm && (
+        <div className="modal">
+          <div className="modal-content">
+            <h2>Restore Backup</h2>
+            <form onSubmit={(e) => handleRestore(e, !restorePreview)}>
+              <div className="form-group">
+                <label>Mode:</label>
+                <select
+                  value={restoreMode}
+                  onChange={(e) => {
+                    setRestoreMode(e.target.value);
+                    setRestorePreview(null);
+                  }}
+                >
+                  <option value="merge">Merge (keep the newer version of each entry)</option>
+                  <option value="replace">Replace (delete everything first)</option>
+                </select>
+              </div>
+              <div className="form-group">
+                <label>Age identity file (for backups encrypted to recipients):</label>
+                <input
+                  type="file"
+                  onChange={(e) => handleRestoreIdentityFile(e.target.files[0] || null)}
+                />
+              </div>
+              {!restoreIdentity && (
+                <div className="form-group">
+                  <label>Backup passphrase:</label>
+                  <input
+                    type="password"
+                    value={restorePassphrase}
+                    onChange={(e) => {
+                      setRestorePassphrase(e.target.value);
+                      setRestorePreview(null);
+                    }}
+                    required
+                  />
+                </div>
+              )}
+              <div className="form-group">
+                <label>Backup file:</label>
+                <input
+                  type="file"
+                  accept=".vault,.age"
+                  onChange={(e) => {
+                    setRestoreFile(e.target.files[0] || null);
+                    setRestorePreview(null);
+                  }}
+                  required
+                />
+              </div>
+              {restorePreview && (
+                <div className="import-preview">
+                  <p>
+                    {restorePreview.removed} to remove, {restorePreview.created} to create, {restorePreview.updated} to update, {restorePreview.unchanged} unchanged
+                  </p>
+                  <p>
+                    {restorePreview.folders_created} folders to create, {restorePreview.folders_updated} to update, {restorePreview.history_added} earlier passwords and {restorePreview.attachments_added} attachments to add
+                  </p>
+                  {restorePreview.errors && (
+                    <ul>
+                      {restorePreview.errors.map(message => <li key={message}>{message}</li>)}
+                    </ul>
+                  )}
+                </div>
+              )}
+              <div className="form-actions">
+                <button type="button" className="btn-secondary" onClick={closeRestoreForm}>
+                  Cancel
+                </button>
+                <button type="submit" className="btn-primary">
+                  {restorePreview ? 'Restore' : 'Preview'}
+                </button>
+              </div>
+            </form>
+          </div>
+        </div>
+      )}
+
+      {healthReport && (
+        <div className="modal">
+          <div className="modal-content">
+            <h2>Vault Health: {healthReport.score}%</h2>
+            <p>{healthReport.checked_entries} logins checked</p>
+            <div className="import-preview">
+              <h3>Reused passwords ({healthReport.reused.length})</h3>
+              <ul>
+                {healthReport.reused.map(group => (
+                  <li key={group.hash}>{group.entries.map(entry => entry.title).join(', ')}</li>
+                ))}
+              </ul>
+              <h3>Weak passwords ({healthReport.weak.length})</h3>
+              <ul>
+                {healthReport.weak.map(entry => (
+                  <li key={entry.id}>
+                    {entry.title}: strength {entry.score} of 4{entry.warning ? `, ${entry.warning}` : ''}
+                  </li>
+                ))}
+              </ul>
+              {healthReport.breach_check && (
+                <>
+                  <h3>Breached passwords ({healthReport.breached.length})</h3>
+                  <ul>
+                    {healthReport.breached.map(entry => (
+                      <li key={entry.id}>{entry.title}: seen {entry.count} times</li>
+                    ))}
+                  </ul>
+                </>
+              )}
+              <h3>Unchanged for over {healthReport.max_age_days} days ({healthReport.old.length})</h3>
+              <ul>
+                {healthReport.old.map(entry => (
+                  <li key={entry.id}>{entry.title}: {entry.age_days} days</li>
+                ))}
+              </ul>
+              <h3>Duplicate logins ({healthReport.duplicates.length})</h3>
+              <ul>
+                {healthReport.duplicates.map(group => (
+                  <li key={group.entries[0].id}>{group.entries.map(entry => entry.title).join(', ')}</li>
+                ))}
+              </ul>
+              <h3>Insecure http:// URLs ({healthReport.insecure_url.length})</h3>
+              <ul>
+                {healthReport.insecure_url.map(entry => <li key={entry.id}>{entry.title}</li>)}
+              </ul>
+              <p>
+                {healthReport.missing_url.length} without a URL, {healthReport.missing_username.length} without a username
+              </p>
+            </div>
+            <div className="form-actions">
+              <button type="button" className="btn-secondary" onClick={() => setHealthReport(null)}>
+                Close
+              </button>
+            </div>
+          </div>
+        </div>
+      )}
     </div>
   );
 }
 
-export default App; 
\ No newline at end of file
+export default App;